@@ -0,0 +1,530 @@
+package nectar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/troubling/nectar/nectarutil"
+)
+
+// AuthProvider authenticates a Client, supplying the token and the service
+// endpoints for its requests. Providers are registered by name with
+// RegisterAuthProvider and chosen with WithAuthProvider; otherwise the
+// built-in "v1", "v2", or "v3" (Keystone) provider is chosen from the auth
+// URL. A provider may also implement GetRegion() string, giving the region
+// its endpoints were chosen for, and SwitchProject(project string)
+// *http.Response, re-scoping its token to another project; see
+// ClientEndpoints and ClientProject. It may also implement GetExpiry()
+// time.Time, giving when its token expires or the zero time if not known,
+// for WithTokenRenewal.
+type AuthProvider interface {
+	// Refresh authenticates anew, returning a 2xx response on success or
+	// the error response otherwise.
+	Refresh() *http.Response
+	GetToken() string
+	// GetEndpoints returns the storage URLs, one for each service endpoint.
+	GetEndpoints() []string
+}
+
+// AuthConfig holds the settings a provider is created with, as given to
+// NewClient.
+type AuthConfig struct {
+	AuthURL  string
+	Tenant   string
+	Username string
+	Password string
+	APIKey   string
+	Region   string
+	// Private selects internal endpoints, such as Rackspace ServiceNet,
+	// rather than public ones.
+	Private bool
+	// OverrideURLs are the storage URLs given to NewClient to use in place
+	// of the provider's endpoints; with these, a provider that finds no
+	// endpoints of its own need not fail.
+	OverrideURLs []string
+	// HTTPClient is the client's own http.Client, which should be used for
+	// any auth requests so they get the same TLS settings and timeouts.
+	HTTPClient *http.Client
+	// Context is the context auth requests should be made with, so they end
+	// with the client's other requests; see WithContext. It may be nil.
+	Context context.Context
+}
+
+// authRequest returns a new auth request made with the config's Context.
+func (config *AuthConfig) authRequest(method string, body io.Reader) (*http.Request, error) {
+	ctx := config.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return http.NewRequestWithContext(ctx, method, config.AuthURL, body)
+}
+
+// AuthProviderFactory returns a new provider for the config.
+type AuthProviderFactory func(config *AuthConfig) AuthProvider
+
+var authProvidersLock sync.Mutex
+var authProviders = map[string]AuthProviderFactory{
+	"v1": newV1Auth,
+	"v2": newKeystoneV2Auth,
+	"v3": newKeystoneV3Auth,
+}
+
+// RegisterAuthProvider makes the provider available by name to
+// WithAuthProvider and the -auth-provider option, replacing any provider
+// already registered with the name.
+func RegisterAuthProvider(name string, factory AuthProviderFactory) {
+	authProvidersLock.Lock()
+	authProviders[name] = factory
+	authProvidersLock.Unlock()
+}
+
+// newAuthProvider returns the named provider or, if name is "", the built-in
+// provider for the auth URL.
+func newAuthProvider(name string, config *AuthConfig) (AuthProvider, error) {
+	if name == "" {
+		if strings.Contains(config.AuthURL, "/v3") {
+			name = "v3"
+		} else if strings.Contains(config.AuthURL, "/v2") {
+			name = "v2"
+		} else {
+			name = "v1"
+		}
+	}
+	authProvidersLock.Lock()
+	factory := authProviders[name]
+	authProvidersLock.Unlock()
+	if factory == nil {
+		return nil, fmt.Errorf("unknown auth provider %q", name)
+	}
+	return factory(config), nil
+}
+
+// v1Auth is the Swift TempAuth style of auth, with the credentials sent as
+// X-Auth-User and X-Auth-Key headers.
+type v1Auth struct {
+	config    AuthConfig
+	token     string
+	endpoints []string
+	expires   time.Time
+}
+
+func newV1Auth(config *AuthConfig) AuthProvider {
+	return &v1Auth{config: *config}
+}
+
+func (a *v1Auth) GetToken() string {
+	return a.token
+}
+
+func (a *v1Auth) GetEndpoints() []string {
+	return a.endpoints
+}
+
+// GetExpiry returns when the token expires, as given by the
+// X-Auth-Token-Expires header, or the zero time if not given.
+func (a *v1Auth) GetExpiry() time.Time {
+	return a.expires
+}
+
+func (a *v1Auth) Refresh() *http.Response {
+	req, err := a.config.authRequest("GET", nil)
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	}
+	au := a.config.Username
+	if a.config.Tenant != "" {
+		au = a.config.Tenant + ":" + a.config.Username
+	}
+	req.Header.Set("X-Auth-User", au)
+	ak := a.config.APIKey
+	if ak == "" {
+		ak = a.config.Password
+	}
+	req.Header.Set("X-Auth-Key", ak)
+	resp, err := a.config.HTTPClient.Do(req)
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	}
+	if resp.StatusCode/100 != 2 {
+		return resp
+	}
+	a.token = resp.Header.Get("X-Auth-Token")
+	if a.token == "" {
+		resp.Body.Close()
+		return nectarutil.ResponseStub(http.StatusInternalServerError, "Response did not have X-Auth-Token header.")
+	}
+	a.expires = time.Time{}
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("X-Auth-Token-Expires"), 64); err == nil {
+		a.expires = time.Now().Add(time.Duration(seconds * float64(time.Second)))
+	}
+	a.endpoints = nil
+	if surl := resp.Header.Get("X-Storage-Url"); surl != "" {
+		a.endpoints = []string{surl}
+	} else if len(a.config.OverrideURLs) == 0 {
+		resp.Body.Close()
+		return nectarutil.ResponseStub(http.StatusInternalServerError, "Response did not have X-Storage-Url header.")
+	}
+	return resp
+}
+
+type keystoneRequestV2 struct {
+	Auth interface{} `json:"auth"`
+}
+
+type keystonePasswordAuthV2 struct {
+	TenantName          string `json:"tenantName"`
+	PasswordCredentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"passwordCredentials"`
+}
+
+type keystoneTokenAuthV2 struct {
+	TenantName string `json:"tenantName"`
+	Token      struct {
+		ID string `json:"id"`
+	} `json:"token"`
+}
+
+type raxAPIKeyAuthV2 struct {
+	APIKeyCredentials struct {
+		Username string `json:"username"`
+		APIKey   string `json:"apiKey"`
+	} `json:"RAX-KSKEY:apiKeyCredentials"`
+}
+
+type keystoneResponseV2 struct {
+	Access struct {
+		Token struct {
+			ID      string `json:"id"`
+			Expires string `json:"expires"`
+			Tenant  struct {
+				Name string `json:"name"`
+				ID   string `json:"id"`
+			} `json:"tenant"`
+		} `json:"token"`
+		ServiceCatalog []struct {
+			Endpoints []struct {
+				PublicURL   string `json:"publicURL"`
+				InternalURL string `json:"internalURL"`
+				Region      string `json:"region"`
+			} `json:"endpoints"`
+			Type string `json:"type"`
+		} `json:"serviceCatalog"`
+		User struct {
+			RaxDefaultRegion string `json:"RAX-AUTH:defaultRegion"`
+		} `json:"user"`
+	} `json:"access"`
+}
+
+// keystoneV2Auth is Keystone v2 auth, by password or by Rackspace API key.
+type keystoneV2Auth struct {
+	config    AuthConfig
+	token     string
+	endpoints []string
+	region    string
+	expires   time.Time
+}
+
+func newKeystoneV2Auth(config *AuthConfig) AuthProvider {
+	a := &keystoneV2Auth{config: *config}
+	if !strings.HasSuffix(a.config.AuthURL, "tokens") {
+		if strings.HasSuffix(a.config.AuthURL, "/") {
+			a.config.AuthURL += "tokens"
+		} else {
+			a.config.AuthURL += "/tokens"
+		}
+	}
+	return a
+}
+
+func (a *keystoneV2Auth) GetToken() string {
+	return a.token
+}
+
+func (a *keystoneV2Auth) GetEndpoints() []string {
+	return a.endpoints
+}
+
+func (a *keystoneV2Auth) GetRegion() string {
+	return a.region
+}
+
+// GetExpiry returns when the token expires, or the zero time if not known.
+func (a *keystoneV2Auth) GetExpiry() time.Time {
+	return a.expires
+}
+
+func (a *keystoneV2Auth) Refresh() *http.Response {
+	var authReq []byte
+	var err error
+	if a.config.Password != "" {
+		creds := &keystonePasswordAuthV2{TenantName: a.config.Tenant}
+		creds.PasswordCredentials.Username = a.config.Username
+		creds.PasswordCredentials.Password = a.config.Password
+		authReq, err = json.Marshal(&keystoneRequestV2{Auth: creds})
+	} else if a.config.APIKey != "" {
+		creds := &raxAPIKeyAuthV2{}
+		creds.APIKeyCredentials.Username = a.config.Username
+		creds.APIKeyCredentials.APIKey = a.config.APIKey
+		authReq, err = json.Marshal(&keystoneRequestV2{Auth: creds})
+	} else {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, "Couldn't figure out what credentials to use.")
+	}
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	}
+	return a.request(authReq)
+}
+
+// SwitchProject exchanges the current token for one scoped to the tenant.
+func (a *keystoneV2Auth) SwitchProject(project string) *http.Response {
+	creds := &keystoneTokenAuthV2{TenantName: project}
+	creds.Token.ID = a.token
+	authReq, err := json.Marshal(&keystoneRequestV2{Auth: creds})
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	}
+	resp := a.request(authReq)
+	if resp.StatusCode/100 == 2 {
+		a.config.Tenant = project
+	}
+	return resp
+}
+
+// request sends the token request, setting the token and endpoints from the
+// response.
+func (a *keystoneV2Auth) request(authReq []byte) *http.Response {
+	req, err := a.config.authRequest("POST", bytes.NewBuffer(authReq))
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.config.HTTPClient.Do(req)
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	}
+	if resp.StatusCode/100 != 2 {
+		return resp
+	}
+	var authResponse keystoneResponseV2
+	if err := json.NewDecoder(resp.Body).Decode(&authResponse); err != nil {
+		resp.Body.Close()
+		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	}
+	resp.Body.Close()
+	a.token = authResponse.Access.Token.ID
+	a.expires, _ = time.Parse(time.RFC3339, authResponse.Access.Token.Expires)
+	a.region = a.config.Region
+	if a.region == "" {
+		a.region = authResponse.Access.User.RaxDefaultRegion
+	}
+	a.endpoints = nil
+	for _, s := range authResponse.Access.ServiceCatalog {
+		if s.Type == "object-store" {
+			for _, e := range s.Endpoints {
+				if e.Region == a.region || a.region == "" || len(s.Endpoints) == 1 {
+					if a.config.Private {
+						a.endpoints = append(a.endpoints, e.InternalURL)
+					} else {
+						a.endpoints = append(a.endpoints, e.PublicURL)
+					}
+				}
+			}
+		}
+	}
+	return nectarutil.ResponseStub(http.StatusOK, "")
+}
+
+type keystoneRequestV3 struct {
+	Auth struct {
+		Identity struct {
+			Methods  []string `json:"methods"`
+			Password struct {
+				User struct {
+					Name   string `json:"name"`
+					Domain struct {
+						Name string `json:"name"`
+					} `json:"domain"`
+					Password string `json:"password"`
+				} `json:"user"`
+			} `json:"password"`
+		} `json:"identity"`
+		Scope *keystoneScopeV3 `json:"scope,omitempty"`
+	} `json:"auth"`
+}
+
+type keystoneTokenRequestV3 struct {
+	Auth struct {
+		Identity struct {
+			Methods []string `json:"methods"`
+			Token   struct {
+				ID string `json:"id"`
+			} `json:"token"`
+		} `json:"identity"`
+		Scope *keystoneScopeV3 `json:"scope,omitempty"`
+	} `json:"auth"`
+}
+
+type keystoneScopeV3 struct {
+	Project struct {
+		Name   string `json:"name"`
+		Domain struct {
+			Name string `json:"name"`
+		} `json:"domain"`
+	} `json:"project"`
+}
+
+// projectScopeV3 returns the scope for the project in the Default domain, or
+// nil if no project is given.
+func projectScopeV3(project string) *keystoneScopeV3 {
+	if project == "" {
+		return nil
+	}
+	scope := &keystoneScopeV3{}
+	scope.Project.Name = project
+	scope.Project.Domain.Name = "Default"
+	return scope
+}
+
+type keystoneResponseV3 struct {
+	Token struct {
+		ExpiresAt string `json:"expires_at"`
+		Catalog   []struct {
+			Type      string `json:"type"`
+			Endpoints []struct {
+				Region    string `json:"region"`
+				URL       string `json:"url"`
+				Interface string `json:"interface"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	} `json:"token"`
+}
+
+// keystoneV3Auth is Keystone v3 auth by password, for users in the Default
+// domain.
+type keystoneV3Auth struct {
+	config    AuthConfig
+	token     string
+	endpoints []string
+	expires   time.Time
+}
+
+func newKeystoneV3Auth(config *AuthConfig) AuthProvider {
+	a := &keystoneV3Auth{config: *config}
+	if !strings.HasSuffix(a.config.AuthURL, "auth/tokens") {
+		if strings.HasSuffix(a.config.AuthURL, "/") {
+			a.config.AuthURL += "auth/tokens"
+		} else {
+			a.config.AuthURL += "/auth/tokens"
+		}
+	}
+	return a
+}
+
+func (a *keystoneV3Auth) GetToken() string {
+	return a.token
+}
+
+func (a *keystoneV3Auth) GetEndpoints() []string {
+	return a.endpoints
+}
+
+func (a *keystoneV3Auth) GetRegion() string {
+	return a.config.Region
+}
+
+// GetExpiry returns when the token expires, or the zero time if not known.
+func (a *keystoneV3Auth) GetExpiry() time.Time {
+	return a.expires
+}
+
+func (a *keystoneV3Auth) Refresh() *http.Response {
+	var authReq []byte
+	var err error
+	if a.config.Password != "" {
+		creds := &keystoneRequestV3{}
+		creds.Auth.Identity.Methods = []string{"password"}
+		creds.Auth.Identity.Password.User.Domain.Name = "Default"
+		creds.Auth.Identity.Password.User.Name = a.config.Username
+		creds.Auth.Identity.Password.User.Password = a.config.Password
+		creds.Auth.Scope = projectScopeV3(a.config.Tenant)
+		authReq, err = json.Marshal(creds)
+	} else if a.config.APIKey != "" {
+		panic("v3 by api key not implemented yet: please use password instead")
+	} else {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, "Couldn't figure out what credentials to use.")
+	}
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	}
+	return a.request(authReq)
+}
+
+// SwitchProject exchanges the current token for one scoped to the project.
+func (a *keystoneV3Auth) SwitchProject(project string) *http.Response {
+	creds := &keystoneTokenRequestV3{}
+	creds.Auth.Identity.Methods = []string{"token"}
+	creds.Auth.Identity.Token.ID = a.token
+	creds.Auth.Scope = projectScopeV3(project)
+	authReq, err := json.Marshal(creds)
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	}
+	resp := a.request(authReq)
+	if resp.StatusCode/100 == 2 {
+		a.config.Tenant = project
+	}
+	return resp
+}
+
+// request sends the token request, setting the token and endpoints from the
+// response.
+func (a *keystoneV3Auth) request(authReq []byte) *http.Response {
+	req, err := a.config.authRequest("POST", bytes.NewBuffer(authReq))
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.config.HTTPClient.Do(req)
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	}
+	if resp.StatusCode/100 != 2 {
+		return resp
+	}
+	defer resp.Body.Close()
+	token := resp.Header.Get("X-Subject-Token")
+	if token == "" {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, "No X-Subject-Token in response.")
+	}
+	a.token = token
+	var authResponse keystoneResponseV3
+	if err := json.NewDecoder(resp.Body).Decode(&authResponse); err != nil {
+		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	}
+	a.expires, _ = time.Parse(time.RFC3339, authResponse.Token.ExpiresAt)
+	intrfc := "public"
+	if a.config.Private {
+		intrfc = "private"
+	}
+	a.endpoints = nil
+	for _, s := range authResponse.Token.Catalog {
+		if s.Type == "object-store" {
+			for _, e := range s.Endpoints {
+				if ((e.Region == a.config.Region || a.config.Region == "") && e.Interface == intrfc) || len(s.Endpoints) == 1 {
+					a.endpoints = append(a.endpoints, e.URL)
+				}
+			}
+		}
+	}
+	return nectarutil.ResponseStub(http.StatusOK, "")
+}
@@ -0,0 +1,36 @@
+package nectar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestV1AuthMissingStorageURL(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Auth-Token", "token")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+	for _, test := range []struct {
+		name         string
+		overrideURLs []string
+		status       int
+	}{
+		{name: "no override URLs", status: http.StatusInternalServerError},
+		{name: "override URLs", overrideURLs: []string{"http://storage/v1/AUTH_test"}, status: http.StatusOK},
+	} {
+		a := newV1Auth(&AuthConfig{AuthURL: s.URL, Username: "test:tester", APIKey: "testing", OverrideURLs: test.overrideURLs, HTTPClient: &http.Client{}})
+		resp := a.Refresh()
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+		if test.status != http.StatusOK && !strings.Contains(string(body), "X-Storage-Url") {
+			t.Errorf("%s: got %q, want the missing X-Storage-Url reported", test.name, body)
+		}
+	}
+}
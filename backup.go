@@ -0,0 +1,367 @@
+package nectar
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backups are kept in a container under a backup name, each backup's files
+// as objects under <name>/<timestamp>/ and its manifest as the object
+// <name>/manifests/<timestamp>.json. The manifest lists every file in the
+// backup, including those unchanged since the previous backup, whose entries
+// refer to the objects uploaded by whichever earlier backup last changed them;
+// so each manifest describes a complete tree, but only changed files are
+// uploaded again.
+const (
+	backupManifests  = "manifests/"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// backupFile is a manifest entry: the file's path relative to the backed up
+// directory, with slashes, and the object holding its content.
+type backupFile struct {
+	Path     string    `json:"path"`
+	Object   string    `json:"object"`
+	Bytes    int64     `json:"bytes"`
+	MD5      string    `json:"md5"`
+	Mode     string    `json:"mode"`
+	Modified time.Time `json:"modified"`
+}
+
+type backupManifest struct {
+	Name    string        `json:"name"`
+	Created time.Time     `json:"created"`
+	Source  string        `json:"source"`
+	Files   []*backupFile `json:"files"`
+}
+
+// backupManifestObject returns the name of the manifest object for the backup
+// name and timestamp, or returns the timestamp as is if it already names a
+// manifest object.
+func backupManifestObject(name string, timestamp string) string {
+	if strings.HasSuffix(timestamp, ".json") {
+		return timestamp
+	}
+	return name + "/" + backupManifests + timestamp + ".json"
+}
+
+// backupManifestList returns the manifest objects for the backup name, oldest
+// first.
+func (cli *CLIInstance) backupManifestList(c Client, container string, name string) ([]string, error) {
+	var names []string
+	err := cli.eachObject(c, container, name+"/"+backupManifests, func(entry *ObjectRecord) bool {
+		if strings.HasSuffix(entry.Name, ".json") {
+			names = append(names, entry.Name)
+		}
+		return true
+	})
+	return names, err
+}
+
+func (cli *CLIInstance) readBackupManifest(c Client, container string, object string) (*backupManifest, error) {
+	resp := c.GetObject(container, object, cli.globalFlagHeaders.Headers())
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		return nil, NewResponseError(resp)
+	}
+	defer resp.Body.Close()
+	var m backupManifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("Could not parse backup manifest %s/%s: %s", container, object, err)
+	}
+	return &m, nil
+}
+
+func (cli *CLIInstance) backup(c Client, args []string) {
+	if err := cli.BackupFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.BackupFlags.Args()
+	if len(args) != 2 {
+		cli.fatalf(cli, "backup requires <sourcepath> <container>.\n")
+	}
+	sourcepath, err := filepath.Abs(args[0])
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	container := args[1]
+	if fi, err := os.Stat(sourcepath); err != nil {
+		cli.fatalf(cli, "Could not stat %s: %s\n", sourcepath, err)
+	} else if !fi.IsDir() {
+		cli.fatalf(cli, "backup requires a directory: %s\n", sourcepath)
+	}
+	name := *cli.backupFlagName
+	if name == "" {
+		name = filepath.Base(sourcepath)
+	}
+	name = strings.Trim(name, "/")
+	resp := c.PutContainer(container, cli.newContainerHeaders(c, container))
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		cli.fatal(cli, NewResponseError(resp))
+	}
+	resp.Body.Close()
+	previous := map[string]*backupFile{}
+	previousObject := ""
+	if !*cli.backupFlagFull {
+		manifests, err := cli.backupManifestList(c, container, name)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		if len(manifests) > 0 {
+			previousObject = manifests[len(manifests)-1]
+			m, err := cli.readBackupManifest(c, container, previousObject)
+			if err != nil {
+				cli.fatal(cli, err)
+			}
+			for _, f := range m.Files {
+				previous[f.Path] = f
+			}
+		}
+	}
+	created := time.Now().UTC()
+	timestamp := created.Format(backupTimeFormat)
+	manifest := &backupManifest{Name: name, Created: created, Source: sourcepath}
+	var manifestLock sync.Mutex
+	var uploaded, failures int
+	interrupt := cli.notifyInterrupt()
+	defer interrupt.stop()
+	root := sourcepath + string(os.PathSeparator)
+	// The walk is as with upload's default -walkers and -queue.
+	walk := newUploadWalk(root, 4, 10000, false, interrupt)
+	events := cli.progressEvents.begin("backup")
+	progress := newTransferProgress(c, "Uploaded", "bytes_sent", cli.reportInterval, 0, 0, walk, events)
+	backupFn := func(path string) error {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		rel := filepath.ToSlash(path[len(root):])
+		f := &backupFile{Path: rel, Bytes: fi.Size(), Mode: fmt.Sprintf("%04o", unixMode(fi.Mode())), Modified: fi.ModTime().UTC()}
+		prev := previous[rel]
+		reused := prev != nil && prev.Bytes == f.Bytes && prev.Modified.Equal(f.Modified)
+		if reused {
+			f.Object = prev.Object
+			f.MD5 = prev.MD5
+			cli.verbosef(cli, "Unchanged %q; reusing %q.\n", path, f.Object)
+		} else {
+			f.Object = name + "/" + timestamp + "/" + rel
+			cli.verbosef(cli, "Uploading %q to %q %q.\n", path, container, f.Object)
+			events.started(path, container, f.Object, f.Bytes)
+			fp, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			hasher := md5.New()
+			resp := c.PutObject(container, f.Object, cli.globalFlagHeaders.Headers(), io.TeeReader(fp, hasher))
+			fp.Close()
+			cli.verboseTransID(resp)
+			if resp.StatusCode/100 != 2 {
+				return NewResponseError(resp)
+			}
+			resp.Body.Close()
+			f.MD5 = fmt.Sprintf("%x", hasher.Sum(nil))
+			if etag := strings.ToLower(resp.Header.Get("Etag")); etag != "" && etag != f.MD5 {
+				return fmt.Errorf("PUT %s/%s: MD5 %s did not match ETag %s", container, f.Object, f.MD5, etag)
+			}
+			progress.completed(f.Bytes)
+			events.completed(path, container, f.Object, f.Bytes)
+		}
+		manifestLock.Lock()
+		manifest.Files = append(manifest.Files, f)
+		if !reused {
+			uploaded++
+		}
+		manifestLock.Unlock()
+		return nil
+	}
+	concurrency := *cli.globalFlagConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for path := range walk.queue {
+				// Once interrupted, queued files are just drained.
+				if interrupt.interrupted() {
+					continue
+				}
+				if err := backupFn(path); err != nil {
+					events.failed(path, container, "", err)
+					if !*cli.globalFlagContinueOnError {
+						cli.fatal(cli, err)
+					}
+					fmt.Fprintln(os.Stderr, err)
+					manifestLock.Lock()
+					failures++
+					manifestLock.Unlock()
+				}
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	progress.Close()
+	events.progress("finished", progress, interrupt.interrupted())
+	if interrupt.interrupted() {
+		cli.fatalf(cli, "Interrupted. %s; no manifest was written, so this backup is incomplete and its objects under %s/%s/ are unreferenced.\n", progress, name, timestamp)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	manifestObject := backupManifestObject(name, timestamp)
+	headers := cli.globalFlagHeaders.Headers()
+	headers["Content-Type"] = "application/json"
+	resp = c.PutObject(container, manifestObject, headers, bytes.NewReader(b))
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		cli.fatal(cli, NewResponseError(resp))
+	}
+	resp.Body.Close()
+	fmt.Printf("Backed up %d files as %s/%s: %d uploaded, %d unchanged", len(manifest.Files), container, manifestObject, uploaded, len(manifest.Files)-uploaded)
+	if previousObject != "" {
+		fmt.Printf(" since %s", previousObject)
+	}
+	fmt.Println(".")
+	if failures > 0 {
+		cli.fatalf(cli, "%d files could not be backed up and are missing from the manifest.\n", failures)
+	}
+}
+
+func (cli *CLIInstance) restore(c Client, args []string) {
+	if err := cli.RestoreFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.RestoreFlags.Args()
+	if *cli.restoreFlagList {
+		if len(args) != 2 {
+			cli.fatalf(cli, "restore -list requires <container> <name>.\n")
+		}
+		manifests, err := cli.backupManifestList(c, args[0], strings.Trim(args[1], "/"))
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		for _, m := range manifests {
+			fmt.Println(strings.TrimSuffix(m[strings.LastIndex(m, "/")+1:], ".json"))
+		}
+		return
+	}
+	if len(args) != 3 {
+		cli.fatalf(cli, "restore requires <container> <name> <destpath>.\n")
+	}
+	container, name, destpath := args[0], strings.Trim(args[1], "/"), args[2]
+	manifestObject := ""
+	if *cli.restoreFlagManifest != "" {
+		manifestObject = backupManifestObject(name, *cli.restoreFlagManifest)
+	} else {
+		manifests, err := cli.backupManifestList(c, container, name)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		if len(manifests) == 0 {
+			cli.fatalf(cli, "No backups named %s in %s.\n", name, container)
+		}
+		manifestObject = manifests[len(manifests)-1]
+	}
+	manifest, err := cli.readBackupManifest(c, container, manifestObject)
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	var total int64
+	for _, f := range manifest.Files {
+		total += f.Bytes
+	}
+	cli.verbosef(cli, "Restoring %d files, %s, from %s/%s.\n", len(manifest.Files), humanBytes(total), container, manifestObject)
+	interrupt := cli.notifyInterrupt()
+	defer interrupt.stop()
+	events := cli.progressEvents.begin("restore")
+	progress := newTransferProgress(c, "Downloaded", "bytes_received", cli.reportInterval, len(manifest.Files), total, nil, events)
+	var lock sync.Mutex
+	var skipped, failures int
+	restoreFn := func(f *backupFile) error {
+		path := filepath.Join(destpath, filepath.FromSlash(f.Path))
+		if rel, err := filepath.Rel(destpath, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			return fmt.Errorf("Refusing to restore %q outside of %s", f.Path, destpath)
+		}
+		if fileUnchanged(path, &ObjectRecord{Bytes: int(f.Bytes), Hash: f.MD5}) {
+			cli.verbosef(cli, "Skipping %q; already restored.\n", path)
+			lock.Lock()
+			skipped++
+			lock.Unlock()
+		} else {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("Could not make directory path %s: %s", filepath.Dir(path), err)
+			}
+			cli.verbosef(cli, "Downloading %s/%s to %s.\n", container, f.Object, path)
+			events.started(path, container, f.Object, f.Bytes)
+			if err := cli.downloadObject(c, nil, nil, container, f.Object, path); err != nil {
+				return err
+			}
+			events.completed(path, container, f.Object, f.Bytes)
+		}
+		progress.completed(f.Bytes)
+		if mode, err := parseOctalMode(f.Mode); err == nil {
+			if err = os.Chmod(path, mode); err != nil {
+				cli.verbosef(cli, "Could not restore mode %s of %s: %s\n", f.Mode, path, err)
+			}
+		}
+		if err := os.Chtimes(path, f.Modified, f.Modified); err != nil {
+			cli.verbosef(cli, "Could not restore modification time of %s: %s\n", path, err)
+		}
+		return nil
+	}
+	files := make(chan *backupFile, len(manifest.Files))
+	for _, f := range manifest.Files {
+		files <- f
+	}
+	close(files)
+	concurrency := *cli.globalFlagConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for f := range files {
+				if interrupt.interrupted() {
+					continue
+				}
+				if err := restoreFn(f); err != nil {
+					events.failed(filepath.Join(destpath, filepath.FromSlash(f.Path)), container, f.Object, err)
+					if !*cli.globalFlagContinueOnError {
+						cli.fatal(cli, err)
+					}
+					fmt.Fprintln(os.Stderr, err)
+					lock.Lock()
+					failures++
+					lock.Unlock()
+				}
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	progress.Close()
+	events.progress("finished", progress, interrupt.interrupted())
+	if interrupt.interrupted() {
+		cli.fatalf(cli, "Interrupted. %s; rerunning the restore skips the files already restored.\n", progress)
+	}
+	fmt.Printf("Restored %d files from %s/%s to %s: %d downloaded, %d already present.\n", len(manifest.Files)-failures, container, manifestObject, destpath, len(manifest.Files)-failures-skipped, skipped)
+	if failures > 0 {
+		cli.fatalf(cli, "%d files could not be restored.\n", failures)
+	}
+}
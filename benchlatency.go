@@ -0,0 +1,67 @@
+package nectar
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyGrowth is the ratio between the bounds of consecutive latencyStats
+// buckets, giving percentiles accurate to about 1%.
+const latencyGrowth = 1.01
+
+// latencyStats tallies the requests of one kind made by a bench, with their
+// errors and latency distribution. Latencies are kept in logarithmic buckets,
+// rather than individually, so memory stays bounded however long the bench
+// runs.
+type latencyStats struct {
+	lock    sync.Mutex
+	count   int64
+	errors  int64
+	buckets map[int]int64
+}
+
+func newLatencyStats() *latencyStats {
+	return &latencyStats{buckets: map[int]int64{}}
+}
+
+// record adds a request that took elapsed and, if failed, counts it as an
+// error.
+func (s *latencyStats) record(elapsed time.Duration, failed bool) {
+	bucket := 0
+	if elapsed > 1 {
+		bucket = int(math.Log(float64(elapsed)) / math.Log(latencyGrowth))
+	}
+	s.lock.Lock()
+	s.count++
+	if failed {
+		s.errors++
+	}
+	s.buckets[bucket]++
+	s.lock.Unlock()
+}
+
+// percentile returns the latency that p percent of the requests were at or
+// under, or 0 if there were no requests.
+func (s *latencyStats) percentile(p float64) time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.count == 0 {
+		return 0
+	}
+	var buckets []int
+	for bucket := range s.buckets {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+	want := int64(math.Ceil(float64(s.count) * p / 100))
+	var seen int64
+	for _, bucket := range buckets {
+		seen += s.buckets[bucket]
+		if seen >= want {
+			return time.Duration(math.Pow(latencyGrowth, float64(bucket)+0.5))
+		}
+	}
+	return time.Duration(math.Pow(latencyGrowth, float64(buckets[len(buckets)-1])+0.5))
+}
@@ -0,0 +1,130 @@
+package nectar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/troubling/nectar/nectarutil"
+)
+
+// benchResults is the JSON report of a bench run stored with -results-container,
+// along with the run's CSV files. A nil *benchResults is valid and stores
+// nothing.
+type benchResults struct {
+	RunID              string    `json:"run_id"`
+	Command            string    `json:"command"`
+	Args               []string  `json:"args"`
+	Start              time.Time `json:"start"`
+	ElapsedSeconds     float64   `json:"elapsed_seconds"`
+	Requests           int64     `json:"requests"`
+	RequestsPerSecond  float64   `json:"requests_per_second"`
+	Bytes              int64     `json:"bytes"`
+	MegabytesPerSecond float64   `json:"megabytes_per_second"`
+	Concurrency        int       `json:"concurrency"`
+	Resources          string    `json:"resources"`
+
+	cli   *CLIInstance
+	c     Client
+	files []string
+}
+
+// newBenchResults returns the results to be stored for the bench command if
+// -results-container was given, or nil otherwise; files are the names of any
+// CSV files the bench will write.
+func (cli *CLIInstance) newBenchResults(c Client, command string, args []string, files ...string) *benchResults {
+	if cli.benchFlagResultsContainer == "" {
+		return nil
+	}
+	r := &benchResults{RunID: nectarutil.UUID(), Command: command, Args: args, Start: time.Now().UTC(), cli: cli, c: c}
+	for _, file := range files {
+		if file != "" {
+			r.files = append(r.files, file)
+		}
+	}
+	return r
+}
+
+// finish records the outcome of the run; transferred is the bytes of object
+// content sent or received, if any.
+func (r *benchResults) finish(elapsed time.Duration, requests int64, transferred int64, concurrency int, resources string) {
+	if r == nil {
+		return
+	}
+	r.ElapsedSeconds = elapsed.Seconds()
+	r.Requests = requests
+	if elapsed > 0 {
+		r.RequestsPerSecond = float64(requests) / elapsed.Seconds()
+	}
+	r.Bytes = transferred
+	r.MegabytesPerSecond = megabytesPerSecond(transferred, elapsed)
+	r.Concurrency = concurrency
+	r.Resources = resources
+}
+
+// store uploads the report and CSV files as objects named
+// <timestamp>-<run-id>/<file> in the results container. This must be called
+// after the CSV files have been closed. Failures are reported but do not stop
+// the process, as the bench itself has already completed.
+func (r *benchResults) store() {
+	if r == nil {
+		return
+	}
+	container := r.cli.benchFlagResultsContainer
+	prefix := r.Start.Format("20060102T150405Z") + "-" + r.RunID + "/"
+	resp := r.c.PutContainer(container, r.cli.globalFlagHeaders.Headers())
+	r.cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		fmt.Fprintln(os.Stderr, NewResponseError(resp))
+		return
+	}
+	resp.Body.Close()
+	for _, file := range r.files {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot open %s while attempting to store bench results: %s\n", file, err)
+			continue
+		}
+		contentType := "text/csv"
+		if strings.HasSuffix(file, ".gz") {
+			contentType = "application/gzip"
+		}
+		r.put(container, prefix+filepath.Base(file), contentType, f)
+		f.Close()
+	}
+	report, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not encode bench report: %s\n", err)
+		return
+	}
+	if r.put(container, prefix+"report.json", "application/json", bytes.NewReader(report)) {
+		fmt.Printf("Stored results in %s/%s\n", container, prefix)
+	}
+}
+
+func (r *benchResults) put(container string, object string, contentType string, body io.Reader) bool {
+	headers := r.cli.globalFlagHeaders.Headers()
+	headers["Content-Type"] = contentType
+	resp := r.c.PutObject(container, object, headers, body)
+	r.cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		fmt.Fprintln(os.Stderr, NewResponseError(resp))
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+// megabytesPerSecond returns the throughput in MB/s, where a MB is one
+// million bytes.
+func megabytesPerSecond(transferred int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(transferred) / 1e6 / elapsed.Seconds()
+}
@@ -0,0 +1,108 @@
+package nectar
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker tracks consecutive failures per endpoint, opening the
+// circuit for an endpoint (refusing requests to it) for a cooldown period
+// once too many failures have occurred in a row. Once the cooldown has passed
+// a single probe request is allowed through; if it succeeds the circuit
+// closes, otherwise it opens for another cooldown period.
+type circuitBreaker struct {
+	failures int
+	cooldown time.Duration
+	lock     sync.Mutex
+	states   map[string]*breakerState
+	// now is time.Now, other than in tests.
+	now func() time.Time
+}
+
+type breakerState struct {
+	consecutive int
+	openUntil   time.Time
+}
+
+func newCircuitBreaker(failures int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{failures: failures, cooldown: cooldown, states: map[string]*breakerState{}, now: time.Now}
+}
+
+// allow returns true if a request may be sent to the endpoint.
+func (b *circuitBreaker) allow(endpoint string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	s := b.states[endpoint]
+	if s == nil || s.consecutive < b.failures {
+		return true
+	}
+	now := b.now()
+	if now.Before(s.openUntil) {
+		return false
+	}
+	// This request is the probe; others wait for its result or another
+	// cooldown period.
+	s.openUntil = now.Add(b.cooldown)
+	return true
+}
+
+// record notes the outcome of a request to the endpoint.
+func (b *circuitBreaker) record(endpoint string, ok bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	s := b.states[endpoint]
+	if s == nil {
+		if ok {
+			return
+		}
+		s = &breakerState{}
+		b.states[endpoint] = s
+	}
+	if ok {
+		s.consecutive = 0
+		s.openUntil = time.Time{}
+		return
+	}
+	s.consecutive++
+	if s.consecutive == b.failures {
+		s.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// retryBudget limits retries to a ratio of the requests made, plus a small
+// reserve, so that retries cannot multiply the load on a struggling cluster.
+type retryBudget struct {
+	ratio  float64
+	lock   sync.Mutex
+	tokens float64
+}
+
+const (
+	retryBudgetReserve = 10
+	retryBudgetMax     = 100
+)
+
+func newRetryBudget(ratio float64) *retryBudget {
+	return &retryBudget{ratio: ratio, tokens: retryBudgetReserve}
+}
+
+// deposit is called for each request made.
+func (b *retryBudget) deposit() {
+	b.lock.Lock()
+	b.tokens += b.ratio
+	if b.tokens > retryBudgetMax {
+		b.tokens = retryBudgetMax
+	}
+	b.lock.Unlock()
+}
+
+// withdraw returns true if a retry may be made.
+func (b *retryBudget) withdraw() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,176 @@
+package nectar
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testClock is a clock for circuitBreaker.now that only moves when told.
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+func newTestBreaker(failures int, cooldown time.Duration) (*circuitBreaker, *testClock) {
+	clock := &testClock{t: time.Unix(1000000000, 0)}
+	b := newCircuitBreaker(failures, cooldown)
+	b.now = clock.now
+	return b, clock
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	// Each step advances the clock, checks allow, then records the outcome
+	// of the request, if any.
+	const (
+		none = iota
+		ok
+		failed
+	)
+	type step struct {
+		advance time.Duration
+		allow   bool
+		outcome int
+	}
+	for _, test := range []struct {
+		name  string
+		steps []step
+	}{
+		{name: "closed", steps: []step{
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{allow: true, outcome: ok},
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{allow: true, outcome: ok},
+		}},
+		{name: "trip", steps: []step{
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{allow: false},
+			{advance: 9 * time.Second, allow: false},
+		}},
+		{name: "probe succeeds", steps: []step{
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{advance: 10 * time.Second, allow: true, outcome: ok},
+			{allow: true, outcome: failed},
+			{allow: true, outcome: ok},
+		}},
+		{name: "one probe at a time", steps: []step{
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{advance: 10 * time.Second, allow: true},
+			{allow: false},
+			{advance: 9 * time.Second, allow: false},
+		}},
+		{name: "probe fails", steps: []step{
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{allow: true, outcome: failed},
+			{advance: 10 * time.Second, allow: true, outcome: failed},
+			{allow: false},
+			{advance: 9 * time.Second, allow: false},
+			{advance: time.Second, allow: true, outcome: ok},
+			{allow: true, outcome: ok},
+		}},
+	} {
+		b, clock := newTestBreaker(3, 10*time.Second)
+		for i, s := range test.steps {
+			clock.t = clock.t.Add(s.advance)
+			if allow := b.allow("http://one"); allow != s.allow {
+				t.Errorf("%s: step %d: allow = %v, want %v", test.name, i+1, allow, s.allow)
+				break
+			}
+			if s.outcome != none {
+				b.record("http://one", s.outcome == ok)
+			}
+		}
+		if !b.allow("http://two") {
+			t.Errorf("%s: another endpoint was refused", test.name)
+		}
+	}
+}
+
+func TestCircuitBreakerFailover(t *testing.T) {
+	bad := newRetryServer(nil, http.StatusInternalServerError)
+	defer bad.Close()
+	good := newRetryServer(nil, http.StatusNoContent)
+	defer good.Close()
+	b, clock := newTestBreaker(1, 10*time.Second)
+	c := newTestClient(bad.URL)
+	c.breaker = b
+
+	// Trip the circuit for the bad endpoint.
+	resp := c.HeadObject("c", "o", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusInternalServerError)
+	}
+	// With the circuit open and no other endpoint, a stub is returned.
+	resp = c.HeadObject("c", "o", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d with every circuit open", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if got := len(bad.requests()); got != 1 {
+		t.Errorf("the bad endpoint got %d requests while open, want just the 1", got)
+	}
+	// Requests aimed at the bad endpoint go to the good one instead.
+	c.ServiceURLs = []string{bad.URL, good.URL}
+	for i := 0; i < 10; i++ {
+		resp = c.HeadObject("c", "o", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusNoContent)
+		}
+	}
+	if got := len(bad.requests()); got != 1 {
+		t.Errorf("the bad endpoint got %d requests while open, want just the 1", got)
+	}
+	if got := len(good.requests()); got != 10 {
+		t.Errorf("the good endpoint got %d requests, want 10", got)
+	}
+	// Once cooled down, the bad endpoint is probed again.
+	clock.t = clock.t.Add(10 * time.Second)
+	c.ServiceURLs = []string{bad.URL}
+	resp = c.HeadObject("c", "o", nil)
+	resp.Body.Close()
+	if got := len(bad.requests()); got != 2 {
+		t.Errorf("the bad endpoint got %d requests after the cooldown, want 2", got)
+	}
+}
+
+func TestRetryBudgetFailover(t *testing.T) {
+	bad := newRetryServer(nil, http.StatusInternalServerError)
+	defer bad.Close()
+	good := newRetryServer(nil, http.StatusNoContent)
+	defer good.Close()
+	c := newTestClient(bad.URL, good.URL)
+	c.budget = &retryBudget{tokens: 100}
+	for i := 0; i < 10; i++ {
+		resp := c.HeadObject("c", "o", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusNoContent)
+		}
+	}
+	if got := len(good.requests()); got != 10 {
+		t.Errorf("the good endpoint got %d requests, want 10", got)
+	}
+	// With the budget spent, a request to the bad endpoint fails.
+	c.budget = &retryBudget{}
+	for tried := len(bad.requests()); len(bad.requests()) == tried; {
+		resp := c.HeadObject("c", "o", nil)
+		resp.Body.Close()
+		if len(bad.requests()) > tried && resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("got status %d, want %d with the budget spent", resp.StatusCode, http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package nectar
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// bufferPool provides fixed size buffers for copying transfer content,
+// reusing them to reduce allocations and GC pressure at high concurrency. The
+// memory in use is bounded by the buffer size times the number of concurrent
+// copies.
+type bufferPool struct {
+	// gets and allocs are first to ensure 64-bit alignment for atomic use.
+	gets   int64
+	allocs int64
+	size   int
+	pool   sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	if size < 512 {
+		size = 512
+	}
+	p := &bufferPool{size: size}
+	p.pool.New = func() interface{} {
+		atomic.AddInt64(&p.allocs, 1)
+		b := make([]byte, p.size)
+		return &b
+	}
+	return p
+}
+
+// copy is io.Copy using a pooled buffer. The ReaderFrom and WriterTo
+// interfaces of dst and src are deliberately hidden so the pooled buffer is
+// always the one used.
+func (p *bufferPool) copy(dst io.Writer, src io.Reader) (int64, error) {
+	atomic.AddInt64(&p.gets, 1)
+	bp := p.pool.Get().(*[]byte)
+	n, err := io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *bp)
+	p.pool.Put(bp)
+	return n, err
+}
+
+// stats returns the number of buffers requested from the pool, the number
+// allocated, and the size of each.
+func (p *bufferPool) stats() map[string]int64 {
+	return map[string]int64{
+		"gets":   atomic.LoadInt64(&p.gets),
+		"allocs": atomic.LoadInt64(&p.allocs),
+		"size":   int64(p.size),
+	}
+}
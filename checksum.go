@@ -0,0 +1,67 @@
+package nectar
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// checksumMetaHeader holds the client-side checksum of an object uploaded with
+// -checksum, as <algorithm>:<hex digest>, so a later download knows what to
+// compute to verify it. Unlike the ETag, it covers the whole content of large
+// objects as well.
+const checksumMetaHeader = "X-Object-Meta-Nectar-Checksum"
+
+// checksumAlgorithms are the names accepted by -checksum.
+var checksumAlgorithms = []string{"md5", "sha1", "sha256", "xxhash"}
+
+// newChecksumHash returns the hash for the algorithm name.
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "xxhash":
+		return newXXHash64(), nil
+	}
+	return nil, fmt.Errorf("unknown checksum algorithm %q; use one of %s", algorithm, strings.Join(checksumAlgorithms, ", "))
+}
+
+// fileChecksum returns the checksum header value for the file.
+func fileChecksum(path string, algorithm string) (string, error) {
+	hasher, err := newChecksumHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%x", algorithm, hasher.Sum(nil)), nil
+}
+
+// parseChecksum splits a checksum header value into its algorithm's hash and
+// the expected hex digest.
+func parseChecksum(value string) (hash.Hash, string, error) {
+	i := strings.Index(value, ":")
+	if i < 0 {
+		return nil, "", fmt.Errorf("invalid checksum %q", value)
+	}
+	hasher, err := newChecksumHash(value[:i])
+	if err != nil {
+		return nil, "", err
+	}
+	return hasher, strings.ToLower(value[i+1:]), nil
+}
@@ -2,31 +2,51 @@ package nectar
 
 import (
 	"bytes"
-	"encoding/csv"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"expvar"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/http/pprof"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/gholt/brimtext"
+	"github.com/troubling/nectar/nectarutil"
 )
 
-type CLIInstance struct {
-	Arg0 string
-
-	fatal    func(cli *CLIInstance, err error)
-	fatalf   func(cli *CLIInstance, frmt string, args ...interface{})
-	verbosef func(cli *CLIInstance, frmt string, args ...interface{})
+// cliGlobals are the global options of a CLIInstance and what is set up from
+// them, shared by every command it runs; see CLIInstance.Run.
+type cliGlobals struct {
+	buffers  *bufferPool
+	template *template.Template
+	// reportInterval is from -report-interval, or 0 if not given.
+	reportInterval time.Duration
+	// maxDuration is from -max-duration, or 0 if not given.
+	maxDuration time.Duration
+	// ctx is the run's context, ending at any -deadline; every request is
+	// made with it.
+	ctx context.Context
+	// progressEvents is from -progress-json, or nil if not given.
+	progressEvents *progressEvents
 
 	GlobalFlags               *flag.FlagSet
 	globalFlagAuthURL         *string
@@ -34,19 +54,81 @@ type CLIInstance struct {
 	globalFlagAuthUser        *string
 	globalFlagAuthKey         *string
 	globalFlagAuthPassword    *string
+	globalFlagAuthProvider    *string
 	globalFlagOverrideURLs    *string
 	globalFlagStorageRegion   *string
+	globalFlagAccount         *string
 	GlobalFlagVerbose         *bool
 	globalFlagContinueOnError *bool
 	globalFlagConcurrency     *int
+	globalFlagAutoConcurrency *bool
+	globalFlagBufferSize      *int
 	globalFlagInternalStorage *bool
 	globalFlagHeaders         stringListFlag
+	globalFlagContainerHeader stringListFlag
+	globalFlagQuery           stringListFlag
+	globalFlagRequestIDs      *bool
+	globalFlagRequestIDHeader *string
+	globalFlagLogFormat       *string
+	globalFlagBreakerFailures *int
+	globalFlagBreakerCooldown *string
+	globalFlagRetryBudget     *float64
+	globalFlagRetries         *int
+	globalFlagBackoff         *string
+	globalFlagKeepTokenFresh  *string
+	globalFlagStallTimeout    *string
+	globalFlagHTTP2           *bool
+	globalFlagDNSRefresh      *string
+	globalFlagIPv4            *bool
+	globalFlagIPv6            *bool
+	globalFlagCACert          *string
+	globalFlagCert            *string
+	globalFlagKey             *string
+	globalFlagInsecure        *bool
+	globalFlagTimeout         *string
+	globalFlagPlain           *bool
+	globalFlagFormat          *string
+	globalFlagJSON            *bool
+	globalFlagMetricsListen   *string
+	globalFlagDebugListen     *string
+	globalFlagReportInterval  *string
+	globalFlagMaxDuration     *string
+	globalFlagDeadline        *string
+	globalFlagProgressJSON    *string
+	// cancel releases ctx once the run is over.
+	cancel context.CancelFunc
+	// client is what commands are run against, once connected.
+	client Client
+}
+
+type CLIInstance struct {
+	Arg0 string
+
+	fatal    func(cli *CLIInstance, err error)
+	fatalf   func(cli *CLIInstance, frmt string, args ...interface{})
+	verbosef func(cli *CLIInstance, frmt string, args ...interface{})
+
+	*cliGlobals
+	// command is the command being run, if any, for its help text.
+	command *Command
+	// deadline is -max-duration from the start of the command being run,
+	// or zero if not given.
+	deadline time.Time
+
+	AuthFlags       *flag.FlagSet
+	authFlagAccount *bool
+	authFlagProject *string
+
+	BackupFlags    *flag.FlagSet
+	backupFlagName *string
+	backupFlagFull *bool
 
 	BenchDeleteFlags          *flag.FlagSet
 	benchDeleteFlagContainers *int
 	benchDeleteFlagCount      *int
 	benchDeleteFlagCSV        *string
 	benchDeleteFlagCSVOT      *string
+	benchDeleteFlagRate       *float64
 
 	BenchGetFlags          *flag.FlagSet
 	benchGetFlagContainers *int
@@ -75,16 +157,155 @@ type CLIInstance struct {
 	benchPostFlagCSV        *string
 	benchPostFlagCSVOT      *string
 
-	BenchPutFlags          *flag.FlagSet
-	benchPutFlagContainers *int
-	benchPutFlagCount      *int
-	benchPutFlagCSV        *string
-	benchPutFlagCSVOT      *string
-	benchPutFlagSize       *int
-	benchPutFlagMaxSize    *int
+	BenchPutFlags           *flag.FlagSet
+	benchPutFlagContainers  *int
+	benchPutFlagCount       *int
+	benchPutFlagCSV         *string
+	benchPutFlagCSVOT       *string
+	benchPutFlagSize        *int
+	benchPutFlagMaxSize     *int
+	benchPutFlagSizeDist    sizeDistributionFlag
+	benchPutFlagDeleteAfter *string
+
+	// These are shared by all the bench-* flagsets.
+	benchFlagPProfListen      string
+	benchFlagCPUProfile       string
+	benchFlagMemProfile       string
+	benchFlagResultsContainer string
+	benchFlagPhases           bool
+
+	CopyFlags             *flag.FlagSet
+	copyFlagRecursive     *bool
+	copyFlagFreshMetadata *bool
+
+	DirectFlags           *flag.FlagSet
+	directFlagRing        *string
+	directFlagServer      stringListFlag
+	directFlagPartition   *int64
+	directFlagPartPower   *int
+	directFlagHashPrefix  *string
+	directFlagHashSuffix  *string
+	directFlagConf        *string
+	directFlagAccount     *string
+	directFlagPolicyIndex *string
+	directFlagHead        *bool
+
+	DuFlags    *flag.FlagSet
+	duFlagAll  *bool
+	duFlagSort *string
+
+	DeleteFlags              *flag.FlagSet
+	deleteFlagManifestDelete *bool
+
+	MoveFlags         *flag.FlagSet
+	moveFlagRecursive *bool
+	moveFlagDryRun    *bool
+	moveFlagRate      *float64
+
+	DownloadFlags        *flag.FlagSet
+	downloadFlagAccount  *bool
+	downloadFlagParts    *int
+	downloadFlagState    *string
+	downloadFlagNoAtomic *bool
+	downloadFlagPlan     *bool
+	downloadFlagYes      *bool
+	downloadFlagMinSize  sizeFlag
+	downloadFlagMaxSize  sizeFlag
+	downloadFlagType     *string
+	downloadFlagPrefix   stringListFlag
+	downloadFlagPreserve *bool
+
+	downloadFlagEstimate           *bool
+	downloadFlagEstimateThroughput sizeFlag
+
+	ExportListingFlags      *flag.FlagSet
+	exportListingFlagFormat *string
+	exportListingFlagPrefix *string
+
+	HeadFlags        *flag.FlagSet
+	headFlagRaw      *bool
+	headFlagPolicies *bool
+
+	ImportCheckFlags      *flag.FlagSet
+	importCheckFlagFormat *string
+
+	InitFlags     *flag.FlagSet
+	initFlagForce *bool
+
+	PostFlags    *flag.FlagSet
+	postFlagMeta stringListFlag
+
+	PutFlags    *flag.FlagSet
+	putFlagMeta stringListFlag
+
+	RawFlags *flag.FlagSet
 
-	DownloadFlags       *flag.FlagSet
-	downloadFlagAccount *bool
+	SegmentsGCFlags                 *flag.FlagSet
+	segmentsGCFlagConfirm           *bool
+	segmentsGCFlagManifestContainer stringListFlag
+	segmentsGCFlagMinAge            *string
+
+	ShardsFlags *flag.FlagSet
+
+	SnapshotDiffFlags *flag.FlagSet
+
+	SnapshotListFlags      *flag.FlagSet
+	snapshotListFlagPrefix *string
+
+	// This is shared by the snapshot-* flagsets.
+	snapshotFlagObject bool
+
+	// These are shared by the copy and upload flagsets.
+	clobberFlagNoClobber    bool
+	clobberFlagIfUnmodified bool
+
+	// These are shared by the delete, get, head, post, and put flagsets.
+	expectFlagStatus  string
+	expectFlagHeaders stringListFlag
+
+	TagFlags      *flag.FlagSet
+	tagFlagAdd    stringListFlag
+	tagFlagRemove stringListFlag
+
+	RestoreFlags        *flag.FlagSet
+	restoreFlagManifest *string
+	restoreFlagList     *bool
+
+	TaggedFlags      *flag.FlagSet
+	taggedFlagPrefix *string
+
+	TempURLFlags       *flag.FlagSet
+	tempurlFlagMethod  *string
+	tempurlFlagExpires *string
+	tempurlFlagKey     *string
+	tempurlFlagDigest  *string
+	tempurlFlagPrefix  *bool
+
+	UploadFlags                  *flag.FlagSet
+	uploadFlagMeta               stringListFlag
+	uploadFlagState              *string
+	uploadFlagSkipUnchanged      *bool
+	uploadFlagTransform          transformFlag
+	uploadFlagWalkers            *int
+	uploadFlagQueue              *int
+	uploadFlagMetaSidecar        *string
+	uploadFlagMetaManifest       *string
+	uploadFlagChecksum           *string
+	uploadFlagPreserve           *bool
+	uploadFlagEstimate           *bool
+	uploadFlagEstimateThroughput sizeFlag
+	uploadFlagArchive            *bool
+	// uploadMetaManifest is loaded from -meta-manifest, if given.
+	uploadMetaManifest map[string]*fileMeta
+
+	uploadFlagSegmentSize      sizeFlag
+	uploadFlagSegmentContainer *string
+
+	VersioningFlags       *flag.FlagSet
+	versioningFlagEnable  *string
+	versioningFlagHistory *bool
+	versioningFlagDisable *bool
+	versioningFlagRestore *string
 
 	GetFlags         *flag.FlagSet
 	getFlagRaw       *bool
@@ -95,6 +316,7 @@ type CLIInstance struct {
 	getFlagLimit     *int
 	getFlagPrefix    *string
 	getFlagDelimiter *string
+	getFlagManifest  *bool
 }
 
 // CLI runs a nectar command-line-interface with the given args (args[0] should
@@ -102,6 +324,55 @@ type CLIInstance struct {
 // may be nil for the defaults. The default fatal and fatalf functions will
 // call os.Exit(1) after emitting error (or help) text.
 func CLI(args []string, fatal func(cli *CLIInstance, err error), fatalf func(cli *CLIInstance, frmt string, args ...interface{}), verbosef func(cli *CLIInstance, frmt string, args ...interface{})) {
+	cli := newCLIInstance(args[0], fatal, fatalf, verbosef, nil)
+	if err := cli.GlobalFlags.Parse(args[1:]); err != nil || len(cli.GlobalFlags.Args()) == 0 {
+		cli.fatal(cli, err)
+	}
+	cli.applyGlobalFlags()
+	defer cli.cancel()
+	// Help needs no authentication, whether from help or <subcommand> -h.
+	cli.command = lookupCommand(cli.GlobalFlags.Arg(0))
+	if cli.command != nil && cli.command.Flags != nil {
+		if err := cli.command.Flags(cli).Parse(cli.GlobalFlags.Args()[1:]); err == flag.ErrHelp {
+			cli.fatal(cli, err)
+		}
+	}
+	if cli.command == nil || !cli.command.NoAuth {
+		cli.connect()
+	}
+	cli.Run(cli.GlobalFlags.Args())
+}
+
+// NewCLI parses the global options in args (args[0] should have the name of
+// the executable) and authenticates, returning a CLIInstance whose Run
+// executes commands against the one authenticated client, without parsing the
+// global options or authenticating again for each; such as for a shell, or a
+// Go program driving nectar. Any command in args is ignored. The fatal,
+// fatalf, and verbosef parameters are as with CLI; note the defaults exit the
+// process, as will any command's failure then.
+func NewCLI(args []string, fatal func(cli *CLIInstance, err error), fatalf func(cli *CLIInstance, frmt string, args ...interface{}), verbosef func(cli *CLIInstance, frmt string, args ...interface{})) *CLIInstance {
+	cli := newCLIInstance(args[0], fatal, fatalf, verbosef, nil)
+	if err := cli.GlobalFlags.Parse(args[1:]); err != nil {
+		cli.fatal(cli, err)
+	}
+	cli.applyGlobalFlags()
+	cli.connect()
+	return cli
+}
+
+// Run executes the command given by args, such as
+// []string{"upload", "dir", "container"}, with the global options and client
+// of the CLIInstance. Run is safe to call concurrently: each call parses its
+// command's options into flag sets of its own, sharing only the global
+// options, which are never modified once parsed, and the client.
+func (cli *CLIInstance) Run(args []string) {
+	newCLIInstance(cli.Arg0, cli.fatal, cli.fatalf, cli.verbosef, cli.cliGlobals).run(args)
+}
+
+// newCLIInstance returns a CLIInstance with its command flag sets defined,
+// sharing the globals given, or with new globals and their flag set defined
+// if nil.
+func newCLIInstance(arg0 string, fatal func(cli *CLIInstance, err error), fatalf func(cli *CLIInstance, frmt string, args ...interface{}), verbosef func(cli *CLIInstance, frmt string, args ...interface{}), globals *cliGlobals) *CLIInstance {
 	if fatal == nil {
 		fatal = cliFatal
 	}
@@ -111,79 +382,207 @@ func CLI(args []string, fatal func(cli *CLIInstance, err error), fatalf func(cli
 	if verbosef == nil {
 		verbosef = cliVerbosef
 	}
-	cli := &CLIInstance{Arg0: args[0], fatal: fatal, fatalf: fatalf, verbosef: verbosef}
-	var flagbuf bytes.Buffer
+	cli := &CLIInstance{Arg0: arg0, fatal: fatal, fatalf: fatalf, verbosef: verbosef, cliGlobals: globals}
+	flagbuf := &bytes.Buffer{}
+	if cli.cliGlobals == nil {
+		cli.cliGlobals = &cliGlobals{ctx: context.Background(), cancel: func() {}}
+		cli.defineGlobalFlags(flagbuf)
+	}
+	cli.defineFlags(flagbuf)
+	return cli
+}
 
+// defineGlobalFlags defines the global flag set.
+func (cli *CLIInstance) defineGlobalFlags(flagbuf *bytes.Buffer) {
 	cli.GlobalFlags = flag.NewFlagSet(cli.Arg0, flag.ContinueOnError)
-	cli.GlobalFlags.SetOutput(&flagbuf)
+	cli.GlobalFlags.SetOutput(flagbuf)
 	cli.globalFlagAuthURL = cli.GlobalFlags.String("A", os.Getenv("AUTH_URL"), "|<url>| URL to auth system, example: http://127.0.0.1:8080/auth/v1.0 - Env: AUTH_URL")
 	cli.globalFlagAuthTenant = cli.GlobalFlags.String("T", os.Getenv("AUTH_TENANT"), "|<tenant>| Tenant name for auth system, example: test - Not all auth systems need this. Env: AUTH_TENANT")
 	cli.globalFlagAuthUser = cli.GlobalFlags.String("U", os.Getenv("AUTH_USER"), "|<user>| User name for auth system, example: tester - Some auth systems allow tenant:user format here, example: test:tester - Env: AUTH_USER")
 	cli.globalFlagAuthKey = cli.GlobalFlags.String("K", os.Getenv("AUTH_KEY"), "|<key>| Key for auth system, example: testing - Some auth systems use passwords instead, see -P - Env: AUTH_KEY")
 	cli.globalFlagAuthPassword = cli.GlobalFlags.String("P", os.Getenv("AUTH_PASSWORD"), "|<password>| Password for auth system, example: testing - Some auth system use keys instead, see -K - Env: AUTH_PASSWORD")
+	cli.globalFlagAuthProvider = cli.GlobalFlags.String("auth-provider", os.Getenv("AUTH_PROVIDER"), "|<name>| The auth provider to use: v1, v2, or v3 (Keystone), or one registered by a program embedding nectar; by default it is chosen from the auth URL. Env: AUTH_PROVIDER")
 	cli.globalFlagOverrideURLs = cli.GlobalFlags.String("O", os.Getenv("OVERRIDE_URLS"), "|<url> [url] ...| Override URLs for service endpoint(s); the service endpoint given by auth will be ignored - Env: OVERRIDE_URLS")
 	cli.globalFlagStorageRegion = cli.GlobalFlags.String("R", os.Getenv("STORAGE_REGION"), "|<region>| Storage region to use if set, otherwise uses the default. Env: STORAGE_REGION")
+	cli.globalFlagAccount = cli.GlobalFlags.String("account", "", "|<account>| Targets this account, such as AUTH_xyz, in place of the account in the storage URL given by auth; for operators with reseller admin or service tokens valid for other accounts.")
+	cli.globalFlagCACert = cli.GlobalFlags.String("cacert", os.Getenv("NECTAR_CACERT"), "|<file>| Trusts the certificate authorities in this PEM file, as well as the system's, for https endpoints; such as for private clusters with an internal CA. Env: NECTAR_CACERT")
+	cli.globalFlagCert = cli.GlobalFlags.String("cert", os.Getenv("NECTAR_CERT"), "|<file>| Presents the client certificate in this PEM file to https endpoints; the key may be in the same file, or see -key. Env: NECTAR_CERT")
+	cli.globalFlagKey = cli.GlobalFlags.String("key", os.Getenv("NECTAR_KEY"), "|<file>| The PEM file with the private key for -cert. Env: NECTAR_KEY")
+	b, _ := strconv.ParseBool(os.Getenv("NECTAR_INSECURE"))
+	cli.globalFlagInsecure = cli.GlobalFlags.Bool("insecure", b, "Does not verify the certificates of https endpoints; for testing only. Env: NECTAR_INSECURE")
+	cli.globalFlagTimeout = cli.GlobalFlags.String("timeout", "30m", "|<timespan>| The overall time limit for each request, including transferring its content; 0 for no limit.")
 	cli.GlobalFlagVerbose = cli.GlobalFlags.Bool("v", false, "Will activate verbose output.")
 	cli.globalFlagContinueOnError = cli.GlobalFlags.Bool("continue-on-error", false, "When possible, continue with additional operations even if one or more fail.")
 	i32, _ := strconv.ParseInt(os.Getenv("CONCURRENCY"), 10, 32)
 	cli.globalFlagConcurrency = cli.GlobalFlags.Int("C", int(i32), "|<number>| The maximum number of concurrent operations to perform; default is 1. Env: CONCURRENCY")
-	b, _ := strconv.ParseBool(os.Getenv("STORAGE_INTERNAL"))
+	cli.globalFlagAutoConcurrency = cli.GlobalFlags.Bool("auto-concurrency", false, "For upload, download, and the bench commands, starts with a concurrency of 1 and adjusts it based on observed latency and errors, never exceeding -C (which defaults to 64 with this option).")
+	cli.globalFlagBufferSize = cli.GlobalFlags.Int("buffer-size", 64*1024, "|<bytes>| The size of the pooled buffers used when copying downloaded content.")
+	b, _ = strconv.ParseBool(os.Getenv("STORAGE_INTERNAL"))
 	cli.globalFlagInternalStorage = cli.GlobalFlags.Bool("I", b, "Internal storage URL resolution, such as Rackspace ServiceNet. Env: STORAGE_INTERNAL")
+	for _, header := range strings.Split(os.Getenv("CONTAINER_HEADERS"), ";") {
+		if strings.TrimSpace(header) != "" {
+			cli.globalFlagContainerHeader = append(cli.globalFlagContainerHeader, header)
+		}
+	}
+	cli.GlobalFlags.Var(&cli.globalFlagContainerHeader, "container-header", "|<name>:[value]| Sets a header to be sent only when creating a container that does not yet exist, with put, upload, copy, move, and the benches, such as X-Storage-Policy: gold or X-Container-Read: .r:*; existing containers are left as they are. This option can be specified multiple times for additional headers, and -H takes precedence. Env: CONTAINER_HEADERS, as a ; separated list.")
 	cli.GlobalFlags.Var(&cli.globalFlagHeaders, "H", "|<name>:[value]| Sets a header to be sent with the request. Useful mostly for PUTs and POSTs, allowing you to set metadata. This option can be specified multiple times for additional headers.")
+	cli.GlobalFlags.Var(&cli.globalFlagQuery, "Q", "|<name>=[value]| Sets a query parameter to be appended to request URLs. Useful for accessing middleware features not otherwise supported, such as format=xml. This option can be specified multiple times for additional parameters.")
+	cli.globalFlagRequestIDs = cli.GlobalFlags.Bool("request-ids", false, "Stamps every request with a client-generated ID, the run ID followed by a sequence number, which will also be emitted alongside the X-Trans-Id in verbose output; useful for correlating client and server logs.")
+	cli.globalFlagBreakerFailures = cli.GlobalFlags.Int("breaker-failures", 0, "|<number>| The number of consecutive failures (transport errors or 5xx responses) with a service endpoint before its circuit breaker opens, stopping requests to that endpoint for the -breaker-cooldown; the default of 0 disables the circuit breaker.")
+	cli.globalFlagBreakerCooldown = cli.GlobalFlags.String("breaker-cooldown", "30s", "|<timespan>| How long an endpoint's circuit breaker stays open before a probe request is allowed through.")
+	cli.globalFlagRetryBudget = cli.GlobalFlags.Float64("retry-budget", 0, "|<ratio>| Allows requests failing against one service endpoint to be retried against another, limited to this ratio of all requests made (plus a small reserve), such as 0.1 for 10%; the default of 0 disables these retries.")
+	cli.globalFlagRetries = cli.GlobalFlags.Int("retries", 0, "|<number>| Retries each request failing with a transport error or a 408, 429, or 5xx response up to this many times, waiting -backoff before the first retry and doubling the wait with each retry after, up to a minute, with up to half of each wait randomized; a Retry-After given with the response is honored if longer. Uploads are rewound for each retry, though standard input cannot be. With -retry-budget, these retries also draw on the budget.")
+	cli.globalFlagBackoff = cli.GlobalFlags.String("backoff", "1s", "|<timespan>| How long to wait before the first of the -retries.")
+	cli.globalFlagKeepTokenFresh = cli.GlobalFlags.String("keep-token-fresh", "", "|<timespan>| Renews the auth token in the background this long, such as 10m, before it expires, so long-running benches and transfers never hit a window of 401s as the token expires under load. With auth that does not say when tokens expire, the token is renewed every <timespan> instead.")
+	cli.globalFlagMaxDuration = cli.GlobalFlags.String("max-duration", "", "|<timespan>| Stops upload, download, copy -r, move -r, and the benches from starting new work once <timespan>, such as 2h, has passed since starting, as if interrupted: the requests in flight are finished, the summary of the work completed is output, and the exit status is 1. With -state, rerunning the same upload or download resumes where it stopped.")
+	cli.globalFlagDeadline = cli.GlobalFlags.String("deadline", "", "|<timespan>| Ends the whole run once <timespan>, such as 2h, has passed since starting: unlike -max-duration, the requests in flight are cancelled too, including any retries and waits between them, so every request of upload, download, copy, move, backup, restore, the benches, and every other command shares the one deadline; each request still has its own -timeout.")
+	cli.globalFlagProgressJSON = cli.GlobalFlags.String("progress-json", "", "|<destination>| Emits machine-readable upload and download progress as one JSON event per line: started, completed, skipped, and failed events for each object, with its path, container, object, and bytes; progress events with the running totals at each -report-interval; and a finished event with the final totals. The <destination> can be fd:<n> for an open file descriptor, unix:<path> or tcp:<host:port> for a socket to connect to, or a file path to create.")
+	cli.globalFlagReportInterval = cli.GlobalFlags.String("report-interval", "", "|<timespan>| How often progress is reported, such as 5s: the progress lines and -csvot rows of benches (default 1m), copy and move progress (default 10s), and download -plan progress (default 1s); upload and download also report progress when this is given.")
+	cli.globalFlagHTTP2 = cli.GlobalFlags.Bool("http2", false, "Offers HTTP/2 to https endpoints, using it where the server agrees, so requests are multiplexed over fewer connections; otherwise HTTP/1.1 is always used. With -v, each request's protocol is output if not HTTP/1.1, as is how many connections negotiated HTTP/2, and the bench -csv files give each request's protocol, for measuring whether HTTP/2 helps or hurts with a given proxy tier.")
+	cli.globalFlagDNSRefresh = cli.GlobalFlags.String("dns-refresh", "", "|<timespan>| Spreads new connections across all the addresses the storage host resolves to, in turn, and resolves it again this often, such as 1m, closing idle connections should the addresses change; so long benches behind round-robin DNS don't pin every connection to the single address resolved at startup.")
+	cli.globalFlagIPv4 = cli.GlobalFlags.Bool("4", false, "Connects only over IPv4; otherwise a host's IPv4 and IPv6 addresses are both tried, racing the two families as in happy eyeballs, and whichever connects first is used. With -v, the address of each request's connection is output, for debugging dual-stack clusters.")
+	cli.globalFlagIPv6 = cli.GlobalFlags.Bool("6", false, "Connects only over IPv6; see -4.")
+	cli.globalFlagStallTimeout = cli.GlobalFlags.String("stall-timeout", "", "|<timespan>| Aborts and retries any transfer where no bytes have moved for this long, such as 30s; stalled downloads resume from where they left off. Unlike the overall request timeout, this protects long transfers from half-dead connections that never error out.")
+	cli.globalFlagPlain = cli.GlobalFlags.Bool("plain", false, "Outputs listings and headers as tab separated values rather than aligned columns, for easier use with tools like cut and awk.")
+	cli.globalFlagFormat = cli.GlobalFlags.String("format", "", "|<template>| Outputs each listing entry, or the head information, using the Go text/template, such as '{{.Name}} {{.Bytes}}'. Container listing entries have Name, Bytes, ContentType, LastModified, Hash, and Subdir fields; account listing entries have Name, Count, Bytes, LastModified, StoragePolicy, and Subdir; head information has StatusCode, Status, Header, and Metadata.")
+	cli.globalFlagMetricsListen = cli.GlobalFlags.String("metrics-listen", "", "|<address>| Serves Prometheus metrics at http://<address>/metrics while running, such as :9100, with request counts, error counts, bytes transferred, and latency histograms by method; mostly useful for long running benches and transfers.")
+	cli.globalFlagDebugListen = cli.GlobalFlags.String("debug-listen", "", "|<address>| Serves internal counters, such as active requests, retries, stalls, authentications, and buffer pool usage, as expvar JSON at http://<address>/debug/vars while running; useful for inspecting a stuck transfer.")
+	cli.globalFlagJSON = cli.GlobalFlags.Bool("json", false, "Outputs listings and head information as JSON, with the same fields as available to -format.")
+	cli.globalFlagLogFormat = cli.GlobalFlags.String("log-format", "text", "|<format>| The format for verbose output: text, json, or logfmt; the latter two emit one structured event per line for ingestion by log pipelines.")
+	cli.globalFlagRequestIDHeader = cli.GlobalFlags.String("request-id-header", "X-Trans-Id-Extra", "|<name>| The header to use for -request-ids.")
+}
+
+// defineFlags defines the flag sets of the commands.
+func (cli *CLIInstance) defineFlags(flagbuf *bytes.Buffer) {
+	cli.BackupFlags = flag.NewFlagSet("backup", flag.ContinueOnError)
+	cli.BackupFlags.SetOutput(flagbuf)
+	cli.backupFlagName = cli.BackupFlags.String("name", "", "|<name>| The backup name, under which the backups of the same directory are kept; the default is the directory's base name.")
+	cli.backupFlagFull = cli.BackupFlags.Bool("full", false, "Uploads every file rather than reusing the objects of files unchanged since the previous backup.")
 
 	cli.BenchDeleteFlags = flag.NewFlagSet("bench-delete", flag.ContinueOnError)
-	cli.BenchDeleteFlags.SetOutput(&flagbuf)
-	cli.benchDeleteFlagContainers = cli.BenchDeleteFlags.Int("containers", 1, "|<number>| Number of containers in use.")
+	cli.BenchDeleteFlags.SetOutput(flagbuf)
+	cli.benchDeleteFlagContainers = cli.BenchDeleteFlags.Int("containers", 1, "|<number>| Number of containers in use; the number of each is appended to <container>, or replaces a {n} placeholder within it, such as perfdata-{n}-2024.")
 	cli.benchDeleteFlagCount = cli.BenchDeleteFlags.Int("count", 1000, "|<number>| Number of objects to delete, distributed across containers.")
-	cli.benchDeleteFlagCSV = cli.BenchDeleteFlags.String("csv", "", "|<filename>| Store the timing of each delete into a CSV file.")
-	cli.benchDeleteFlagCSVOT = cli.BenchDeleteFlags.String("csvot", "", "|<filename>| Store the number of deletes performed over time into a CSV file.")
+	cli.benchDeleteFlagCSV = cli.BenchDeleteFlags.String("csv", "", "|<filename>| Store the timing of each delete into a CSV file; a filename ending in .gz will be gzip compressed.")
+	cli.benchDeleteFlagCSVOT = cli.BenchDeleteFlags.String("csvot", "", "|<filename>| Store the number of deletes performed over time into a CSV file; a filename ending in .gz will be gzip compressed.")
+	cli.benchDeleteFlagRate = cli.BenchDeleteFlags.Float64("delete-rate", 0, "|<number>| Limits the deletes to <number> per second in total, such as 0.5 for one every two seconds, so cleaning up a large bench can be spread over hours rather than overwhelming the container servers and replicators.")
 
 	cli.BenchGetFlags = flag.NewFlagSet("bench-get", flag.ContinueOnError)
-	cli.BenchGetFlags.SetOutput(&flagbuf)
-	cli.benchGetFlagContainers = cli.BenchGetFlags.Int("containers", 1, "|<number>| Number of containers to use.")
+	cli.BenchGetFlags.SetOutput(flagbuf)
+	cli.benchGetFlagContainers = cli.BenchGetFlags.Int("containers", 1, "|<number>| Number of containers to use; the number of each is appended to <container>, or replaces a {n} placeholder within it, such as perfdata-{n}-2024.")
 	cli.benchGetFlagCount = cli.BenchGetFlags.Int("count", 1000, "|<number>| Number of objects to get, distributed across containers.")
-	cli.benchGetFlagCSV = cli.BenchGetFlags.String("csv", "", "|<filename>| Store the timing of each get into a CSV file.")
-	cli.benchGetFlagCSVOT = cli.BenchGetFlags.String("csvot", "", "|<filename>| Store the number of gets performed over time into a CSV file.")
+	cli.benchGetFlagCSV = cli.BenchGetFlags.String("csv", "", "|<filename>| Store the timing of each get into a CSV file; a filename ending in .gz will be gzip compressed.")
+	cli.benchGetFlagCSVOT = cli.BenchGetFlags.String("csvot", "", "|<filename>| Store the number of gets performed over time into a CSV file; a filename ending in .gz will be gzip compressed.")
 	cli.benchGetFlagIterations = cli.BenchGetFlags.Int("iterations", 1, "|<number>| Number of iterations to perform.")
 
+	cli.AuthFlags = flag.NewFlagSet("auth", flag.ContinueOnError)
+	cli.AuthFlags.SetOutput(flagbuf)
+	cli.authFlagAccount = cli.AuthFlags.Bool("v", false, "Also HEAD the account and display an overview of its container, object, and byte counts, quotas, temp URL keys, and metadata.")
+	cli.authFlagProject = cli.AuthFlags.String("project", "", "|<name>| Re-scopes the token to this Keystone project (tenant) and displays the new Account URL and token; the credentials are not sent again. Requires Keystone auth v2 or v3.")
+
 	cli.BenchHeadFlags = flag.NewFlagSet("bench-head", flag.ContinueOnError)
-	cli.BenchHeadFlags.SetOutput(&flagbuf)
-	cli.benchHeadFlagContainers = cli.BenchHeadFlags.Int("containers", 1, "|<number>| Number of containers to use.")
+	cli.BenchHeadFlags.SetOutput(flagbuf)
+	cli.benchHeadFlagContainers = cli.BenchHeadFlags.Int("containers", 1, "|<number>| Number of containers to use; the number of each is appended to <container>, or replaces a {n} placeholder within it, such as perfdata-{n}-2024.")
 	cli.benchHeadFlagCount = cli.BenchHeadFlags.Int("count", 1000, "|<number>| Number of objects to head, distributed across containers.")
-	cli.benchHeadFlagCSV = cli.BenchHeadFlags.String("csv", "", "|<filename>| Store the timing of each head into a CSV file.")
-	cli.benchHeadFlagCSVOT = cli.BenchHeadFlags.String("csvot", "", "|<filename>| Store the number of heads performed over time into a CSV file.")
+	cli.benchHeadFlagCSV = cli.BenchHeadFlags.String("csv", "", "|<filename>| Store the timing of each head into a CSV file; a filename ending in .gz will be gzip compressed.")
+	cli.benchHeadFlagCSVOT = cli.BenchHeadFlags.String("csvot", "", "|<filename>| Store the number of heads performed over time into a CSV file; a filename ending in .gz will be gzip compressed.")
 	cli.benchHeadFlagIterations = cli.BenchHeadFlags.Int("iterations", 1, "|<number>| Number of iterations to perform.")
 
 	cli.BenchMixedFlags = flag.NewFlagSet("bench-mixed", flag.ContinueOnError)
-	cli.BenchMixedFlags.SetOutput(&flagbuf)
-	cli.benchMixedFlagContainers = cli.BenchMixedFlags.Int("containers", 1, "|<number>| Number of containers to use.")
-	cli.benchMixedFlagCSV = cli.BenchMixedFlags.String("csv", "", "|<filename>| Store the timing of each request into a CSV file.")
-	cli.benchMixedFlagCSVOT = cli.BenchMixedFlags.String("csvot", "", "|<filename>| Store the number of requests performed over time into a CSV file.")
+	cli.BenchMixedFlags.SetOutput(flagbuf)
+	cli.benchMixedFlagContainers = cli.BenchMixedFlags.Int("containers", 1, "|<number>| Number of containers to use; the number of each is appended to <container>, or replaces a {n} placeholder within it, such as perfdata-{n}-2024.")
+	cli.benchMixedFlagCSV = cli.BenchMixedFlags.String("csv", "", "|<filename>| Store the timing of each request into a CSV file; a filename ending in .gz will be gzip compressed.")
+	cli.benchMixedFlagCSVOT = cli.BenchMixedFlags.String("csvot", "", "|<filename>| Store the number of requests performed over time into a CSV file; a filename ending in .gz will be gzip compressed.")
 	cli.benchMixedFlagSize = cli.BenchMixedFlags.Int("size", 4096, "|<bytes>| Number of bytes for each object.")
 	cli.benchMixedFlagTime = cli.BenchMixedFlags.String("time", "10m", "|<timespan>| Amount of time to run the test, such as 10m or 1h.")
 
 	cli.BenchPostFlags = flag.NewFlagSet("bench-post", flag.ContinueOnError)
-	cli.BenchPostFlags.SetOutput(&flagbuf)
-	cli.benchPostFlagContainers = cli.BenchPostFlags.Int("containers", 1, "|<number>| Number of containers in use.")
+	cli.BenchPostFlags.SetOutput(flagbuf)
+	cli.benchPostFlagContainers = cli.BenchPostFlags.Int("containers", 1, "|<number>| Number of containers in use; the number of each is appended to <container>, or replaces a {n} placeholder within it, such as perfdata-{n}-2024.")
 	cli.benchPostFlagCount = cli.BenchPostFlags.Int("count", 1000, "|<number>| Number of objects to post, distributed across containers.")
-	cli.benchPostFlagCSV = cli.BenchPostFlags.String("csv", "", "|<filename>| Store the timing of each post into a CSV file.")
-	cli.benchPostFlagCSVOT = cli.BenchPostFlags.String("csvot", "", "|<filename>| Store the number of posts performed over time into a CSV file.")
+	cli.benchPostFlagCSV = cli.BenchPostFlags.String("csv", "", "|<filename>| Store the timing of each post into a CSV file; a filename ending in .gz will be gzip compressed.")
+	cli.benchPostFlagCSVOT = cli.BenchPostFlags.String("csvot", "", "|<filename>| Store the number of posts performed over time into a CSV file; a filename ending in .gz will be gzip compressed.")
 
 	cli.BenchPutFlags = flag.NewFlagSet("bench-put", flag.ContinueOnError)
-	cli.BenchPutFlags.SetOutput(&flagbuf)
-	cli.benchPutFlagContainers = cli.BenchPutFlags.Int("containers", 1, "|<number>| Number of containers to use.")
+	cli.BenchPutFlags.SetOutput(flagbuf)
+	cli.benchPutFlagContainers = cli.BenchPutFlags.Int("containers", 1, "|<number>| Number of containers to use; the number of each is appended to <container>, or replaces a {n} placeholder within it, such as perfdata-{n}-2024.")
 	cli.benchPutFlagCount = cli.BenchPutFlags.Int("count", 1000, "|<number>| Number of objects to PUT, distributed across containers.")
-	cli.benchPutFlagCSV = cli.BenchPutFlags.String("csv", "", "|<filename>| Store the timing of each PUT into a CSV file.")
-	cli.benchPutFlagCSVOT = cli.BenchPutFlags.String("csvot", "", "|<filename>| Store the number of PUTs performed over time into a CSV file.")
+	cli.benchPutFlagCSV = cli.BenchPutFlags.String("csv", "", "|<filename>| Store the timing of each PUT into a CSV file; a filename ending in .gz will be gzip compressed.")
+	cli.benchPutFlagCSVOT = cli.BenchPutFlags.String("csvot", "", "|<filename>| Store the number of PUTs performed over time into a CSV file; a filename ending in .gz will be gzip compressed.")
 	cli.benchPutFlagSize = cli.BenchPutFlags.Int("size", 4096, "|<bytes>| Number of bytes for each object.")
 	cli.benchPutFlagMaxSize = cli.BenchPutFlags.Int("maxsize", 0, "|<bytes>| This option will vary object sizes randomly between -size and -maxsize")
+	cli.BenchPutFlags.Var(&cli.benchPutFlagSizeDist, "size-distribution", "|<size>:<weight>,...| Chooses each object's size from the weighted sizes given, such as 4k:70,1m:25,100m:5 for 70% 4 KiB, 25% 1 MiB, and 5% 100 MiB objects; overrides -size and -maxsize. Sizes are as with download -min-size.")
+	cli.benchPutFlagDeleteAfter = cli.BenchPutFlags.String("delete-after", "", "|<timespan>| Sets X-Delete-After on each object so the object expirer removes the benchmark data after <timespan>, such as 24h, even if bench-delete is never run.")
+
+	for _, flags := range []*flag.FlagSet{cli.BenchDeleteFlags, cli.BenchGetFlags, cli.BenchHeadFlags, cli.BenchMixedFlags, cli.BenchPostFlags, cli.BenchPutFlags} {
+		flags.StringVar(&cli.benchFlagPProfListen, "pprof-listen", "", "|<address>| Serves the Go pprof endpoints at http://<address>/debug/pprof/ during the run, for diagnosing client-side bottlenecks.")
+		flags.StringVar(&cli.benchFlagCPUProfile, "cpuprofile", "", "|<filename>| Writes a CPU profile of the run to the file, for use with go tool pprof.")
+		flags.StringVar(&cli.benchFlagResultsContainer, "results-container", "", "|<container>| Stores the CSV files and a JSON report of the run as objects named <timestamp>-<run-id>/<file> in the container, to accumulate a history of results in the cluster itself.")
+		flags.BoolVar(&cli.benchFlagPhases, "phases", false, "Adds columns to the -csv file for the DNS, connect, TLS, request write, and time to first byte phases of each request, in nanoseconds, to tell network setup from server processing; the first three are 0 when a kept-alive connection was reused.")
+		flags.StringVar(&cli.benchFlagMemProfile, "memprofile", "", "|<filename>| Writes a heap profile to the file at the end of the run, for use with go tool pprof.")
+	}
+
+	cli.CopyFlags = flag.NewFlagSet("copy", flag.ContinueOnError)
+	cli.CopyFlags.SetOutput(flagbuf)
+	cli.copyFlagFreshMetadata = cli.CopyFlags.Bool("fresh-metadata", false, "Leaves the source's metadata behind, so the copies have only the metadata given with -H, using X-Fresh-Metadata.")
+	cli.copyFlagRecursive = cli.CopyFlags.Bool("r", false, "Copies every object whose name begins with the source object name, treating it as a prefix such as a pseudo-directory; the prefix is replaced with the destination object name.")
+
+	cli.DeleteFlags = flag.NewFlagSet("delete", flag.ContinueOnError)
+	cli.DeleteFlags.SetOutput(flagbuf)
+	cli.deleteFlagManifestDelete = cli.DeleteFlags.Bool("manifest-delete", false, "When deleting a static large object, deletes its segments as well as the manifest, using ?multipart-manifest=delete")
+
+	cli.DirectFlags = flag.NewFlagSet("direct", flag.ContinueOnError)
+	cli.DirectFlags.SetOutput(flagbuf)
+	cli.directFlagRing = cli.DirectFlags.String("ring", "", "|<path>| The object ring file, such as /etc/swift/object.ring.gz, in the R1NG format, used to find the partition and the object servers holding its primary copies.")
+	cli.DirectFlags.Var(&cli.directFlagServer, "server", "|<url>| An object server device to request the object from directly, as http://<host>:<port>/<device>, in addition to any found with -ring. This option can be specified multiple times for additional devices.")
+	cli.directFlagPartition = cli.DirectFlags.Int64("partition", -1, "|<number>| The object's partition, rather than calculating it from the ring or -part-power.")
+	cli.directFlagPartPower = cli.DirectFlags.Int("part-power", 0, "|<number>| The ring's partition power, for calculating the partition without -ring.")
+	cli.directFlagHashPrefix = cli.DirectFlags.String("hash-prefix", "", "|<text>| The cluster's swift_hash_path_prefix, for calculating the partition.")
+	cli.directFlagHashSuffix = cli.DirectFlags.String("hash-suffix", "", "|<text>| The cluster's swift_hash_path_suffix, for calculating the partition.")
+	cli.directFlagConf = cli.DirectFlags.String("conf", "", "|<path>| A config file, such as /etc/swift/swift.conf or /etc/hummingbird/hummingbird.conf, to read the hash path prefix and suffix from when -hash-prefix and -hash-suffix are not given.")
+	cli.directFlagAccount = cli.DirectFlags.String("account", "", "|<name>| The account, such as AUTH_test, as known to the object servers; the default is the last part of the storage URL.")
+	cli.directFlagPolicyIndex = cli.DirectFlags.String("policy-index", "", "|<number>| Sends X-Backend-Storage-Policy-Index to the object servers, for containers not in the default storage policy.")
+	cli.directFlagHead = cli.DirectFlags.Bool("head", false, "HEADs the object rather than GETting it.")
+
+	cli.DuFlags = flag.NewFlagSet("du", flag.ContinueOnError)
+	cli.DuFlags.SetOutput(flagbuf)
+	cli.duFlagAll = cli.DuFlags.Bool("a", false, "Includes every container in the account, the account listing being paged through as needed.")
+	cli.duFlagSort = cli.DuFlags.String("sort", "bytes", "|<field>| Sorts the containers by bytes or objects, largest first, or by name.")
+
+	cli.MoveFlags = flag.NewFlagSet("move", flag.ContinueOnError)
+	cli.MoveFlags.SetOutput(flagbuf)
+	cli.moveFlagRecursive = cli.MoveFlags.Bool("r", false, "Moves every object whose name begins with the source object name, treating it as a prefix such as a pseudo-directory; the prefix is replaced with the destination object name.")
+	cli.moveFlagDryRun = cli.MoveFlags.Bool("dry-run", false, "Lists what would be moved without actually moving anything.")
+	cli.moveFlagRate = cli.MoveFlags.Float64("delete-rate", 0, "|<number>| With -r, limits the deletions of the sources to <number> per second in total, such as 0.5 for one every two seconds, so moving many objects does not overwhelm the container servers and replicators; the copies are paced along with them.")
 
 	cli.DownloadFlags = flag.NewFlagSet("download", flag.ContinueOnError)
-	cli.DownloadFlags.SetOutput(&flagbuf)
+	cli.DownloadFlags.SetOutput(flagbuf)
 	cli.downloadFlagAccount = cli.DownloadFlags.Bool("a", false, "Indicates you truly wish to download the entire account; this is to prevent accidentally doing so when giving a single parameter to download.")
+	cli.downloadFlagNoAtomic = cli.DownloadFlags.Bool("no-atomic", false, "Writes directly to the destination files rather than to temporary .nectar-tmp files renamed into place once verified; useful on filesystems where renames are costly.")
+	cli.downloadFlagParts = cli.DownloadFlags.Int("parts", 1, "|<number>| Downloads each object of at least <number> MiB as that many ranges concurrently, writing each range in place within the preallocated file.")
+	cli.DownloadFlags.Var(&cli.downloadFlagMinSize, "min-size", "|<size>| Skips listed objects smaller than <size>, such as 100 or 10MiB; see -max-size.")
+	cli.DownloadFlags.Var(&cli.downloadFlagMaxSize, "max-size", "|<size>| Skips listed objects larger than <size>. Sizes may be given in bytes or with a K, M, G, T, or P suffix, optionally followed by iB or B, all multiples of 1024. Objects are sized as listed, so a large object is sized by its manifest, and an object named explicitly is always downloaded.")
+	cli.downloadFlagType = cli.DownloadFlags.String("content-type", "", "|<type>| Skips listed objects whose content type does not match <type>, either exactly or as a glob pattern such as image/*.")
+	cli.DownloadFlags.Var(&cli.downloadFlagPrefix, "prefix", "|<text>| Only downloads listed objects whose names begin with <text>. This option can be specified multiple times; each prefix is listed separately and concurrently, so only the matching parts of a container are scanned.")
+	cli.downloadFlagEstimate = cli.DownloadFlags.Bool("estimate", false, "Reports the number of objects, bytes, and requests the download would involve, and about how long it would take at the -C concurrency, without downloading anything; only the listings are read and the objects named explicitly HEADed, so large objects are sized by their manifests.")
+	cli.downloadFlagEstimateThroughput = defaultEstimateThroughput
+	cli.DownloadFlags.Var(&cli.downloadFlagEstimateThroughput, "estimate-throughput", "|<size>| The throughput per connection, such as 50MiB, per second that -estimate assumes.")
+	cli.downloadFlagPlan = cli.DownloadFlags.Bool("plan", false, "HEADs every object concurrently before downloading to report the total size and ask for confirmation; aggregate progress is then reported every second.")
+	cli.downloadFlagYes = cli.DownloadFlags.Bool("y", false, "Proceeds with a -plan download without asking for confirmation.")
+	cli.downloadFlagPreserve = cli.DownloadFlags.Bool("preserve", false, "Restores the file mode, owner, and symlinks recorded by upload -preserve, as best it can: a mode or owner that cannot be set, such as an owner when not running as root, is only reported with -v.")
+	cli.downloadFlagState = cli.DownloadFlags.String("state", "", "|<file>| Records each completed download in <file>; rerunning with the same <file> skips objects already downloaded.")
+
+	cli.ExportListingFlags = flag.NewFlagSet("export-listing", flag.ContinueOnError)
+	cli.ExportListingFlags.SetOutput(flagbuf)
+	cli.exportListingFlagFormat = cli.ExportListingFlags.String("format", "", "|<format>| Writes the listing as json or csv; the default is csv for a <file> ending in .csv and json otherwise.")
+	cli.exportListingFlagPrefix = cli.ExportListingFlags.String("prefix", "", "|<text>| Only includes objects whose names begin with <text>.")
+
+	cli.ImportCheckFlags = flag.NewFlagSet("import-check", flag.ContinueOnError)
+	cli.ImportCheckFlags.SetOutput(flagbuf)
+	cli.importCheckFlagFormat = cli.ImportCheckFlags.String("format", "", "|<format>| Reads the listing as json or csv; the default is csv for a <file> ending in .csv and json otherwise.")
 
 	cli.GetFlags = flag.NewFlagSet("get", flag.ContinueOnError)
-	cli.GetFlags.SetOutput(&flagbuf)
+	cli.GetFlags.SetOutput(flagbuf)
 	cli.getFlagRaw = cli.GetFlags.Bool("r", false, "Emit raw results")
 	cli.getFlagNameOnly = cli.GetFlags.Bool("n", false, "In listings, emits the names only")
 	cli.getFlagMarker = cli.GetFlags.String("marker", "", "|<text>| In listings, sets the start marker")
@@ -192,10 +591,158 @@ func CLI(args []string, fatal func(cli *CLIInstance, err error), fatalf func(cli
 	cli.getFlagLimit = cli.GetFlags.Int("limit", 0, "|<number>| In listings, limits the results")
 	cli.getFlagPrefix = cli.GetFlags.String("prefix", "", "|<text>| In listings, returns only those matching the prefix")
 	cli.getFlagDelimiter = cli.GetFlags.String("delimiter", "", "|<text>| In listings, sets the delimiter and activates delimiter listings")
+	cli.getFlagManifest = cli.GetFlags.Bool("manifest-get", false, "For a static large object, emits the manifest rather than the object content, using ?multipart-manifest=get")
 
-	if err := cli.GlobalFlags.Parse(args[1:]); err != nil || len(cli.GlobalFlags.Args()) == 0 {
-		cli.fatal(cli, err)
+	cli.InitFlags = flag.NewFlagSet("init", flag.ContinueOnError)
+	cli.InitFlags.SetOutput(flagbuf)
+	cli.initFlagForce = cli.InitFlags.Bool("f", false, "Replaces any existing .nectar file.")
+
+	cli.HeadFlags = flag.NewFlagSet("head", flag.ContinueOnError)
+	cli.HeadFlags.SetOutput(flagbuf)
+	cli.headFlagRaw = cli.HeadFlags.Bool("r", false, "Emit the raw headers rather than grouping and decoding them")
+	cli.headFlagPolicies = cli.HeadFlags.Bool("policies", false, "For the account, outputs the containers, objects, and bytes in each storage policy, found by listing the containers and HEADing each, -C at a time, rather than the account's headers.")
+
+	cli.PostFlags = flag.NewFlagSet("post", flag.ContinueOnError)
+	cli.PostFlags.SetOutput(flagbuf)
+	cli.PostFlags.Var(&cli.postFlagMeta, "m", "|<key>=[value]| Sets a metadata item, mapped to the X-Account-Meta-, X-Container-Meta-, or X-Object-Meta- header depending on the target; an empty value removes the item. This option can be specified multiple times for additional items.")
+
+	cli.PutFlags = flag.NewFlagSet("put", flag.ContinueOnError)
+	cli.PutFlags.SetOutput(flagbuf)
+	cli.PutFlags.Var(&cli.putFlagMeta, "m", "|<key>=[value]| Sets a metadata item, mapped to the X-Account-Meta-, X-Container-Meta-, or X-Object-Meta- header depending on the target. This option can be specified multiple times for additional items.")
+
+	for _, flags := range []*flag.FlagSet{cli.DeleteFlags, cli.GetFlags, cli.HeadFlags, cli.PostFlags, cli.PutFlags} {
+		flags.StringVar(&cli.expectFlagStatus, "expect-status", "", "|<codes>| Exits non-zero unless the response status is one of the comma separated <codes>, each such as 404 or a class such as 2xx; an expected status other than 2xx is then not an error, with get outputting nothing for it.")
+		flags.Var(&cli.expectFlagHeaders, "expect-header", "|<name>[=<value>]| Exits non-zero unless the response has the header, with exactly <value> if given. This option can be specified multiple times for additional headers.")
+	}
+
+	cli.RawFlags = flag.NewFlagSet("raw", flag.ContinueOnError)
+	cli.RawFlags.SetOutput(flagbuf)
+
+	cli.SegmentsGCFlags = flag.NewFlagSet("segments-gc", flag.ContinueOnError)
+	cli.SegmentsGCFlags.SetOutput(flagbuf)
+	cli.segmentsGCFlagConfirm = cli.SegmentsGCFlags.Bool("confirm", false, "Deletes the orphaned segments rather than only outputting their names.")
+	cli.SegmentsGCFlags.Var(&cli.segmentsGCFlagManifestContainer, "manifest-container", "|<container>| A container whose large object manifests may refer to the segments; the default is the segment container's name without its _segments suffix. This option can be specified multiple times for additional containers.")
+	cli.segmentsGCFlagMinAge = cli.SegmentsGCFlags.String("min-age", "24h", "|<timespan>| Only considers segments last modified at least <timespan> ago as orphaned, so the segments of uploads still in progress, whose manifests are yet to be written, are left alone.")
+
+	cli.ShardsFlags = flag.NewFlagSet("shards", flag.ContinueOnError)
+	cli.ShardsFlags.SetOutput(flagbuf)
+
+	cli.SnapshotDiffFlags = flag.NewFlagSet("snapshot-diff", flag.ContinueOnError)
+	cli.SnapshotDiffFlags.SetOutput(flagbuf)
+	cli.SnapshotDiffFlags.BoolVar(&cli.snapshotFlagObject, "object", false, "Reads the snapshots from objects, each given as <container>/<object>, rather than local files.")
+
+	cli.SnapshotListFlags = flag.NewFlagSet("snapshot-list", flag.ContinueOnError)
+	cli.SnapshotListFlags.SetOutput(flagbuf)
+	cli.SnapshotListFlags.BoolVar(&cli.snapshotFlagObject, "object", false, "Saves the snapshot as an object, given as <container>/<object>, rather than a local file.")
+	cli.snapshotListFlagPrefix = cli.SnapshotListFlags.String("prefix", "", "|<text>| Only includes objects whose names begin with <text>.")
+
+	cli.TagFlags = flag.NewFlagSet("tag", flag.ContinueOnError)
+	cli.TagFlags.SetOutput(flagbuf)
+	cli.TagFlags.Var(&cli.tagFlagAdd, "t", "|<key>=<value>| Sets the tag, replacing any value it had. This option can be specified multiple times for additional tags.")
+	cli.TagFlags.Var(&cli.tagFlagRemove, "r", "|<key>| Removes the tag. This option can be specified multiple times for additional tags.")
+
+	cli.RestoreFlags = flag.NewFlagSet("restore", flag.ContinueOnError)
+	cli.RestoreFlags.SetOutput(flagbuf)
+	cli.restoreFlagManifest = cli.RestoreFlags.String("manifest", "", "|<timestamp>| Restores the backup with this timestamp, as output by -list, or the manifest object with this name, rather than the latest backup.")
+	cli.restoreFlagList = cli.RestoreFlags.Bool("list", false, "Outputs the timestamps of the backups with the name, oldest first, rather than restoring; <destpath> is then not needed.")
+
+	cli.TaggedFlags = flag.NewFlagSet("tagged", flag.ContinueOnError)
+	cli.TaggedFlags.SetOutput(flagbuf)
+	cli.taggedFlagPrefix = cli.TaggedFlags.String("prefix", "", "|<text>| Only considers objects whose names begin with <text>.")
+
+	cli.TempURLFlags = flag.NewFlagSet("tempurl", flag.ContinueOnError)
+	cli.TempURLFlags.SetOutput(flagbuf)
+	cli.tempurlFlagMethod = cli.TempURLFlags.String("method", "GET", "|<method>| The request method the URL will allow, such as GET, HEAD, or PUT.")
+	cli.tempurlFlagExpires = cli.TempURLFlags.String("expires", "1h", "|<timespan>| How long the URL will be valid for.")
+	cli.tempurlFlagKey = cli.TempURLFlags.String("key", "", "|<key>| The key to sign the URL with; the default is the container's Temp-URL-Key metadata, or else the account's.")
+	cli.tempurlFlagDigest = cli.TempURLFlags.String("digest", "sha256", "|<digest>| The signature's digest, sha1 or sha256; the cluster must allow it.")
+	cli.tempurlFlagPrefix = cli.TempURLFlags.Bool("prefix", false, "Makes the URL valid for every object whose name begins with [object], used as a prefix; the object name in the URL can then be replaced with any such object's name.")
+
+	cli.UploadFlags = flag.NewFlagSet("upload", flag.ContinueOnError)
+	cli.UploadFlags.SetOutput(flagbuf)
+	cli.UploadFlags.Var(&cli.uploadFlagMeta, "m", "|<key>=[value]| Sets a metadata item on each object uploaded, as an X-Object-Meta- header. This option can be specified multiple times for additional items.")
+	cli.uploadFlagState = cli.UploadFlags.String("state", "", "|<file>| Records each completed upload in <file>; rerunning with the same <file> skips files already uploaded unless they have since changed size or modification time.")
+	cli.UploadFlags.Var(&cli.uploadFlagTransform, "transform", "|<rule>| Renames each local path found under <sourcepath>, as given, with a sed style s|pattern|replacement|[g] rule before the object name prefix is added, such as s|^build/|releases/v1.2/|; the pattern is a Go regular expression, replacements may use \\1 or ${1}, and any delimiter may be used. This option can be specified multiple times, the rules being applied in order.")
+	cli.UploadFlags.Var(&cli.uploadFlagSegmentSize, "segment-size", "|<size>| Uploads each file larger than <size>, such as 1GiB, as a static large object whose segments of <size> are uploaded concurrently (see -C), so a single large file is not limited to one connection's throughput. Note that with many files, up to -C segments may be in flight for each of -C files.")
+	cli.uploadFlagSegmentContainer = cli.UploadFlags.String("segment-container", "", "|<container>| Container for the segments of -segment-size uploads; the default is the destination container name with _segments appended.")
+	cli.uploadFlagWalkers = cli.UploadFlags.Int("walkers", 4, "|<number>| The number of directories read at once while finding the files to upload in a directory, which helps with huge trees on network filesystems.")
+	cli.uploadFlagQueue = cli.UploadFlags.Int("queue", 10000, "|<number>| How many files found may wait to be uploaded, letting finding the files run ahead of the uploads.")
+	cli.uploadFlagMetaSidecar = cli.UploadFlags.String("meta-sidecar", "", "|<suffix>| Reads the content type, metadata, and expiry for each file from a JSON sidecar file named with <suffix> appended, such as .meta.json for file.txt.meta.json, if it exists; the sidecar files themselves are not uploaded. The JSON is an object with any of content_type, metadata (an object of names and values), delete_at (Unix seconds), delete_after (seconds), and headers (an object of headers and values).")
+	cli.uploadFlagMetaManifest = cli.UploadFlags.String("meta-manifest", "", "|<file>| Reads the content type, metadata, and expiry for each file from <file>, a JSON object whose keys are object names and values are as with -meta-sidecar. Sidecar files take precedence over the manifest, and both over -m.")
+	cli.uploadFlagEstimate = cli.UploadFlags.Bool("estimate", false, "Reports the number of objects, bytes, and requests the upload would involve, and about how long it would take at the -C concurrency, without uploading anything; the local files are only stat-ed and the container HEADed. Files that -skip-unchanged or -state would skip are still counted.")
+	cli.uploadFlagEstimateThroughput = defaultEstimateThroughput
+	cli.UploadFlags.Var(&cli.uploadFlagEstimateThroughput, "estimate-throughput", "|<size>| The throughput per connection, such as 50MiB, per second that -estimate assumes.")
+	cli.uploadFlagChecksum = cli.UploadFlags.String("checksum", "", "|<algorithm>| Computes a checksum of each file with md5, sha1, sha256, or xxhash (XXH64) before uploading it and stores it, with the algorithm name, as X-Object-Meta-Nectar-Checksum metadata; download then verifies the content against it. Each file is read an extra time to do so.")
+	cli.uploadFlagPreserve = cli.UploadFlags.Bool("preserve", false, "Records each file's mode, and its numeric owner and group where the platform has them, as X-Object-Meta-Nectar-Mode, -Uid, and -Gid metadata, for download -preserve to restore. Symlinks found in a directory are also uploaded, as empty objects with their targets recorded as X-Object-Meta-Nectar-Symlink metadata, rather than skipped.")
+	cli.uploadFlagArchive = cli.UploadFlags.Bool("archive", false, "Writes the files under the <sourcepath> directory as a gzipped tar, named as their objects would be, and streams it up in a single request for the cluster to expand server-side, which is much faster for many small files. The cluster must have the bulk middleware enabled. Content types and metadata, including from -m, -meta-sidecar, -meta-manifest, -checksum, and -preserve, are kept, but other headers such as expiries are not; -state, -skip-unchanged, -segment-size, -no-clobber, and -if-unmodified cannot be used with -archive.")
+	cli.uploadFlagSkipUnchanged = cli.UploadFlags.Bool("skip-unchanged", false, "Lists the existing objects under the destination once before uploading and skips any file whose size and MD5 match its object's.")
+
+	for _, flags := range []*flag.FlagSet{cli.CopyFlags, cli.UploadFlags} {
+		flags.BoolVar(&cli.clobberFlagNoClobber, "no-clobber", false, "Leaves any destination object that already exists alone, skipping it; the write is sent with If-None-Match: *, so an object another writer creates meanwhile is not overwritten either.")
+		flags.BoolVar(&cli.clobberFlagIfUnmodified, "if-unmodified", false, "HEADs each destination object just before writing it and sends the write with If-Match and If-Unmodified-Since for its ETag and Last-Modified, or If-None-Match: * if it does not exist, so an object another writer changes meanwhile fails with 412 Precondition Failed rather than being overwritten, where the cluster honors these conditions on writes.")
+	}
+
+	cli.VersioningFlags = flag.NewFlagSet("versioning", flag.ContinueOnError)
+	cli.VersioningFlags.SetOutput(flagbuf)
+	cli.versioningFlagEnable = cli.VersioningFlags.String("enable", "", "|<container>| Enables versioning, archiving previous versions of objects to <container>, which is created if needed.")
+	cli.versioningFlagHistory = cli.VersioningFlags.Bool("history", false, "With -enable, uses history mode, X-History-Location, rather than stack mode, X-Versions-Location.")
+	cli.versioningFlagDisable = cli.VersioningFlags.Bool("disable", false, "Disables versioning; versions already archived are left alone.")
+	cli.versioningFlagRestore = cli.VersioningFlags.String("restore", "", "|<version>| Restores the object to the version, as listed, or to the most recent with latest.")
+}
+
+// applyGlobalFlags validates the global options and sets up what they call
+// for, besides the client; see connect.
+func (cli *CLIInstance) applyGlobalFlags() {
+	switch *cli.globalFlagLogFormat {
+	case "text", "json", "logfmt":
+	default:
+		cli.fatalf(cli, "Unknown -log-format: %s\n", *cli.globalFlagLogFormat)
+	}
+	if *cli.globalFlagFormat != "" {
+		var err error
+		if cli.template, err = template.New("format").Parse(*cli.globalFlagFormat + "\n"); err != nil {
+			cli.fatal(cli, err)
+		}
+	}
+	if *cli.globalFlagReportInterval != "" {
+		var err error
+		if cli.reportInterval, err = time.ParseDuration(*cli.globalFlagReportInterval); err != nil {
+			cli.fatal(cli, err)
+		}
+		if cli.reportInterval <= 0 {
+			cli.fatalf(cli, "-report-interval must be positive\n")
+		}
+	}
+	if *cli.globalFlagMaxDuration != "" {
+		maxDuration, err := time.ParseDuration(*cli.globalFlagMaxDuration)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		if maxDuration <= 0 {
+			cli.fatalf(cli, "-max-duration must be positive\n")
+		}
+		cli.maxDuration = maxDuration
+	}
+	if *cli.globalFlagDeadline != "" {
+		deadline, err := time.ParseDuration(*cli.globalFlagDeadline)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		if deadline <= 0 {
+			cli.fatalf(cli, "-deadline must be positive\n")
+		}
+		cli.ctx, cli.cancel = context.WithTimeout(cli.ctx, deadline)
 	}
+	if *cli.globalFlagProgressJSON != "" {
+		var err error
+		if cli.progressEvents, err = openProgressEvents(*cli.globalFlagProgressJSON); err != nil {
+			cli.fatalf(cli, "Could not open -progress-json %s: %s\n", *cli.globalFlagProgressJSON, err)
+		}
+	}
+}
+
+// connect creates the client the commands are run against, authenticating.
+func (cli *CLIInstance) connect() {
 	if *cli.globalFlagAuthURL == "" {
 		cli.fatalf(cli, "No Auth URL set; use -A\n")
 	}
@@ -205,130 +752,194 @@ func CLI(args []string, fatal func(cli *CLIInstance, err error), fatalf func(cli
 	if *cli.globalFlagAuthKey == "" && *cli.globalFlagAuthPassword == "" {
 		cli.fatalf(cli, "No Auth Key or Password set; use -K or -P\n")
 	}
-	c, resp := NewClient(*cli.globalFlagAuthTenant, *cli.globalFlagAuthUser, *cli.globalFlagAuthPassword, *cli.globalFlagAuthKey, *cli.globalFlagStorageRegion, *cli.globalFlagAuthURL, *cli.globalFlagInternalStorage, strings.Split(*cli.globalFlagOverrideURLs, " "))
+	cli.buffers = newBufferPool(*cli.globalFlagBufferSize)
+	var opts []ClientOption
+	if *cli.globalFlagRequestIDs {
+		runID := nectarutil.UUID()
+		cli.verbosef(cli, "Request ID run: %s\n", runID)
+		opts = append(opts, WithRequestIDs(*cli.globalFlagRequestIDHeader, runID))
+	}
+	if *cli.GlobalFlagVerbose {
+		opts = append(opts, WithRequestObserver(cli.verboseRequest))
+	}
+	if *cli.globalFlagMetricsListen != "" {
+		metrics := newRequestMetrics()
+		opts = append(opts, WithRequestObserver(metrics.observe))
+		listener, err := net.Listen("tcp", *cli.globalFlagMetricsListen)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		go http.Serve(listener, mux)
+	}
+	if len(cli.globalFlagQuery) > 0 {
+		opts = append(opts, WithQuery(cli.globalFlagQuery.Query()))
+	}
+	if *cli.globalFlagAccount != "" {
+		opts = append(opts, WithAccount(*cli.globalFlagAccount))
+	}
+	if *cli.globalFlagAuthProvider != "" {
+		opts = append(opts, WithAuthProvider(*cli.globalFlagAuthProvider))
+	}
+	if *cli.globalFlagBreakerFailures > 0 {
+		cooldown, err := time.ParseDuration(*cli.globalFlagBreakerCooldown)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		opts = append(opts, WithCircuitBreaker(*cli.globalFlagBreakerFailures, cooldown))
+	}
+	if *cli.globalFlagRetryBudget > 0 {
+		opts = append(opts, WithRetryBudget(*cli.globalFlagRetryBudget))
+	}
+	if *cli.globalFlagRetries > 0 {
+		backoff, err := time.ParseDuration(*cli.globalFlagBackoff)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		opts = append(opts, WithRetryPolicy(RetryPolicy{MaxAttempts: *cli.globalFlagRetries + 1, Backoff: backoff, MaxBackoff: time.Minute, Jitter: 0.5}))
+	}
+	if *cli.globalFlagKeepTokenFresh != "" {
+		ahead, err := time.ParseDuration(*cli.globalFlagKeepTokenFresh)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		opts = append(opts, WithTokenRenewal(ahead))
+	}
+	if *cli.globalFlagStallTimeout != "" {
+		timeout, err := time.ParseDuration(*cli.globalFlagStallTimeout)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		opts = append(opts, WithStallTimeout(timeout))
+	}
+	if *cli.globalFlagHTTP2 {
+		opts = append(opts, WithHTTP2())
+	}
+	if *cli.globalFlagCACert != "" || *cli.globalFlagCert != "" || *cli.globalFlagKey != "" || *cli.globalFlagInsecure {
+		config, err := LoadTLSConfig(*cli.globalFlagCACert, *cli.globalFlagCert, *cli.globalFlagKey, *cli.globalFlagInsecure)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		opts = append(opts, WithTLSConfig(config))
+	}
+	timeout, err := time.ParseDuration(*cli.globalFlagTimeout)
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	opts = append(opts, WithTimeout(timeout))
+	if *cli.globalFlagDNSRefresh != "" {
+		refresh, err := time.ParseDuration(*cli.globalFlagDNSRefresh)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		opts = append(opts, WithDNSRoundRobin(refresh))
+	}
+	opts = append(opts, WithContext(cli.ctx))
+	if *cli.globalFlagIPv4 && *cli.globalFlagIPv6 {
+		cli.fatalf(cli, "Only one of -4 and -6 may be given.\n")
+	}
+	if *cli.globalFlagIPv4 {
+		opts = append(opts, WithIPVersion(4))
+	}
+	if *cli.globalFlagIPv6 {
+		opts = append(opts, WithIPVersion(6))
+	}
+	var resp *http.Response
+	cli.client, resp = NewClient(*cli.globalFlagAuthTenant, *cli.globalFlagAuthUser, *cli.globalFlagAuthPassword, *cli.globalFlagAuthKey, *cli.globalFlagStorageRegion, *cli.globalFlagAuthURL, *cli.globalFlagInternalStorage, strings.Split(*cli.globalFlagOverrideURLs, " "), opts...)
 	if resp != nil {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		cli.fatalf(cli, "Auth responded with %d %s - %s\n", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+		cli.fatalf(cli, "Auth responded with %s\n", NewResponseError(resp))
+	}
+	if *cli.globalFlagDebugListen != "" {
+		if cs, ok := cli.client.(ClientStats); ok {
+			expvar.Publish("client", expvar.Func(func() interface{} { return cs.Stats() }))
+		}
+		expvar.Publish("buffers", expvar.Func(func() interface{} { return cli.buffers.stats() }))
+		listener, err := net.Listen("tcp", *cli.globalFlagDebugListen)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		go http.Serve(listener, mux)
 	}
+}
+
+// run executes the command given by args.
+func (cli *CLIInstance) run(args []string) {
+	c := cli.client
 	cmd := ""
-	args = append([]string{}, cli.GlobalFlags.Args()...)
+	args = append([]string{}, args...)
 	if len(args) > 0 {
 		cmd = args[0]
 		args = args[1:]
 	}
-	switch cmd {
-	case "auth":
-		cli.auth(c, args)
-	case "bench-delete":
-		cli.benchDelete(c, args)
-	case "bench-get":
-		cli.benchGet(c, args)
-	case "bench-head":
-		cli.benchHead(c, args)
-	case "bench-mixed":
-		cli.benchMixed(c, args)
-	case "bench-post":
-		cli.benchPost(c, args)
-	case "bench-put":
-		cli.benchPut(c, args)
-	case "delete":
-		cli.delet(c, args)
-	case "download":
-		cli.download(c, args)
-	case "get":
-		cli.get(c, args)
-	case "head":
-		cli.head(c, args)
-	case "post":
-		cli.post(c, args)
-	case "put":
-		cli.put(c, args)
-	case "upload":
-		cli.upload(c, args)
-	default:
+	command := lookupCommand(cmd)
+	if command == nil {
 		cli.fatalf(cli, "Unknown command: %s\n", cmd)
+		return
+	}
+	cli.command = command
+	if cli.maxDuration > 0 {
+		cli.deadline = time.Now().Add(cli.maxDuration)
 	}
+	var flags *flag.FlagSet
+	if command.Flags != nil {
+		flags = command.Flags(cli)
+	}
+	command.Run(cli, c, flags, args)
+	cli.verboseConns(c)
 }
 
-func cliFatal(cli *CLIInstance, err error) {
-	if err == flag.ErrHelp || err == nil {
-		fmt.Println(cli.Arg0, `[options] <subcommand> ...`)
-		fmt.Println(brimtext.Wrap(`
+// printHelp outputs the help text for the global options and every command.
+func (cli *CLIInstance) printHelp() {
+	fmt.Println(cli.Arg0, `[options] <subcommand> ...`)
+	fmt.Println(brimtext.Wrap(`
 Tool for accessing a Hummingbird/Swift cluster. Some global options can also be set via environment variables. These will be noted at the end of the description with Env: NAME. The following global options are available:
         `, 0, "  ", "  "))
-		fmt.Print(cli.HelpFlags(cli.GlobalFlags))
-		fmt.Println()
-		fmt.Println(brimtext.Wrap(`
-The following subcommands are available:`, 0, "", ""))
-		fmt.Println("\nauth")
-		fmt.Println(brimtext.Wrap(`
-Displays information retrieved after authentication, such as the Account URL.
-        `, 0, "  ", "  "))
-		fmt.Println("\nbench-delete [options] <container> [object]")
-		fmt.Println(brimtext.Wrap(`
-Benchmark tests DELETEs. By default, 1000 DELETEs are done against the named <container>. If you specify [object] it will be used as a prefix for the object names, otherwise "bench-" will be used. Generally, you would use bench-put to populate the containers and objects, and then use bench-delete with the same options to test the deletions.
-        `, 0, "  ", "  "))
-		fmt.Print(cli.HelpFlags(cli.BenchDeleteFlags))
-		fmt.Println("\nbench-get [options] <container> [object]")
-		fmt.Println(brimtext.Wrap(`
-Benchmark tests GETs. By default, 1000 GETs are done from the named <container>. If you specify [object] it will be used as the prefix for the object names, otherwise "bench-" will be used. Generally, you would use bench-put to populate the containers and objects, and then use bench-get with the same options with the possible addition of -iterations to lengthen the test time.
-        `, 0, "  ", "  "))
-		fmt.Print(cli.HelpFlags(cli.BenchGetFlags))
-		fmt.Println("\nbench-head [options] <container> [object]")
-		fmt.Println(brimtext.Wrap(`
-Benchmark tests HEADs. By default, 1000 HEADs are done from the named <container>. If you specify [object] it will be used as the prefix for the object names, otherwise "bench-" will be used. Generally, you would use bench-put to populate the containers and objects, and then use bench-head with the same options with the possible addition of -iterations to lengthen the test time.
-        `, 0, "  ", "  "))
-		fmt.Print(cli.HelpFlags(cli.BenchHeadFlags))
-		fmt.Println("\nbench-mixed [options] <container> [object]")
-		fmt.Println(brimtext.Wrap(`
-Benchmark tests mixed request workloads. If you specify [object] it will be used as a prefix for the object names, otherwise "bench-" will be used. This test is made to be run for a specific span of time (10 minutes by default). You probably want to run with the -continue-on-error global flag; due to the eventual consistency model of Swift|Hummingbird, a few requests may 404.
+	fmt.Print(cli.HelpFlags(cli.GlobalFlags))
+	fmt.Println()
+	fmt.Println(brimtext.Wrap(`
+The following subcommands are available; help <subcommand>, or <subcommand> -h, outputs just the help for that one:`, 0, "", ""))
+	for _, cmd := range sortedCommands() {
+		cli.printCommandHelp(cmd)
+	}
+	fmt.Println("\n[container] [object] can also be specified as [container]/[object]")
+}
 
-Note: The concurrency setting for this test will be used for each request type separately. So, with five request types (PUT, POST, GET, HEAD, DELETE), this means five times the concurrency value specified.
-        `, 0, "  ", "  "))
-		fmt.Println()
-		fmt.Print(cli.HelpFlags(cli.BenchMixedFlags))
-		fmt.Println("\nbench-post [options] <container> [object]")
-		fmt.Println(brimtext.Wrap(`
-Benchmark tests POSTs. By default, 1000 POSTs are done against the named <container>. If you specify [object] it will be used as a prefix for the object names, otherwise "bench-" will be used. Generally, you would use bench-put to populate the containers and objects, and then use bench-post with the same options to test POSTing.
-        `, 0, "  ", "  "))
-		fmt.Print(cli.HelpFlags(cli.BenchPostFlags))
-		fmt.Println("\nbench-put [options] <container> [object]")
-		fmt.Println(brimtext.Wrap(`
-Benchmark tests PUTs. By default, 1000 PUTs are done into the named <container>. If you specify [object] it will be used as a prefix for the object names, otherwise "bench-" will be used.
-        `, 0, "  ", "  "))
-		fmt.Print(cli.HelpFlags(cli.BenchPutFlags))
-		fmt.Println("\ndelete [container] [object]")
-		fmt.Println(brimtext.Wrap(`
-Performs a DELETE request. A DELETE, as probably expected, is used to remove the target.
-        `, 0, "  ", "  "))
-		fmt.Println("\ndownload [options] [container] [object] <destpath>")
-		fmt.Println(brimtext.Wrap(`
-Downloads an object or objects to a local file or files. The <destpath> indicates where you want the file or files to be created. If you don't give [container] [object] the entire account will be downloaded (requires -a for confirmation). If you just give [container] that entire container will be downloaded. Perhaps obviously, if you give [container] [object] just that object will be downloaded.
-        `, 0, "  ", "  "))
-		fmt.Print(cli.HelpFlags(cli.DownloadFlags))
-		fmt.Println("\nget [options] [container] [object]")
-		fmt.Println(brimtext.Wrap(`
-Performs a GET request. A GET on an account or container will output the listing of containers or objects, respectively. A GET on an object will output the content of the object to standard output.
-        `, 0, "  ", "  "))
-		fmt.Print(cli.HelpFlags(cli.GetFlags))
-		fmt.Println("\nhead [options] [container] [object]")
-		fmt.Println(brimtext.Wrap(`
-Performs a HEAD request, giving overall information about the account, container, or object.
-        `, 0, "  ", "  "))
-		fmt.Println("\npost [container] [object]")
-		fmt.Println(brimtext.Wrap(`
-Performs a POST request. POSTs allow you to update the metadata for the target.
-        `, 0, "  ", "  "))
-		fmt.Println("\nput [container] [object]")
-		fmt.Println(brimtext.Wrap(`
-Performs a PUT request. A PUT to an account or container will create them. A PUT to an object will create it using the content from standard input.
-        `, 0, "  ", "  "))
-		fmt.Println("\nupload [options] <sourcepath> [container] [object]")
-		fmt.Println(brimtext.Wrap(`
-Uploads local files as objects. If you don't specify [container] the name of the current directory will be used. If you don't specify [object] the relative path name from the current directory will be used. If you do specify [object] while uploading a directory, [object] will be used as a prefix to the resulting object names. Note that when uploading a directory, only regular files will be uploaded.
-        `, 0, "  ", "  "))
-		fmt.Println("\n[container] [object] can also be specified as [container]/[object]")
+// printCommandHelp outputs the help text for the command alone.
+func (cli *CLIInstance) printCommandHelp(cmd *Command) {
+	fmt.Println("\n" + cmd.Name + " " + cmd.Usage)
+	fmt.Println(brimtext.Wrap("\n"+strings.TrimSpace(cmd.Help)+"\n", 0, "  ", "  "))
+	if cmd.Flags != nil {
+		fmt.Print(cli.HelpFlags(cmd.Flags(cli)))
+	}
+}
+
+// help is the help command, outputting the help text for the subcommand
+// given, or for everything.
+func (cli *CLIInstance) help(args []string) {
+	if len(args) == 0 {
+		cli.printHelp()
+		return
+	}
+	for _, name := range args {
+		cmd := lookupCommand(name)
+		if cmd == nil {
+			cli.fatalf(cli, "Unknown command: %s\n", name)
+			return
+		}
+		cli.printCommandHelp(cmd)
+	}
+	fmt.Printf("\nSee %s help for the global options.\n", cli.Arg0)
+}
+
+func cliFatal(cli *CLIInstance, err error) {
+	if err == flag.ErrHelp && cli.command != nil {
+		// Just the help for the command being run, as with <subcommand> -h.
+		cli.printCommandHelp(cli.command)
+	} else if err == flag.ErrHelp || err == nil {
+		cli.printHelp()
 	} else {
 		msg := err.Error()
 		if strings.HasPrefix(msg, "flag provided but not defined: ") {
@@ -346,62 +957,350 @@ func cliFatalf(cli *CLIInstance, frmt string, args ...interface{}) {
 
 func cliVerbosef(cli *CLIInstance, frmt string, args ...interface{}) {
 	if *cli.GlobalFlagVerbose {
-		fmt.Fprintf(os.Stderr, frmt, args...)
+		if *cli.globalFlagLogFormat == "text" {
+			fmt.Fprintf(os.Stderr, frmt, args...)
+		} else {
+			fmt.Fprint(os.Stderr, formatLogEvent(*cli.globalFlagLogFormat, "msg", strings.TrimSpace(fmt.Sprintf(frmt, args...))))
+		}
 	}
 }
 
-// HelpFlags returns the formatted help text for the FlagSet given.
-func (cli *CLIInstance) HelpFlags(flags *flag.FlagSet) string {
-	var data [][]string
-	firstWidth := 0
-	flags.VisitAll(func(f *flag.Flag) {
-		n := "    -" + f.Name
-		u := strings.TrimSpace(f.Usage)
-		if u != "" && u[0] == '|' {
-			s := strings.SplitN(u, "|", 3)
-			if len(s) == 3 {
-				n += " " + strings.TrimSpace(s[1])
-				u = strings.TrimSpace(s[2])
-			}
-		}
-		if len(n) > firstWidth {
-			firstWidth = len(n)
-		}
-		data = append(data, []string{n, u})
-	})
-	opts := brimtext.NewDefaultAlignOptions()
-	opts.Widths = []int{0, brimtext.GetTTYWidth() - firstWidth - 2}
-	return brimtext.Align(data, opts)
+// verboseEvent emits text with the text -log-format, otherwise it emits the
+// key/value pairs as a structured event. Structured events are always written
+// directly to stderr, bypassing any custom verbosef.
+func (cli *CLIInstance) verboseEvent(text string, keyvals ...interface{}) {
+	if *cli.globalFlagLogFormat == "text" {
+		cli.verbosef(cli, "%s", text)
+	} else if *cli.GlobalFlagVerbose {
+		fmt.Fprint(os.Stderr, formatLogEvent(*cli.globalFlagLogFormat, keyvals...))
+	}
 }
 
-func (cli *CLIInstance) auth(c Client, args []string) {
-	uc, ok := c.(*userClient)
-	if ok {
-		surls := uc.GetURLs()
-		if len(surls) == 0 {
-			fmt.Println("Account URL:")
-		} else if len(surls) == 1 {
-			fmt.Println("Account URL:", surls[0])
+// formatLogEvent returns a single line encoding the key/value pairs, prefixed
+// with the current time, in the given format: json or logfmt.
+func formatLogEvent(format string, keyvals ...interface{}) string {
+	keyvals = append([]interface{}{"time", time.Now().Format(time.RFC3339Nano)}, keyvals...)
+	var buf bytes.Buffer
+	if format == "json" {
+		buf.WriteByte('{')
+	}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		k := fmt.Sprint(keyvals[i])
+		v := keyvals[i+1]
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		if format == "json" {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			kb, _ := json.Marshal(k)
+			vb, err := json.Marshal(v)
+			if err != nil {
+				vb, _ = json.Marshal(fmt.Sprint(v))
+			}
+			buf.Write(kb)
+			buf.WriteByte(':')
+			buf.Write(vb)
 		} else {
-			fmt.Println("Account URLs:", strings.Join(surls, " "))
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(k)
+			buf.WriteByte('=')
+			s := fmt.Sprint(v)
+			if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+				s = strconv.Quote(s)
+			}
+			buf.WriteString(s)
 		}
-	} else {
-		fmt.Println("Account URL:", c.GetURL())
 	}
-	if ct, ok := c.(ClientToken); ok {
-		fmt.Println("Token:", ct.GetToken())
+	if format == "json" {
+		buf.WriteByte('}')
 	}
+	buf.WriteByte('\n')
+	return buf.String()
 }
 
-func (cli *CLIInstance) benchDelete(c Client, args []string) {
-	if err := cli.BenchDeleteFlags.Parse(args); err != nil {
-		cli.fatal(cli, err)
+// verboseTransID emits the X-Trans-Id of the response, along with the
+// client-generated request ID if -request-ids is in use.
+func (cli *CLIInstance) verboseTransID(resp *http.Response) {
+	if *cli.globalFlagRequestIDs && resp.Request != nil {
+		requestID := resp.Request.Header.Get(*cli.globalFlagRequestIDHeader)
+		cli.verboseEvent(fmt.Sprintf("X-Trans-Id: %q %s: %q\n", resp.Header.Get("X-Trans-Id"), *cli.globalFlagRequestIDHeader, requestID), "msg", "response", "trans_id", resp.Header.Get("X-Trans-Id"), "request_id", requestID)
+		return
 	}
-	container, object := parsePath(cli.BenchDeleteFlags.Args())
-	if container == "" {
-		cli.fatalf(cli, "bench-delete requires <container>\n")
+	cli.verboseEvent(fmt.Sprintf("X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id")), "msg", "response", "trans_id", resp.Header.Get("X-Trans-Id"))
+}
+
+// verboseConns emits how many connections the client opened and reused, the
+// TLS handshakes it did, and how many of those negotiated HTTP/2; far more
+// connections opened than the concurrency used means idle connections are not
+// being kept alive for reuse.
+func (cli *CLIInstance) verboseConns(c Client) {
+	cs, ok := c.(ClientStats)
+	if !ok || !*cli.GlobalFlagVerbose {
+		return
 	}
-	if object == "" {
+	stats := cs.Stats()
+	cli.verboseEvent(
+		fmt.Sprintf("Connections: %d opened, %d reused, %d TLS handshakes, %d HTTP/2\n", stats["conns_opened"], stats["conns_reused"], stats["tls_handshakes"], stats["http2_conns"]),
+		"msg", "connections",
+		"opened", stats["conns_opened"],
+		"reused", stats["conns_reused"],
+		"tls_handshakes", stats["tls_handshakes"],
+		"http2", stats["http2_conns"],
+	)
+}
+
+// verboseRequest emits the timing, status, and byte counts of a completed
+// request.
+func (cli *CLIInstance) verboseRequest(info *RequestInfo) {
+	path := info.URL
+	if info.Request != nil {
+		path = info.Request.URL.RequestURI()
+	}
+	if info.Err != nil {
+		cli.verboseEvent(fmt.Sprintf("%s %s - %s - %.05fs\n", info.Method, path, info.Err, float64(info.Elapsed)/float64(time.Second)), "msg", "request", "method", info.Method, "path", path, "error", info.Err, "elapsed_seconds", float64(info.Elapsed)/float64(time.Second))
+		return
+	}
+	// The protocol is only noted when not the usual HTTP/1.1, such as when
+	// -http2 negotiated HTTP/2.
+	proto := ""
+	if info.Proto != "" && info.Proto != "HTTP/1.1" {
+		proto = " - " + info.Proto
+	}
+	// The address connected to is noted too, for debugging -4 and -6 or
+	// -dns-refresh.
+	addr := ""
+	if info.RemoteAddr != "" {
+		addr = " - " + info.RemoteAddr
+	}
+	cli.verboseEvent(
+		fmt.Sprintf("%s %s - %d %s%s%s - %d bytes sent, %d bytes received - %.05fs to headers, %.05fs total\n", info.Method, path, info.Status, http.StatusText(info.Status), proto, addr, info.BytesSent, info.BytesReceived, float64(info.HeadersElapsed)/float64(time.Second), float64(info.Elapsed)/float64(time.Second)),
+		"msg", "request",
+		"method", info.Method,
+		"path", path,
+		"status", info.Status,
+		"proto", info.Proto,
+		"remote_addr", info.RemoteAddr,
+		"trans_id", info.Header.Get("X-Trans-Id"),
+		"bytes_sent", info.BytesSent,
+		"bytes_received", info.BytesReceived,
+		"headers_elapsed_seconds", float64(info.HeadersElapsed)/float64(time.Second),
+		"elapsed_seconds", float64(info.Elapsed)/float64(time.Second),
+	)
+}
+
+// autoConcurrency returns the concurrency to use and, if -auto-concurrency is
+// in use, an adaptive limiter for that concurrency as its maximum. With
+// -auto-concurrency and no -C, the maximum will be 64.
+func (cli *CLIInstance) autoConcurrency(concurrency int, name string) (int, *adaptiveLimiter) {
+	if !*cli.globalFlagAutoConcurrency {
+		return concurrency, nil
+	}
+	if *cli.globalFlagConcurrency < 1 {
+		concurrency = 64
+	}
+	return concurrency, newAdaptiveLimiter(concurrency, func(limit int) {
+		cli.verbosef(cli, "%s concurrency now %d\n", name, limit)
+	})
+}
+
+// benchResponseColumns returns the -csv columns given for each response: the
+// protocol, such as HTTP/1.1 or HTTP/2.0, and the phases if the bench -phases
+// option was given.
+func (cli *CLIInstance) benchResponseColumns() []string {
+	if !cli.benchFlagPhases {
+		return []string{"protocol"}
+	}
+	return []string{"protocol", "dns_nanoseconds", "connect_nanoseconds", "tls_nanoseconds", "request_write_nanoseconds", "ttfb_nanoseconds"}
+}
+
+// benchResponseFields returns the values of the benchResponseColumns for the
+// response, empty if not known, such as for a request that failed outright.
+func (cli *CLIInstance) benchResponseFields(resp *http.Response) []string {
+	proto := ""
+	if resp != nil && resp.Request != nil {
+		proto = resp.Proto
+	}
+	if !cli.benchFlagPhases {
+		return []string{proto}
+	}
+	phases := ResponsePhases(resp)
+	if phases == nil {
+		return []string{proto, "", "", "", "", ""}
+	}
+	return []string{
+		proto,
+		fmt.Sprintf("%d", phases.DNS.Nanoseconds()),
+		fmt.Sprintf("%d", phases.Connect.Nanoseconds()),
+		fmt.Sprintf("%d", phases.TLS.Nanoseconds()),
+		fmt.Sprintf("%d", phases.RequestWrite.Nanoseconds()),
+		fmt.Sprintf("%d", phases.TTFB.Nanoseconds()),
+	}
+}
+
+// benchCloseCSV closes the CSV writers given, any of which may be nil,
+// failing if any of them could not be completely written.
+func (cli *CLIInstance) benchCloseCSV(writers ...*csvWriter) {
+	var firstErr error
+	for _, w := range writers {
+		if w == nil {
+			continue
+		}
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		cli.fatal(cli, firstErr)
+	}
+}
+
+// benchProfile starts any profiling requested with the bench -pprof-listen,
+// -cpuprofile, and -memprofile options, returning the function to call once
+// the bench is complete.
+func (cli *CLIInstance) benchProfile() func() {
+	if cli.benchFlagPProfListen != "" {
+		listener, err := net.Listen("tcp", cli.benchFlagPProfListen)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		go http.Serve(listener, mux)
+	}
+	var cpuf *os.File
+	if cli.benchFlagCPUProfile != "" {
+		var err error
+		if cpuf, err = os.Create(cli.benchFlagCPUProfile); err != nil {
+			cli.fatal(cli, err)
+		}
+		if err = rpprof.StartCPUProfile(cpuf); err != nil {
+			cli.fatal(cli, err)
+		}
+	}
+	return func() {
+		if cpuf != nil {
+			rpprof.StopCPUProfile()
+			if err := cpuf.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Could not write %s: %s\n", cli.benchFlagCPUProfile, err)
+			}
+		}
+		if cli.benchFlagMemProfile != "" {
+			f, err := os.Create(cli.benchFlagMemProfile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not create %s: %s\n", cli.benchFlagMemProfile, err)
+				return
+			}
+			runtime.GC()
+			if err = rpprof.WriteHeapProfile(f); err == nil {
+				err = f.Close()
+			} else {
+				f.Close()
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not write %s: %s\n", cli.benchFlagMemProfile, err)
+			}
+		}
+	}
+}
+
+// HelpFlags returns the formatted help text for the FlagSet given.
+func (cli *CLIInstance) HelpFlags(flags *flag.FlagSet) string {
+	var data [][]string
+	firstWidth := 0
+	flags.VisitAll(func(f *flag.Flag) {
+		n := "    -" + f.Name
+		u := strings.TrimSpace(f.Usage)
+		if u != "" && u[0] == '|' {
+			s := strings.SplitN(u, "|", 3)
+			if len(s) == 3 {
+				n += " " + strings.TrimSpace(s[1])
+				u = strings.TrimSpace(s[2])
+			}
+		}
+		if len(n) > firstWidth {
+			firstWidth = len(n)
+		}
+		data = append(data, []string{n, u})
+	})
+	opts := brimtext.NewDefaultAlignOptions()
+	opts.Widths = []int{0, brimtext.GetTTYWidth() - firstWidth - 2}
+	return brimtext.Align(data, opts)
+}
+
+func (cli *CLIInstance) auth(c Client, args []string) {
+	if err := cli.AuthFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	if *cli.authFlagProject != "" {
+		cp, ok := c.(ClientProject)
+		if !ok {
+			cli.fatalf(cli, "This client cannot switch projects.\n")
+		}
+		resp := cp.SwitchProject(*cli.authFlagProject)
+		if resp.StatusCode/100 != 2 {
+			cli.fatalf(cli, "Switching to project %s responded with %s\n", *cli.authFlagProject, NewResponseError(resp))
+		}
+		resp.Body.Close()
+	}
+	if ce, ok := c.(ClientEndpoints); ok {
+		surls := ce.GetURLs()
+		if len(surls) == 0 {
+			fmt.Println("Account URL:")
+		} else if len(surls) == 1 {
+			fmt.Println("Account URL:", surls[0])
+		} else {
+			fmt.Println("Account URLs:", strings.Join(surls, " "))
+		}
+		if region := ce.GetRegion(); region != "" {
+			fmt.Println("Region:", region)
+		}
+	} else {
+		fmt.Println("Account URL:", c.GetURL())
+	}
+	if ct, ok := c.(ClientToken); ok {
+		fmt.Println("Token:", ct.GetToken())
+	}
+	if !*cli.authFlagAccount {
+		return
+	}
+	resp := c.HeadAccount(cli.globalFlagHeaders.Headers())
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		cli.fatal(cli, NewResponseError(resp))
+	}
+	resp.Body.Close()
+	// Only the headers describing the account itself are of interest here,
+	// not those describing the response.
+	header := http.Header{}
+	for k, vs := range resp.Header {
+		if _, ok := systemHeaderLabels[k]; ok || metaHeaderName(k) != "" || strings.HasPrefix(k, "X-Account-Storage-Policy-") {
+			header[k] = vs
+		}
+	}
+	fmt.Println()
+	cli.table(headerSections(header), brimtext.NewDefaultAlignOptions())
+}
+
+func (cli *CLIInstance) benchDelete(c Client, args []string) {
+	if err := cli.BenchDeleteFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	defer cli.benchProfile()()
+	results := cli.newBenchResults(c, "bench-delete", args, *cli.benchDeleteFlagCSV, *cli.benchDeleteFlagCSVOT)
+	defer results.store()
+	container, object := parsePath(cli.BenchDeleteFlags.Args())
+	if container == "" {
+		cli.fatalf(cli, "bench-delete requires <container>\n")
+	}
+	if object == "" {
 		object = "bench-"
 	}
 	containers := *cli.benchDeleteFlagContainers
@@ -412,40 +1311,28 @@ func (cli *CLIInstance) benchDelete(c Client, args []string) {
 	if count < 1 {
 		count = 1000
 	}
-	var csvw *csv.Writer
-	var csvlk sync.Mutex
+	var csvw *csvWriter
 	if *cli.benchDeleteFlagCSV != "" {
-		csvf, err := os.Create(*cli.benchDeleteFlagCSV)
-		if err != nil {
+		var err error
+		if csvw, err = newCSVWriter(*cli.benchDeleteFlagCSV); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvw = csv.NewWriter(csvf)
-		defer func() {
-			csvw.Flush()
-			csvf.Close()
-		}()
-		csvw.Write([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "elapsed_nanoseconds"})
-		csvw.Flush()
+		csvw.Write(append([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "elapsed_nanoseconds", "retries", "final_attempt_succeeded"}, cli.benchResponseColumns()...))
 	}
-	var csvotw *csv.Writer
+	var csvotw *csvWriter
 	if *cli.benchDeleteFlagCSVOT != "" {
-		csvotf, err := os.Create(*cli.benchDeleteFlagCSVOT)
-		if err != nil {
+		var err error
+		if csvotw, err = newCSVWriter(*cli.benchDeleteFlagCSVOT); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvotw = csv.NewWriter(csvotf)
-		defer func() {
-			csvotw.Flush()
-			csvotf.Close()
-		}()
-		csvotw.Write([]string{"time_unix_nano", "count_since_last_time"})
-		csvotw.Write([]string{fmt.Sprintf("%d", time.Now().UnixNano()), "0"})
-		csvotw.Flush()
 	}
+	csvot := newOverTimeCSV(csvotw, cli.progressInterval(time.Minute), "count_since_last_time")
 	concurrency := *cli.globalFlagConcurrency
 	if concurrency < 1 {
 		concurrency = 1
 	}
+	concurrency, limiter := cli.autoConcurrency(concurrency, "DELETE")
+	rate := newRateLimiter(*cli.benchDeleteFlagRate)
 	benchChan := make(chan int, concurrency)
 	wg := sync.WaitGroup{}
 	wg.Add(concurrency)
@@ -458,38 +1345,37 @@ func (cli *CLIInstance) benchDelete(c Client, args []string) {
 					break
 				}
 				i--
-				deleteContainer := container
-				if containers > 1 {
-					deleteContainer = fmt.Sprintf("%s%d", deleteContainer, i%containers)
-				}
+				deleteContainer := benchContainer(container, containers, i%containers)
 				deleteObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "DELETE %s/%s\n", deleteContainer, deleteObject)
 				if csvw != nil {
 					start = time.Now()
 				}
+				limiter.acquire()
+				opStart := time.Now()
 				resp := c.DeleteObject(deleteContainer, deleteObject, cli.globalFlagHeaders.Headers())
+				limiter.release(opStart, resp.StatusCode)
+				csvot.add(0)
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						deleteContainer + "/" + deleteObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "DELETE %s/%s - %d %s - %s\n", deleteContainer, deleteObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "DELETE %s/%s - %d %s - %s\n", deleteContainer, deleteObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
 				}
 				resp.Body.Close()
@@ -502,76 +1388,79 @@ func (cli *CLIInstance) benchDelete(c Client, args []string) {
 	} else {
 		fmt.Printf("Bench-DELETE of %d objects, distributed across %d containers, at %d concurrency...", count, containers, concurrency)
 	}
-	ticker := time.NewTicker(time.Minute)
+	interrupt := cli.notifyInterrupt()
+	defer interrupt.stop()
+	ticker := time.NewTicker(cli.progressInterval(time.Minute))
+	resources := newResourceSampler(c)
 	start := time.Now()
-	lastSoFar := 0
+	csvot.begin(start)
+	requests := 0
+feeding:
 	for i := 1; i <= count; i++ {
+		if !rate.wait(interrupt.done()) {
+			break feeding
+		}
 		waiting := true
 		for waiting {
 			select {
+			case <-interrupt.done():
+				break feeding
 			case <-ticker.C:
 				soFar := i - concurrency
 				now := time.Now()
 				elapsed := now.Sub(start)
 				fmt.Printf("\n%.05fs for %d DELETEs so far, %.05f DELETEs per second...", float64(elapsed)/float64(time.Second), soFar, float64(soFar)/float64(elapsed/time.Second))
-				if csvotw != nil {
-					csvotw.Write([]string{
-						fmt.Sprintf("%d", now.UnixNano()),
-						fmt.Sprintf("%d", soFar-lastSoFar),
-					})
-					csvotw.Flush()
-					lastSoFar = soFar
-				}
 			case benchChan <- i:
 				waiting = false
+				requests++
 			}
 		}
 	}
 	close(benchChan)
 	wg.Wait()
 	stop := time.Now()
+	csvot.finish(stop)
 	elapsed := stop.Sub(start)
 	ticker.Stop()
 	fmt.Println()
-	if containers == 1 {
+	if interrupt.interrupted() {
+		fmt.Printf("Interrupted after %d of %d DELETEs; not deleting the containers.", requests, count)
+	} else if containers == 1 {
 		fmt.Printf("Attempting to delete container...")
-		cli.verbosef(cli, "DELETE %s\n", container)
-		resp := c.DeleteContainer(container, cli.globalFlagHeaders.Headers())
+		oneContainer := benchContainer(container, 1, 0)
+		cli.verbosef(cli, "DELETE %s\n", oneContainer)
+		resp := c.DeleteContainer(oneContainer, cli.globalFlagHeaders.Headers())
 		if resp.StatusCode/100 != 2 {
-			bodyBytes, _ := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
-			fmt.Fprintf(os.Stderr, "DELETE %s - %d %s - %s\n", container, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+			fmt.Fprintln(os.Stderr, NewResponseError(resp))
 		}
 		resp.Body.Close()
 	} else {
 		fmt.Printf("Attempting to delete the %d containers...", containers)
 		for x := 0; x < containers; x++ {
-			deleteContainer := fmt.Sprintf("%s%d", container, x)
+			deleteContainer := benchContainer(container, containers, x)
 			cli.verbosef(cli, "DELETE %s\n", deleteContainer)
 			resp := c.DeleteContainer(deleteContainer, cli.globalFlagHeaders.Headers())
 			if resp.StatusCode/100 != 2 {
-				bodyBytes, _ := ioutil.ReadAll(resp.Body)
-				resp.Body.Close()
-				fmt.Fprintf(os.Stderr, "DELETE %s - %d %s - %s\n", deleteContainer, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+				fmt.Fprintln(os.Stderr, NewResponseError(resp))
 			}
 			resp.Body.Close()
 		}
 	}
 	fmt.Println()
-	fmt.Printf("%.05fs total time, %.05f DELETEs per second.\n", float64(elapsed)/float64(time.Second), float64(count)/float64(elapsed/time.Second))
-	if csvotw != nil {
-		csvotw.Write([]string{
-			fmt.Sprintf("%d", stop.UnixNano()),
-			fmt.Sprintf("%d", count-lastSoFar),
-		})
-		csvotw.Flush()
-	}
+	fmt.Printf("%.05fs total time, %.05f DELETEs per second.\n", float64(elapsed)/float64(time.Second), float64(requests)/float64(elapsed/time.Second))
+	usage := resources.stop()
+	fmt.Println(usage)
+	results.finish(elapsed, int64(requests), 0, concurrency, usage)
+	cli.benchCloseCSV(csvw, csvotw)
 }
 
 func (cli *CLIInstance) benchGet(c Client, args []string) {
 	if err := cli.BenchGetFlags.Parse(args); err != nil {
 		cli.fatal(cli, err)
 	}
+	defer cli.benchProfile()()
+	results := cli.newBenchResults(c, "bench-get", args, *cli.benchGetFlagCSV, *cli.benchGetFlagCSVOT)
+	defer results.store()
 	container, object := parsePath(cli.BenchGetFlags.Args())
 	if container == "" {
 		cli.fatalf(cli, "bench-get requires <container>\n")
@@ -587,36 +1476,22 @@ func (cli *CLIInstance) benchGet(c Client, args []string) {
 	if count < 1 {
 		count = 1000
 	}
-	var csvw *csv.Writer
-	var csvlk sync.Mutex
+	var csvw *csvWriter
 	if *cli.benchGetFlagCSV != "" {
-		csvf, err := os.Create(*cli.benchGetFlagCSV)
-		if err != nil {
+		var err error
+		if csvw, err = newCSVWriter(*cli.benchGetFlagCSV); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvw = csv.NewWriter(csvf)
-		defer func() {
-			csvw.Flush()
-			csvf.Close()
-		}()
-		csvw.Write([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "headers_elapsed_nanoseconds", "elapsed_nanoseconds"})
-		csvw.Flush()
+		csvw.Write(append([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "headers_elapsed_nanoseconds", "elapsed_nanoseconds", "bytes", "retries", "final_attempt_succeeded"}, cli.benchResponseColumns()...))
 	}
-	var csvotw *csv.Writer
+	var csvotw *csvWriter
 	if *cli.benchGetFlagCSVOT != "" {
-		csvotf, err := os.Create(*cli.benchGetFlagCSVOT)
-		if err != nil {
+		var err error
+		if csvotw, err = newCSVWriter(*cli.benchGetFlagCSVOT); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvotw = csv.NewWriter(csvotf)
-		defer func() {
-			csvotw.Flush()
-			csvotf.Close()
-		}()
-		csvotw.Write([]string{"time_unix_nano", "count_since_last_time"})
-		csvotw.Write([]string{fmt.Sprintf("%d", time.Now().UnixNano()), "0"})
-		csvotw.Flush()
 	}
+	csvot := newOverTimeCSV(csvotw, cli.progressInterval(time.Minute), "count_since_last_time")
 	iterations := *cli.benchGetFlagIterations
 	if iterations < 1 {
 		iterations = 1
@@ -625,9 +1500,11 @@ func (cli *CLIInstance) benchGet(c Client, args []string) {
 	if concurrency < 1 {
 		concurrency = 1
 	}
+	concurrency, limiter := cli.autoConcurrency(concurrency, "GET")
 	benchChan := make(chan int, concurrency)
 	wg := sync.WaitGroup{}
 	wg.Add(concurrency)
+	var bytesReceived int64
 	for x := 0; x < concurrency; x++ {
 		go func() {
 			var start time.Time
@@ -638,46 +1515,46 @@ func (cli *CLIInstance) benchGet(c Client, args []string) {
 					break
 				}
 				i--
-				getContainer := container
-				if containers > 1 {
-					getContainer = fmt.Sprintf("%s%d", getContainer, i%containers)
-				}
+				getContainer := benchContainer(container, containers, i%containers)
 				getObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "GET %s/%s\n", getContainer, getObject)
 				if csvw != nil {
 					start = time.Now()
 				}
+				limiter.acquire()
+				opStart := time.Now()
 				resp := c.GetObject(getContainer, getObject, cli.globalFlagHeaders.Headers())
+				limiter.release(opStart, resp.StatusCode)
+				csvot.add(0)
 				if csvw != nil {
 					headers_elapsed = time.Now().Sub(start).Nanoseconds()
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "GET %s/%s - %d %s - %s\n", getContainer, getObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "GET %s/%s - %d %s - %s\n", getContainer, getObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
-				} else {
-					io.Copy(ioutil.Discard, resp.Body)
 				}
+				n, _ := io.Copy(ioutil.Discard, resp.Body)
+				atomic.AddInt64(&bytesReceived, n)
 				resp.Body.Close()
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						getContainer + "/" + getObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", headers_elapsed),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						fmt.Sprintf("%d", n),
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 			}
 			wg.Done()
@@ -688,29 +1565,29 @@ func (cli *CLIInstance) benchGet(c Client, args []string) {
 	} else {
 		fmt.Printf("Bench-GET of %d (%d distinct) objects, distributed across %d containers, at %d concurrency...", iterations*count, count, containers, concurrency)
 	}
-	ticker := time.NewTicker(time.Minute)
+	interrupt := cli.notifyInterrupt()
+	defer interrupt.stop()
+	ticker := time.NewTicker(cli.progressInterval(time.Minute))
+	resources := newResourceSampler(c)
 	start := time.Now()
-	lastSoFar := 0
+	csvot.begin(start)
+	requests := 0
+feeding:
 	for iteration := 0; iteration < iterations; iteration++ {
 		for i := 1; i <= count; i++ {
 			waiting := true
 			for waiting {
 				select {
+				case <-interrupt.done():
+					break feeding
 				case <-ticker.C:
 					soFar := iteration*count + i - concurrency
 					now := time.Now()
 					elapsed := now.Sub(start)
-					fmt.Printf("\n%.05fs for %d GETs so far, %.05f GETs per second...", float64(elapsed)/float64(time.Second), soFar, float64(soFar)/float64(elapsed/time.Second))
-					if csvotw != nil {
-						csvotw.Write([]string{
-							fmt.Sprintf("%d", now.UnixNano()),
-							fmt.Sprintf("%d", soFar-lastSoFar),
-						})
-						csvotw.Flush()
-						lastSoFar = soFar
-					}
+					fmt.Printf("\n%.05fs for %d GETs so far, %.05f GETs per second, %.05f MB/s...", float64(elapsed)/float64(time.Second), soFar, float64(soFar)/float64(elapsed/time.Second), megabytesPerSecond(atomic.LoadInt64(&bytesReceived), elapsed))
 				case benchChan <- i:
 					waiting = false
+					requests++
 				}
 			}
 		}
@@ -718,23 +1595,27 @@ func (cli *CLIInstance) benchGet(c Client, args []string) {
 	close(benchChan)
 	wg.Wait()
 	stop := time.Now()
+	csvot.finish(stop)
 	elapsed := stop.Sub(start)
 	ticker.Stop()
 	fmt.Println()
-	fmt.Printf("%.05fs total time, %.05f GETs per second.\n", float64(elapsed)/float64(time.Second), float64(iterations*count)/float64(elapsed/time.Second))
-	if csvotw != nil {
-		csvotw.Write([]string{
-			fmt.Sprintf("%d", stop.UnixNano()),
-			fmt.Sprintf("%d", iterations*count-lastSoFar),
-		})
-		csvotw.Flush()
+	if interrupt.interrupted() {
+		fmt.Printf("Interrupted after %d of %d GETs.\n", requests, iterations*count)
 	}
+	fmt.Printf("%.05fs total time, %.05f GETs per second, %.05f MB/s.\n", float64(elapsed)/float64(time.Second), float64(requests)/float64(elapsed/time.Second), megabytesPerSecond(bytesReceived, elapsed))
+	usage := resources.stop()
+	fmt.Println(usage)
+	results.finish(elapsed, int64(requests), bytesReceived, concurrency, usage)
+	cli.benchCloseCSV(csvw, csvotw)
 }
 
 func (cli *CLIInstance) benchHead(c Client, args []string) {
 	if err := cli.BenchHeadFlags.Parse(args); err != nil {
 		cli.fatal(cli, err)
 	}
+	defer cli.benchProfile()()
+	results := cli.newBenchResults(c, "bench-head", args, *cli.benchHeadFlagCSV, *cli.benchHeadFlagCSVOT)
+	defer results.store()
 	container, object := parsePath(cli.BenchHeadFlags.Args())
 	if container == "" {
 		cli.fatalf(cli, "bench-head requires <container>\n")
@@ -750,36 +1631,22 @@ func (cli *CLIInstance) benchHead(c Client, args []string) {
 	if count < 1 {
 		count = 1000
 	}
-	var csvw *csv.Writer
-	var csvlk sync.Mutex
+	var csvw *csvWriter
 	if *cli.benchHeadFlagCSV != "" {
-		csvf, err := os.Create(*cli.benchHeadFlagCSV)
-		if err != nil {
+		var err error
+		if csvw, err = newCSVWriter(*cli.benchHeadFlagCSV); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvw = csv.NewWriter(csvf)
-		defer func() {
-			csvw.Flush()
-			csvf.Close()
-		}()
-		csvw.Write([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "headers_elapsed_nanoseconds", "elapsed_nanoseconds"})
-		csvw.Flush()
+		csvw.Write(append([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "headers_elapsed_nanoseconds", "elapsed_nanoseconds", "retries", "final_attempt_succeeded"}, cli.benchResponseColumns()...))
 	}
-	var csvotw *csv.Writer
+	var csvotw *csvWriter
 	if *cli.benchHeadFlagCSVOT != "" {
-		csvotf, err := os.Create(*cli.benchHeadFlagCSVOT)
-		if err != nil {
+		var err error
+		if csvotw, err = newCSVWriter(*cli.benchHeadFlagCSVOT); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvotw = csv.NewWriter(csvotf)
-		defer func() {
-			csvotw.Flush()
-			csvotf.Close()
-		}()
-		csvotw.Write([]string{"time_unix_nano", "count_since_last_time"})
-		csvotw.Write([]string{fmt.Sprintf("%d", time.Now().UnixNano()), "0"})
-		csvotw.Flush()
 	}
+	csvot := newOverTimeCSV(csvotw, cli.progressInterval(time.Minute), "count_since_last_time")
 	iterations := *cli.benchHeadFlagIterations
 	if iterations < 1 {
 		iterations = 1
@@ -788,6 +1655,7 @@ func (cli *CLIInstance) benchHead(c Client, args []string) {
 	if concurrency < 1 {
 		concurrency = 1
 	}
+	concurrency, limiter := cli.autoConcurrency(concurrency, "HEAD")
 	benchChan := make(chan int, concurrency)
 	wg := sync.WaitGroup{}
 	wg.Add(concurrency)
@@ -801,27 +1669,27 @@ func (cli *CLIInstance) benchHead(c Client, args []string) {
 					break
 				}
 				i--
-				headContainer := container
-				if containers > 1 {
-					headContainer = fmt.Sprintf("%s%d", headContainer, i%containers)
-				}
+				headContainer := benchContainer(container, containers, i%containers)
 				headObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "HEAD %s/%s\n", headContainer, headObject)
 				if csvw != nil {
 					start = time.Now()
 				}
+				limiter.acquire()
+				opStart := time.Now()
 				resp := c.HeadObject(headContainer, headObject, cli.globalFlagHeaders.Headers())
+				limiter.release(opStart, resp.StatusCode)
+				csvot.add(0)
 				if csvw != nil {
 					headers_elapsed = time.Now().Sub(start).Nanoseconds()
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "HEAD %s/%s - %d %s - %s\n", headContainer, headObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "HEAD %s/%s - %d %s - %s\n", headContainer, headObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
 				} else {
 					io.Copy(ioutil.Discard, resp.Body)
@@ -830,17 +1698,16 @@ func (cli *CLIInstance) benchHead(c Client, args []string) {
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						headContainer + "/" + headObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", headers_elapsed),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 			}
 			wg.Done()
@@ -851,29 +1718,29 @@ func (cli *CLIInstance) benchHead(c Client, args []string) {
 	} else {
 		fmt.Printf("Bench-HEAD of %d (%d distinct) objects, distributed across %d containers, at %d concurrency...", iterations*count, count, containers, concurrency)
 	}
-	ticker := time.NewTicker(time.Minute)
+	interrupt := cli.notifyInterrupt()
+	defer interrupt.stop()
+	ticker := time.NewTicker(cli.progressInterval(time.Minute))
+	resources := newResourceSampler(c)
 	start := time.Now()
-	lastSoFar := 0
+	csvot.begin(start)
+	requests := 0
+feeding:
 	for iteration := 0; iteration < iterations; iteration++ {
 		for i := 1; i <= count; i++ {
 			waiting := true
 			for waiting {
 				select {
+				case <-interrupt.done():
+					break feeding
 				case <-ticker.C:
 					soFar := iteration*count + i - concurrency
 					now := time.Now()
 					elapsed := now.Sub(start)
 					fmt.Printf("\n%.05fs for %d HEADs so far, %.05f HEADs per second...", float64(elapsed)/float64(time.Second), soFar, float64(soFar)/float64(elapsed/time.Second))
-					if csvotw != nil {
-						csvotw.Write([]string{
-							fmt.Sprintf("%d", now.UnixNano()),
-							fmt.Sprintf("%d", soFar-lastSoFar),
-						})
-						csvotw.Flush()
-						lastSoFar = soFar
-					}
 				case benchChan <- i:
 					waiting = false
+					requests++
 				}
 			}
 		}
@@ -881,23 +1748,27 @@ func (cli *CLIInstance) benchHead(c Client, args []string) {
 	close(benchChan)
 	wg.Wait()
 	stop := time.Now()
+	csvot.finish(stop)
 	elapsed := stop.Sub(start)
 	ticker.Stop()
 	fmt.Println()
-	fmt.Printf("%.05fs total time, %.05f HEADs per second.\n", float64(elapsed)/float64(time.Second), float64(iterations*count)/float64(elapsed/time.Second))
-	if csvotw != nil {
-		csvotw.Write([]string{
-			fmt.Sprintf("%d", stop.UnixNano()),
-			fmt.Sprintf("%d", iterations*count-lastSoFar),
-		})
-		csvotw.Flush()
+	if interrupt.interrupted() {
+		fmt.Printf("Interrupted after %d of %d HEADs.\n", requests, iterations*count)
 	}
+	fmt.Printf("%.05fs total time, %.05f HEADs per second.\n", float64(elapsed)/float64(time.Second), float64(requests)/float64(elapsed/time.Second))
+	usage := resources.stop()
+	fmt.Println(usage)
+	results.finish(elapsed, int64(requests), 0, concurrency, usage)
+	cli.benchCloseCSV(csvw, csvotw)
 }
 
 func (cli *CLIInstance) benchMixed(c Client, args []string) {
 	if err := cli.BenchMixedFlags.Parse(args); err != nil {
 		cli.fatal(cli, err)
 	}
+	defer cli.benchProfile()()
+	results := cli.newBenchResults(c, "bench-mixed", args, *cli.benchMixedFlagCSV, *cli.benchMixedFlagCSVOT)
+	defer results.store()
 	container, object := parsePath(cli.BenchMixedFlags.Args())
 	if container == "" {
 		cli.fatalf(cli, "bench-mixed requires <container>\n")
@@ -931,64 +1802,49 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 		"POST",
 		"PUT",
 	}
-	var csvw *csv.Writer
-	var csvlk sync.Mutex
+	var csvw *csvWriter
 	if *cli.benchMixedFlagCSV != "" {
-		csvf, err := os.Create(*cli.benchMixedFlagCSV)
-		if err != nil {
+		var err error
+		if csvw, err = newCSVWriter(*cli.benchMixedFlagCSV); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvw = csv.NewWriter(csvf)
-		defer func() {
-			csvw.Flush()
-			csvf.Close()
-		}()
-		csvw.Write([]string{"completion_time_unix_nano", "method", "object_name", "transaction_id", "status", "elapsed_nanoseconds"})
-		csvw.Flush()
+		csvw.Write(append([]string{"completion_time_unix_nano", "method", "object_name", "transaction_id", "status", "elapsed_nanoseconds", "bytes", "retries", "final_attempt_succeeded"}, cli.benchResponseColumns()...))
 	}
-	var csvotw *csv.Writer
+	var csvotw *csvWriter
 	if *cli.benchMixedFlagCSVOT != "" {
-		csvotf, err := os.Create(*cli.benchMixedFlagCSVOT)
-		if err != nil {
+		var err error
+		if csvotw, err = newCSVWriter(*cli.benchMixedFlagCSVOT); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvotw = csv.NewWriter(csvotf)
-		defer func() {
-			csvotw.Flush()
-			csvotf.Close()
-		}()
-		csvotw.Write([]string{"time_unix_nano", "DELETE", "GET", "HEAD", "POST", "PUT"})
-		csvotw.Write([]string{fmt.Sprintf("%d", time.Now().UnixNano()), "0", "0", "0", "0", "0", "0"})
-		csvotw.Flush()
 	}
+	csvot := newOverTimeCSV(csvotw, cli.progressInterval(time.Minute), methods...)
 	if containers == 1 {
 		fmt.Printf("Ensuring container exists...")
-		cli.verbosef(cli, "PUT %s\n", container)
-		resp := c.PutContainer(container, cli.globalFlagHeaders.Headers())
+		oneContainer := benchContainer(container, 1, 0)
+		cli.verbosef(cli, "PUT %s\n", oneContainer)
+		resp := c.PutContainer(oneContainer, cli.newContainerHeaders(c, oneContainer))
 		if resp.StatusCode/100 != 2 {
-			bodyBytes, _ := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
+			err := NewResponseError(resp)
 			if *cli.globalFlagContinueOnError {
-				fmt.Fprintf(os.Stderr, "PUT %s - %d %s - %s\n", container, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+				fmt.Fprintln(os.Stderr, err)
 			} else {
-				cli.fatalf(cli, "PUT %s - %d %s - %s\n", container, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+				cli.fatal(cli, err)
 			}
 		}
 		resp.Body.Close()
 	} else {
 		fmt.Printf("Ensuring %d containers exist...", containers)
 		for x := 0; x < containers; x++ {
-			putContainer := fmt.Sprintf("%s%d", container, x)
+			putContainer := benchContainer(container, containers, x)
 			cli.verbosef(cli, "PUT %s\n", putContainer)
-			resp := c.PutContainer(putContainer, cli.globalFlagHeaders.Headers())
+			resp := c.PutContainer(putContainer, cli.newContainerHeaders(c, putContainer))
 			if resp.StatusCode/100 != 2 {
-				bodyBytes, _ := ioutil.ReadAll(resp.Body)
-				resp.Body.Close()
+				err := NewResponseError(resp)
 				if *cli.globalFlagContinueOnError {
-					fmt.Fprintf(os.Stderr, "PUT %s - %d %s - %s\n", putContainer, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+					fmt.Fprintln(os.Stderr, err)
 					continue
 				} else {
-					cli.fatalf(cli, "PUT %s - %d %s - %s\n", putContainer, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+					cli.fatal(cli, err)
 				}
 			}
 			resp.Body.Close()
@@ -999,10 +1855,19 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 	if concurrency < 1 {
 		concurrency = 1
 	}
+	limiters := make([]*adaptiveLimiter, len(methods))
+	for op, method := range methods {
+		concurrency, limiters[op] = cli.autoConcurrency(concurrency, method)
+	}
 	timespanTicker := time.NewTicker(timespan)
+	interrupt := cli.notifyInterrupt()
+	defer interrupt.stop()
 	doneChan := make(chan bool)
 	go func() {
-		<-timespanTicker.C
+		select {
+		case <-timespanTicker.C:
+		case <-interrupt.done():
+		}
 		close(doneChan)
 	}()
 	deleteChan := make(chan int, concurrency)
@@ -1016,6 +1881,11 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 	var heads int64
 	var posts int64
 	var puts int64
+	var bytesTransferred int64
+	latencies := make([]*latencyStats, len(methods))
+	for op := range methods {
+		latencies[op] = newLatencyStats()
+	}
 	for x := 0; x < concurrency; x++ {
 		wg.Add(1)
 		go func() {
@@ -1029,40 +1899,41 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 					return
 				case i = <-deleteChan:
 				}
-				opContainer := container
-				if containers > 1 {
-					opContainer = fmt.Sprintf("%s%d", opContainer, i%containers)
-				}
+				opContainer := benchContainer(container, containers, i%containers)
 				opObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "%s %s/%s\n", methods[op], opContainer, opObject)
 				if csvw != nil {
 					start = time.Now()
 				}
+				limiters[op].acquire()
+				opStart := time.Now()
 				resp := c.DeleteObject(opContainer, opObject, cli.globalFlagHeaders.Headers())
+				limiters[op].release(opStart, resp.StatusCode)
+				csvot.add(op)
 				atomic.AddInt64(&deletes, 1)
+				latencies[op].record(time.Since(opStart), resp.StatusCode/100 != 2)
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						methods[op],
 						opContainer + "/" + opObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						"0",
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
 				} else {
 					io.Copy(ioutil.Discard, resp.Body)
@@ -1082,43 +1953,49 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 					return
 				case i = <-getChan:
 				}
-				opContainer := container
-				if containers > 1 {
-					opContainer = fmt.Sprintf("%s%d", opContainer, i%containers)
-				}
+				opContainer := benchContainer(container, containers, i%containers)
 				opObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "%s %s/%s\n", methods[op], opContainer, opObject)
 				if csvw != nil {
 					start = time.Now()
 				}
+				limiters[op].acquire()
+				opStart := time.Now()
 				resp := c.GetObject(opContainer, opObject, cli.globalFlagHeaders.Headers())
+				limiters[op].release(opStart, resp.StatusCode)
+				csvot.add(op)
 				atomic.AddInt64(&gets, 1)
+				// The body is read before the timing is recorded so that, as
+				// with bench-get, the elapsed time covers the transfer.
+				var n int64
+				if resp.StatusCode/100 == 2 {
+					n, _ = io.Copy(ioutil.Discard, resp.Body)
+					atomic.AddInt64(&bytesTransferred, n)
+				}
+				latencies[op].record(time.Since(opStart), resp.StatusCode/100 != 2)
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						methods[op],
 						opContainer + "/" + opObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						fmt.Sprintf("%d", n),
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
-				} else {
-					io.Copy(ioutil.Discard, resp.Body)
 				}
 				resp.Body.Close()
 			}
@@ -1135,40 +2012,41 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 					return
 				case i = <-headChan:
 				}
-				opContainer := container
-				if containers > 1 {
-					opContainer = fmt.Sprintf("%s%d", opContainer, i%containers)
-				}
+				opContainer := benchContainer(container, containers, i%containers)
 				opObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "%s %s/%s\n", methods[op], opContainer, opObject)
 				if csvw != nil {
 					start = time.Now()
 				}
+				limiters[op].acquire()
+				opStart := time.Now()
 				resp := c.HeadObject(opContainer, opObject, cli.globalFlagHeaders.Headers())
+				limiters[op].release(opStart, resp.StatusCode)
+				csvot.add(op)
 				atomic.AddInt64(&heads, 1)
+				latencies[op].record(time.Since(opStart), resp.StatusCode/100 != 2)
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						methods[op],
 						opContainer + "/" + opObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						"0",
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
 				} else {
 					io.Copy(ioutil.Discard, resp.Body)
@@ -1188,10 +2066,7 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 					return
 				case i = <-postChan:
 				}
-				opContainer := container
-				if containers > 1 {
-					opContainer = fmt.Sprintf("%s%d", opContainer, i%containers)
-				}
+				opContainer := benchContainer(container, containers, i%containers)
 				opObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "%s %s/%s\n", methods[op], opContainer, opObject)
 				if csvw != nil {
@@ -1199,31 +2074,35 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 				}
 				headers := cli.globalFlagHeaders.Headers()
 				headers["X-Object-Meta-Bench-Mixed"] = strconv.Itoa(i)
+				limiters[op].acquire()
+				opStart := time.Now()
 				resp := c.PostObject(opContainer, opObject, headers)
+				limiters[op].release(opStart, resp.StatusCode)
+				csvot.add(op)
 				atomic.AddInt64(&posts, 1)
+				latencies[op].record(time.Since(opStart), resp.StatusCode/100 != 2)
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						methods[op],
 						opContainer + "/" + opObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						"0",
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
 				} else {
 					io.Copy(ioutil.Discard, resp.Body)
@@ -1244,40 +2123,46 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 					return
 				case i = <-putChan:
 				}
-				opContainer := container
-				if containers > 1 {
-					opContainer = fmt.Sprintf("%s%d", opContainer, i%containers)
-				}
+				opContainer := benchContainer(container, containers, i%containers)
 				opObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "%s %s/%s\n", methods[op], opContainer, opObject)
 				if csvw != nil {
 					start = time.Now()
 				}
+				limiters[op].acquire()
+				opStart := time.Now()
 				resp := c.PutObject(opContainer, opObject, cli.globalFlagHeaders.Headers(), &io.LimitedReader{R: rnd, N: size})
+				limiters[op].release(opStart, resp.StatusCode)
+				csvot.add(op)
 				atomic.AddInt64(&puts, 1)
+				var n int64
+				if resp.StatusCode/100 == 2 {
+					n = size
+					atomic.AddInt64(&bytesTransferred, n)
+				}
+				latencies[op].record(time.Since(opStart), resp.StatusCode/100 != 2)
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						methods[op],
 						opContainer + "/" + opObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						fmt.Sprintf("%d", n),
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "%s %s/%s - %d %s - %s\n", methods[op], opContainer, opObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
 				} else {
 					io.Copy(ioutil.Discard, resp.Body)
@@ -1291,13 +2176,10 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 	} else {
 		fmt.Printf("Bench-Mixed for %s, each object is %d bytes, distributed across %d containers, at %d concurrency...", timespan, size, containers, concurrency)
 	}
-	updateTicker := time.NewTicker(time.Minute)
+	updateTicker := time.NewTicker(cli.progressInterval(time.Minute))
+	resources := newResourceSampler(c)
 	start := time.Now()
-	var lastDeletes int64
-	var lastGets int64
-	var lastHeads int64
-	var lastPosts int64
-	var lastPuts int64
+	csvot.begin(start)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -1306,31 +2188,9 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 			case <-doneChan:
 				return
 			case <-updateTicker.C:
-				now := time.Now()
-				elapsed := now.Sub(start)
-				snapshotDeletes := atomic.LoadInt64(&deletes)
-				snapshotGets := atomic.LoadInt64(&gets)
-				snapshotHeads := atomic.LoadInt64(&heads)
-				snapshotPosts := atomic.LoadInt64(&posts)
-				snapshotPuts := atomic.LoadInt64(&puts)
-				total := snapshotDeletes + snapshotGets + snapshotHeads + snapshotPosts + snapshotPuts
-				fmt.Printf("\n%.05fs for %d requests so far, %.05f requests per second...", float64(elapsed)/float64(time.Second), total, float64(total)/float64(elapsed/time.Second))
-				if csvotw != nil {
-					csvotw.Write([]string{
-						fmt.Sprintf("%d", now.UnixNano()),
-						fmt.Sprintf("%d", snapshotDeletes-lastDeletes),
-						fmt.Sprintf("%d", snapshotGets-lastGets),
-						fmt.Sprintf("%d", snapshotHeads-lastHeads),
-						fmt.Sprintf("%d", snapshotPosts-lastPosts),
-						fmt.Sprintf("%d", snapshotPuts-lastPuts),
-					})
-					csvotw.Flush()
-					lastDeletes = snapshotDeletes
-					lastGets = snapshotGets
-					lastHeads = snapshotHeads
-					lastPosts = snapshotPosts
-					lastPuts = snapshotPuts
-				}
+				elapsed := time.Now().Sub(start)
+				total := atomic.LoadInt64(&deletes) + atomic.LoadInt64(&gets) + atomic.LoadInt64(&heads) + atomic.LoadInt64(&posts) + atomic.LoadInt64(&puts)
+				fmt.Printf("\n%.05fs for %d requests so far, %.05f requests per second, %.05f MB/s...", float64(elapsed)/float64(time.Second), total, float64(total)/float64(elapsed/time.Second), megabytesPerSecond(atomic.LoadInt64(&bytesTransferred), elapsed))
 			}
 		}
 	}()
@@ -1462,29 +2322,44 @@ func (cli *CLIInstance) benchMixed(c Client, args []string) {
 	}()
 	wg.Wait()
 	stop := time.Now()
+	csvot.finish(stop)
 	elapsed := stop.Sub(start)
 	timespanTicker.Stop()
 	updateTicker.Stop()
 	fmt.Println()
 	total := deletes + gets + heads + posts + puts
-	fmt.Printf("%.05fs for %d requests, %.05f requests per second.\n", float64(elapsed)/float64(time.Second), total, float64(total)/float64(elapsed/time.Second))
-	if csvotw != nil {
-		csvotw.Write([]string{
-			fmt.Sprintf("%d", stop.UnixNano()),
-			fmt.Sprintf("%d", deletes-lastDeletes),
-			fmt.Sprintf("%d", gets-lastGets),
-			fmt.Sprintf("%d", heads-lastHeads),
-			fmt.Sprintf("%d", posts-lastPosts),
-			fmt.Sprintf("%d", puts-lastPuts),
+	if interrupt.interrupted() {
+		fmt.Printf("Interrupted before the %s was up.\n", timespan)
+	}
+	fmt.Printf("%.05fs for %d requests, %.05f MB/s.\n", float64(elapsed)/float64(time.Second), total, megabytesPerSecond(bytesTransferred, elapsed))
+	// The methods are broken out as their performance differs so much that
+	// the aggregate rate would hide a regression in any one of them.
+	data := [][]string{{"Method", "Count", "Errors", "Per Second", "p50 ms", "p99 ms"}}
+	for op, method := range methods {
+		l := latencies[op]
+		data = append(data, []string{
+			method,
+			fmt.Sprintf("%d", l.count),
+			fmt.Sprintf("%d", l.errors),
+			fmt.Sprintf("%.02f", float64(l.count)/elapsed.Seconds()),
+			fmt.Sprintf("%.03f", float64(l.percentile(50))/float64(time.Millisecond)),
+			fmt.Sprintf("%.03f", float64(l.percentile(99))/float64(time.Millisecond)),
 		})
-		csvotw.Flush()
 	}
+	cli.table(data, nil)
+	usage := resources.stop()
+	fmt.Println(usage)
+	results.finish(elapsed, total, bytesTransferred, concurrency, usage)
+	cli.benchCloseCSV(csvw, csvotw)
 }
 
 func (cli *CLIInstance) benchPost(c Client, args []string) {
 	if err := cli.BenchPostFlags.Parse(args); err != nil {
 		cli.fatal(cli, err)
 	}
+	defer cli.benchProfile()()
+	results := cli.newBenchResults(c, "bench-post", args, *cli.benchPostFlagCSV, *cli.benchPostFlagCSVOT)
+	defer results.store()
 	container, object := parsePath(cli.BenchPostFlags.Args())
 	if container == "" {
 		cli.fatalf(cli, "bench-post requires <container>\n")
@@ -1500,40 +2375,27 @@ func (cli *CLIInstance) benchPost(c Client, args []string) {
 	if count < 1 {
 		count = 1000
 	}
-	var csvw *csv.Writer
-	var csvlk sync.Mutex
+	var csvw *csvWriter
 	if *cli.benchPostFlagCSV != "" {
-		csvf, err := os.Create(*cli.benchPostFlagCSV)
-		if err != nil {
+		var err error
+		if csvw, err = newCSVWriter(*cli.benchPostFlagCSV); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvw = csv.NewWriter(csvf)
-		defer func() {
-			csvw.Flush()
-			csvf.Close()
-		}()
-		csvw.Write([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "elapsed_nanoseconds"})
-		csvw.Flush()
+		csvw.Write(append([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "elapsed_nanoseconds", "retries", "final_attempt_succeeded"}, cli.benchResponseColumns()...))
 	}
-	var csvotw *csv.Writer
+	var csvotw *csvWriter
 	if *cli.benchPostFlagCSVOT != "" {
-		csvotf, err := os.Create(*cli.benchPostFlagCSVOT)
-		if err != nil {
+		var err error
+		if csvotw, err = newCSVWriter(*cli.benchPostFlagCSVOT); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvotw = csv.NewWriter(csvotf)
-		defer func() {
-			csvotw.Flush()
-			csvotf.Close()
-		}()
-		csvotw.Write([]string{"time_unix_nano", "count_since_last_time"})
-		csvotw.Write([]string{fmt.Sprintf("%d", time.Now().UnixNano()), "0"})
-		csvotw.Flush()
 	}
+	csvot := newOverTimeCSV(csvotw, cli.progressInterval(time.Minute), "count_since_last_time")
 	concurrency := *cli.globalFlagConcurrency
 	if concurrency < 1 {
 		concurrency = 1
 	}
+	concurrency, limiter := cli.autoConcurrency(concurrency, "POST")
 	benchChan := make(chan int, concurrency)
 	wg := sync.WaitGroup{}
 	wg.Add(concurrency)
@@ -1546,38 +2408,37 @@ func (cli *CLIInstance) benchPost(c Client, args []string) {
 					break
 				}
 				i--
-				postContainer := container
-				if containers > 1 {
-					postContainer = fmt.Sprintf("%s%d", postContainer, i%containers)
-				}
+				postContainer := benchContainer(container, containers, i%containers)
 				postObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "POST %s/%s\n", postContainer, postObject)
 				if csvw != nil {
 					start = time.Now()
 				}
+				limiter.acquire()
+				opStart := time.Now()
 				resp := c.PostObject(postContainer, postObject, cli.globalFlagHeaders.Headers())
+				limiter.release(opStart, resp.StatusCode)
+				csvot.add(0)
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						postContainer + "/" + postObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "POST %s/%s - %d %s - %s\n", postContainer, postObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "POST %s/%s - %d %s - %s\n", postContainer, postObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
 				}
 				resp.Body.Close()
@@ -1590,51 +2451,55 @@ func (cli *CLIInstance) benchPost(c Client, args []string) {
 	} else {
 		fmt.Printf("Bench-POST of %d objects, distributed across %d containers, at %d concurrency...", count, containers, concurrency)
 	}
-	ticker := time.NewTicker(time.Minute)
+	interrupt := cli.notifyInterrupt()
+	defer interrupt.stop()
+	ticker := time.NewTicker(cli.progressInterval(time.Minute))
+	resources := newResourceSampler(c)
 	start := time.Now()
-	lastSoFar := 0
+	csvot.begin(start)
+	requests := 0
+feeding:
 	for i := 1; i <= count; i++ {
 		waiting := true
 		for waiting {
 			select {
+			case <-interrupt.done():
+				break feeding
 			case <-ticker.C:
 				soFar := i - concurrency
 				now := time.Now()
 				elapsed := now.Sub(start)
 				fmt.Printf("\n%.05fs for %d POSTs so far, %.05f POSTs per second...", float64(elapsed)/float64(time.Second), soFar, float64(soFar)/float64(elapsed/time.Second))
-				if csvotw != nil {
-					csvotw.Write([]string{
-						fmt.Sprintf("%d", now.UnixNano()),
-						fmt.Sprintf("%d", soFar-lastSoFar),
-					})
-					csvotw.Flush()
-					lastSoFar = soFar
-				}
 			case benchChan <- i:
 				waiting = false
+				requests++
 			}
 		}
 	}
 	close(benchChan)
 	wg.Wait()
 	stop := time.Now()
+	csvot.finish(stop)
 	elapsed := stop.Sub(start)
 	ticker.Stop()
 	fmt.Println()
-	fmt.Printf("%.05fs total time, %.05f POSTs per second.\n", float64(elapsed)/float64(time.Second), float64(count)/float64(elapsed/time.Second))
-	if csvotw != nil {
-		csvotw.Write([]string{
-			fmt.Sprintf("%d", stop.UnixNano()),
-			fmt.Sprintf("%d", count-lastSoFar),
-		})
-		csvotw.Flush()
+	if interrupt.interrupted() {
+		fmt.Printf("Interrupted after %d of %d POSTs.\n", requests, count)
 	}
+	fmt.Printf("%.05fs total time, %.05f POSTs per second.\n", float64(elapsed)/float64(time.Second), float64(requests)/float64(elapsed/time.Second))
+	usage := resources.stop()
+	fmt.Println(usage)
+	results.finish(elapsed, int64(requests), 0, concurrency, usage)
+	cli.benchCloseCSV(csvw, csvotw)
 }
 
 func (cli *CLIInstance) benchPut(c Client, args []string) {
 	if err := cli.BenchPutFlags.Parse(args); err != nil {
 		cli.fatal(cli, err)
 	}
+	defer cli.benchProfile()()
+	results := cli.newBenchResults(c, "bench-put", args, *cli.benchPutFlagCSV, *cli.benchPutFlagCSVOT)
+	defer results.store()
 	container, object := parsePath(cli.BenchPutFlags.Args())
 	if container == "" {
 		cli.fatalf(cli, "bench-put requires <container>\n")
@@ -1658,64 +2523,60 @@ func (cli *CLIInstance) benchPut(c Client, args []string) {
 	if maxsize < size {
 		maxsize = size
 	}
-	var csvw *csv.Writer
-	var csvlk sync.Mutex
-	if *cli.benchPutFlagCSV != "" {
-		csvf, err := os.Create(*cli.benchPutFlagCSV)
+	var deleteAfter string
+	if *cli.benchPutFlagDeleteAfter != "" {
+		d, err := time.ParseDuration(*cli.benchPutFlagDeleteAfter)
 		if err != nil {
 			cli.fatal(cli, err)
 		}
-		csvw = csv.NewWriter(csvf)
-		defer func() {
-			csvw.Flush()
-			csvf.Close()
-		}()
-		csvw.Write([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "elapsed_nanoseconds"})
-		csvw.Flush()
+		if d <= 0 {
+			cli.fatalf(cli, "-delete-after must be positive\n")
+		}
+		deleteAfter = strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+	}
+	var csvw *csvWriter
+	if *cli.benchPutFlagCSV != "" {
+		var err error
+		if csvw, err = newCSVWriter(*cli.benchPutFlagCSV); err != nil {
+			cli.fatal(cli, err)
+		}
+		csvw.Write(append([]string{"completion_time_unix_nano", "object_name", "transaction_id", "status", "elapsed_nanoseconds", "bytes", "retries", "final_attempt_succeeded"}, cli.benchResponseColumns()...))
 	}
-	var csvotw *csv.Writer
+	var csvotw *csvWriter
 	if *cli.benchPutFlagCSVOT != "" {
-		csvotf, err := os.Create(*cli.benchPutFlagCSVOT)
-		if err != nil {
+		var err error
+		if csvotw, err = newCSVWriter(*cli.benchPutFlagCSVOT); err != nil {
 			cli.fatal(cli, err)
 		}
-		csvotw = csv.NewWriter(csvotf)
-		defer func() {
-			csvotw.Flush()
-			csvotf.Close()
-		}()
-		csvotw.Write([]string{"time_unix_nano", "count_since_last_time"})
-		csvotw.Write([]string{fmt.Sprintf("%d", time.Now().UnixNano()), "0"})
-		csvotw.Flush()
 	}
+	csvot := newOverTimeCSV(csvotw, cli.progressInterval(time.Minute), "count_since_last_time")
 	if containers == 1 {
 		fmt.Printf("Ensuring container exists...")
-		cli.verbosef(cli, "PUT %s\n", container)
-		resp := c.PutContainer(container, cli.globalFlagHeaders.Headers())
+		oneContainer := benchContainer(container, 1, 0)
+		cli.verbosef(cli, "PUT %s\n", oneContainer)
+		resp := c.PutContainer(oneContainer, cli.newContainerHeaders(c, oneContainer))
 		if resp.StatusCode/100 != 2 {
-			bodyBytes, _ := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
+			err := NewResponseError(resp)
 			if *cli.globalFlagContinueOnError {
-				fmt.Fprintf(os.Stderr, "PUT %s - %d %s - %s\n", container, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+				fmt.Fprintln(os.Stderr, err)
 			} else {
-				cli.fatalf(cli, "PUT %s - %d %s - %s\n", container, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+				cli.fatal(cli, err)
 			}
 		}
 		resp.Body.Close()
 	} else {
 		fmt.Printf("Ensuring %d containers exist...", containers)
 		for x := 0; x < containers; x++ {
-			putContainer := fmt.Sprintf("%s%d", container, x)
+			putContainer := benchContainer(container, containers, x)
 			cli.verbosef(cli, "PUT %s\n", putContainer)
-			resp := c.PutContainer(putContainer, cli.globalFlagHeaders.Headers())
+			resp := c.PutContainer(putContainer, cli.newContainerHeaders(c, putContainer))
 			if resp.StatusCode/100 != 2 {
-				bodyBytes, _ := ioutil.ReadAll(resp.Body)
-				resp.Body.Close()
+				err := NewResponseError(resp)
 				if *cli.globalFlagContinueOnError {
-					fmt.Fprintf(os.Stderr, "PUT %s - %d %s - %s\n", putContainer, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+					fmt.Fprintln(os.Stderr, err)
 					continue
 				} else {
-					cli.fatalf(cli, "PUT %s - %d %s - %s\n", putContainer, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+					cli.fatal(cli, err)
 				}
 			}
 			resp.Body.Close()
@@ -1726,9 +2587,11 @@ func (cli *CLIInstance) benchPut(c Client, args []string) {
 	if concurrency < 1 {
 		concurrency = 1
 	}
+	concurrency, limiter := cli.autoConcurrency(concurrency, "PUT")
 	benchChan := make(chan int, concurrency)
 	wg := sync.WaitGroup{}
 	wg.Add(concurrency)
+	var bytesSent int64
 	for x := 0; x < concurrency; x++ {
 		go func() {
 			rnd := NewRand(time.Now().UnixNano())
@@ -1739,42 +2602,53 @@ func (cli *CLIInstance) benchPut(c Client, args []string) {
 					break
 				}
 				i--
-				putContainer := container
-				if containers > 1 {
-					putContainer = fmt.Sprintf("%s%d", putContainer, i%containers)
-				}
+				putContainer := benchContainer(container, containers, i%containers)
 				putObject := fmt.Sprintf("%s%d", object, i)
 				cli.verbosef(cli, "PUT %s/%s\n", putContainer, putObject)
 				if csvw != nil {
 					start = time.Now()
 				}
 				sz := size
-				if maxsize > size {
+				if len(cli.benchPutFlagSizeDist) > 0 {
+					sz = cli.benchPutFlagSizeDist.pick(rnd)
+				} else if maxsize > size {
 					sz += int64(rnd.Intn(int(maxsize - size)))
 				}
-				resp := c.PutObject(putContainer, putObject, cli.globalFlagHeaders.Headers(), &io.LimitedReader{R: rnd, N: sz})
+				headers := cli.globalFlagHeaders.Headers()
+				if deleteAfter != "" {
+					headers["X-Delete-After"] = deleteAfter
+				}
+				limiter.acquire()
+				opStart := time.Now()
+				resp := c.PutObject(putContainer, putObject, headers, &io.LimitedReader{R: rnd, N: sz})
+				limiter.release(opStart, resp.StatusCode)
+				csvot.add(0)
+				var n int64
+				if resp.StatusCode/100 == 2 {
+					n = sz
+					atomic.AddInt64(&bytesSent, n)
+				}
 				if csvw != nil {
 					stop := time.Now()
 					elapsed := stop.Sub(start).Nanoseconds()
-					csvlk.Lock()
-					csvw.Write([]string{
+					csvw.Write(append([]string{
 						fmt.Sprintf("%d", stop.UnixNano()),
 						putContainer + "/" + putObject,
 						resp.Header.Get("X-Trans-Id"),
 						fmt.Sprintf("%d", resp.StatusCode),
 						fmt.Sprintf("%d", elapsed),
-					})
-					csvw.Flush()
-					csvlk.Unlock()
+						fmt.Sprintf("%d", n),
+						fmt.Sprintf("%d", ResponseRetries(resp)),
+						strconv.FormatBool(resp.StatusCode/100 == 2),
+					}, cli.benchResponseFields(resp)...))
 				}
 				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
+					err := NewResponseError(resp)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "PUT %s/%s - %d %s - %s\n", putContainer, putObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "PUT %s/%s - %d %s - %s\n", putContainer, putObject, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+						cli.fatal(cli, err)
 					}
 				}
 				resp.Body.Close()
@@ -1783,171 +2657,585 @@ func (cli *CLIInstance) benchPut(c Client, args []string) {
 		}()
 	}
 	var sz string
-	if maxsize > size {
-		sz = fmt.Sprintf("%d-%d", size, maxsize)
+	if len(cli.benchPutFlagSizeDist) > 0 {
+		sz = "sized " + cli.benchPutFlagSizeDist.String()
+	} else if maxsize > size {
+		sz = fmt.Sprintf("each %d-%d bytes", size, maxsize)
 	} else {
-		sz = fmt.Sprintf("%d", size)
+		sz = fmt.Sprintf("each %d bytes", size)
 	}
 	if containers == 1 {
-		fmt.Printf("Bench-PUT of %d objects, each %s bytes, into 1 container, at %d concurrency...", count, sz, concurrency)
+		fmt.Printf("Bench-PUT of %d objects, %s, into 1 container, at %d concurrency...", count, sz, concurrency)
 	} else {
-		fmt.Printf("Bench-PUT of %d objects, each %s bytes, distributed across %d containers, at %d concurrency...", count, sz, containers, concurrency)
+		fmt.Printf("Bench-PUT of %d objects, %s, distributed across %d containers, at %d concurrency...", count, sz, containers, concurrency)
 	}
-	ticker := time.NewTicker(time.Minute)
+	interrupt := cli.notifyInterrupt()
+	defer interrupt.stop()
+	ticker := time.NewTicker(cli.progressInterval(time.Minute))
+	resources := newResourceSampler(c)
 	start := time.Now()
-	lastSoFar := 0
+	csvot.begin(start)
+	requests := 0
+feeding:
 	for i := 1; i <= count; i++ {
 		waiting := true
 		for waiting {
 			select {
+			case <-interrupt.done():
+				break feeding
 			case <-ticker.C:
 				soFar := i - concurrency
 				now := time.Now()
 				elapsed := now.Sub(start)
-				fmt.Printf("\n%.05fs for %d PUTs so far, %.05f PUTs per second...", float64(elapsed)/float64(time.Second), soFar, float64(soFar)/float64(elapsed/time.Second))
-				if csvotw != nil {
-					csvotw.Write([]string{
-						fmt.Sprintf("%d", now.UnixNano()),
-						fmt.Sprintf("%d", soFar-lastSoFar),
-					})
-					csvotw.Flush()
-					lastSoFar = soFar
-				}
+				fmt.Printf("\n%.05fs for %d PUTs so far, %.05f PUTs per second, %.05f MB/s...", float64(elapsed)/float64(time.Second), soFar, float64(soFar)/float64(elapsed/time.Second), megabytesPerSecond(atomic.LoadInt64(&bytesSent), elapsed))
 			case benchChan <- i:
 				waiting = false
+				requests++
 			}
 		}
 	}
 	close(benchChan)
 	wg.Wait()
 	stop := time.Now()
+	csvot.finish(stop)
 	elapsed := stop.Sub(start)
 	ticker.Stop()
 	fmt.Println()
-	fmt.Printf("%.05fs total time, %.05f PUTs per second.\n", float64(elapsed)/float64(time.Second), float64(count)/float64(elapsed/time.Second))
-	if csvotw != nil {
-		csvotw.Write([]string{
-			fmt.Sprintf("%d", stop.UnixNano()),
-			fmt.Sprintf("%d", count-lastSoFar),
-		})
-		csvotw.Flush()
+	if interrupt.interrupted() {
+		fmt.Printf("Interrupted after %d of %d PUTs.\n", requests, count)
 	}
+	fmt.Printf("%.05fs total time, %.05f PUTs per second, %.05f MB/s.\n", float64(elapsed)/float64(time.Second), float64(requests)/float64(elapsed/time.Second), megabytesPerSecond(bytesSent, elapsed))
+	usage := resources.stop()
+	fmt.Println(usage)
+	results.finish(elapsed, int64(requests), bytesSent, concurrency, usage)
+	cli.benchCloseCSV(csvw, csvotw)
 }
 
-func (cli *CLIInstance) delet(c Client, args []string) {
-	container, object := parsePath(args)
-	var resp *http.Response
-	if object != "" {
-		resp = c.DeleteObject(container, object, cli.globalFlagHeaders.Headers())
-	} else if container != "" {
-		resp = c.DeleteContainer(container, cli.globalFlagHeaders.Headers())
-	} else {
-		resp = c.DeleteAccount(cli.globalFlagHeaders.Headers())
+func (cli *CLIInstance) copy(c Client, args []string) {
+	if err := cli.CopyFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
 	}
-	cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-	if resp.StatusCode/100 != 2 {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		cli.fatalf(cli, "%d %s - %s\n", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+	args = cli.CopyFlags.Args()
+	if len(args) != 2 {
+		cli.fatalf(cli, "copy requires a source and a destination.\n")
 	}
-	resp.Body.Close()
-}
-
-func (cli *CLIInstance) get(c Client, args []string) {
-	if err := cli.GetFlags.Parse(args); err != nil {
-		cli.fatal(cli, err)
+	srcContainer, srcObject := parsePath(args[:1])
+	dstContainer, dstObject := parsePath(args[1:])
+	if srcContainer == "" || dstContainer == "" {
+		cli.fatalf(cli, "copy requires a source and a destination.\n")
 	}
-	container, object := parsePath(cli.GetFlags.Args())
-	if *cli.getFlagRaw || object != "" {
-		var resp *http.Response
-		if object != "" {
-			resp = c.GetObject(container, object, cli.globalFlagHeaders.Headers())
-		} else if container != "" {
-			resp = c.GetContainerRaw(container, *cli.getFlagMarker, *cli.getFlagEndMarker, *cli.getFlagLimit, *cli.getFlagPrefix, *cli.getFlagDelimiter, *cli.getFlagReverse, cli.globalFlagHeaders.Headers())
-		} else {
-			resp = c.GetAccountRaw(*cli.getFlagMarker, *cli.getFlagEndMarker, *cli.getFlagLimit, *cli.getFlagPrefix, *cli.getFlagDelimiter, *cli.getFlagReverse, cli.globalFlagHeaders.Headers())
-		}
-		cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-		if resp.StatusCode/100 != 2 {
-			bodyBytes, _ := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
-			cli.fatalf(cli, "%d %s - %s\n", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+	if !*cli.copyFlagRecursive {
+		if srcObject == "" {
+			cli.fatalf(cli, "copy requires a source object unless -r is given.\n")
 		}
-		if *cli.getFlagRaw || object == "" {
-			data := [][]string{}
-			ks := []string{}
-			for k := range resp.Header {
-				ks = append(ks, k)
-			}
-			sort.Strings(ks)
-			for _, k := range ks {
-				for _, v := range resp.Header[k] {
-					data = append(data, []string{k + ":", v})
-				}
-			}
-			fmt.Println(resp.StatusCode, http.StatusText(resp.StatusCode))
-			opts := brimtext.NewDefaultAlignOptions()
-			fmt.Print(brimtext.Align(data, opts))
+		if dstObject == "" {
+			dstObject = srcObject
 		}
-		if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+		if err := copyOverlap(srcContainer, srcObject, dstContainer, dstObject, false); err != nil {
 			cli.fatal(cli, err)
 		}
-		return
-	}
-	if container != "" {
-		entries, resp := c.GetContainer(container, *cli.getFlagMarker, *cli.getFlagEndMarker, *cli.getFlagLimit, *cli.getFlagPrefix, *cli.getFlagDelimiter, *cli.getFlagReverse, cli.globalFlagHeaders.Headers())
-		cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-		if resp.StatusCode/100 != 2 {
-			bodyBytes, _ := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
-			cli.fatalf(cli, "%d %s - %s\n", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
-		}
-		if *cli.getFlagNameOnly {
-			for _, entry := range entries {
-				if entry.Subdir != "" {
-					fmt.Println(entry.Subdir)
-				} else {
-					fmt.Println(entry.Name)
-				}
-			}
-		} else {
-			var data [][]string
-			data = [][]string{{"Name", "Bytes", "Content Type", "Last Modified", "Hash"}}
-			for _, entry := range entries {
-				if entry.Subdir != "" {
-					data = append(data, []string{entry.Subdir, "", "", "", ""})
-				} else {
-					data = append(data, []string{entry.Name, fmt.Sprintf("%d", entry.Bytes), entry.ContentType, entry.LastModified, entry.Hash})
-				}
-			}
-			fmt.Print(brimtext.Align(data, nil))
+		if _, err := cli.copyObject(c, srcContainer, srcObject, dstContainer, dstObject, false); err == errExists {
+			fmt.Fprintf(os.Stderr, "Not copied; %s/%s already exists.\n", dstContainer, dstObject)
+		} else if err != nil {
+			cli.fatalf(cli, "%s\n", err)
 		}
 		return
 	}
-	entries, resp := c.GetAccount(*cli.getFlagMarker, *cli.getFlagEndMarker, *cli.getFlagLimit, *cli.getFlagPrefix, *cli.getFlagDelimiter, *cli.getFlagReverse, cli.globalFlagHeaders.Headers())
-	cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-	if resp.StatusCode/100 != 2 {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		cli.fatalf(cli, "%d %s - %s\n", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+	if err := copyOverlap(srcContainer, srcObject, dstContainer, dstObject, true); err != nil {
+		cli.fatal(cli, err)
 	}
-	if *cli.getFlagNameOnly {
-		for _, entry := range entries {
-			fmt.Println(entry.Name)
-		}
-	} else {
+	summary := cli.copyPrefix(c, srcContainer, srcObject, dstContainer, dstObject, false, nil)
+	if summary.failed > 0 || summary.interrupted {
+		cli.fatalf(cli, "%s\n", summary)
+	}
+	fmt.Println(summary)
+}
+
+// copyOverlap returns an error if the destination of a copy or move is the
+// source itself or, if recursive, lies within the source prefix, where the
+// objects written would be listed and copied again without end.
+func copyOverlap(srcContainer string, srcObject string, dstContainer string, dstObject string, recursive bool) error {
+	if srcContainer != dstContainer {
+		return nil
+	}
+	if srcObject == dstObject {
+		return fmt.Errorf("The destination %s/%s is the source itself.", dstContainer, dstObject)
+	}
+	if recursive && strings.HasPrefix(dstObject, srcObject) {
+		return fmt.Errorf("The destination %s/%s is within the source %s/%s.", dstContainer, dstObject, srcContainer, srcObject)
+	}
+	return nil
+}
+
+// copyObject copies the object server-side, returning the destination's ETag,
+// or errExists if -no-clobber left the destination alone. With manifest, a
+// large object's manifest is copied, with ?multipart-manifest=get, rather than
+// its content, so the copy refers to the same segments.
+func (cli *CLIInstance) copyObject(c Client, srcContainer string, srcObject string, dstContainer string, dstObject string, manifest bool) (string, error) {
+	cli.verbosef(cli, "Copying %s/%s to %s/%s.\n", srcContainer, srcObject, dstContainer, dstObject)
+	headers := cli.globalFlagHeaders.Headers()
+	if *cli.copyFlagFreshMetadata {
+		headers["X-Fresh-Metadata"] = "true"
+	}
+	if err := cli.clobberHeaders(c, dstContainer, dstObject, headers); err != nil {
+		return "", fmt.Errorf("copying from %s/%s: %s", srcContainer, srcObject, err)
+	}
+	var resp *http.Response
+	if manifest {
+		headers["X-Copy-From"] = "/" + nectarutil.EscapeObjectPath(srcContainer) + "/" + nectarutil.EscapeObjectPath(srcObject)
+		resp = c.Raw("PUT", "/"+nectarutil.EscapeObjectPath(dstContainer)+"/"+nectarutil.EscapeObjectPath(dstObject)+"?multipart-manifest=get", headers, nil)
+	} else {
+		resp = CopyObject(c, srcContainer, srcObject, dstContainer, dstObject, headers)
+	}
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		err := cli.clobberError(resp, dstContainer, dstObject)
+		if err == errExists {
+			cli.verbosef(cli, "Skipping %s/%s; it already exists.\n", dstContainer, dstObject)
+			return "", err
+		}
+		return "", fmt.Errorf("copying from %s/%s: %s", srcContainer, srcObject, err)
+	}
+	resp.Body.Close()
+	return strings.Trim(resp.Header.Get("Etag"), "\""), nil
+}
+
+// copySummary tallies the outcome of a recursive copy or move.
+type copySummary struct {
+	verb        string
+	objects     int64
+	bytes       int64
+	skipped     int64
+	failed      int64
+	elapsed     time.Duration
+	interrupted bool
+}
+
+func (s *copySummary) String() string {
+	text := fmt.Sprintf("%s %d objects, %d bytes, in %.05fs", s.verb, s.objects, s.bytes, s.elapsed.Seconds())
+	if s.skipped > 0 {
+		text += fmt.Sprintf("; %d skipped as they already exist", s.skipped)
+	}
+	if s.failed > 0 {
+		text += fmt.Sprintf("; %d failed", s.failed)
+	}
+	if s.interrupted {
+		text += "; interrupted"
+	}
+	return text + "."
+}
+
+// copyPrefix copies, server-side and concurrently, every object in the
+// srcContainer beginning with srcPrefix to the dstContainer, replacing
+// srcPrefix with dstPrefix. With manifests, each object is HEADed first and
+// the manifests of large objects copied rather than their content, as
+// copyObject does. If after is not nil it is called once each object has been
+// copied, with the destination ETag, the source's header if it was a
+// manifest, and a channel closed once interrupted, and may do further work
+// such as removing the source; an error from it counts the object as failed.
+// Failures
+// are reported to stderr as they happen, with progress given every ten
+// seconds. On SIGINT or SIGTERM no further objects are started and the
+// summary covers those completed.
+func (cli *CLIInstance) copyPrefix(c Client, srcContainer string, srcPrefix string, dstContainer string, dstPrefix string, manifests bool, after func(entry *ObjectRecord, dstObject string, etag string, manifest http.Header, done <-chan struct{}) error) *copySummary {
+	summary := &copySummary{verb: "Copied"}
+	resp := c.PutContainer(dstContainer, cli.newContainerHeaders(c, dstContainer))
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		cli.fatal(cli, NewResponseError(resp))
+	}
+	resp.Body.Close()
+	concurrency := *cli.globalFlagConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	concurrency, limiter := cli.autoConcurrency(concurrency, "Copy")
+	interrupt := cli.notifyInterrupt()
+	defer interrupt.stop()
+	entryChan := make(chan *ObjectRecord, concurrency)
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for entry := range entryChan {
+				dstObject := dstPrefix + strings.TrimPrefix(entry.Name, srcPrefix)
+				limiter.acquire()
+				opStart := time.Now()
+				var manifest http.Header
+				var etag string
+				var err error
+				if manifests {
+					manifest, _, err = cli.headSource(c, srcContainer, entry.Name)
+				}
+				if err == nil {
+					etag, err = cli.copyObject(c, srcContainer, entry.Name, dstContainer, dstObject, manifest != nil)
+				}
+				status := http.StatusOK
+				if err != nil && err != errExists {
+					status = http.StatusInternalServerError
+				}
+				limiter.release(opStart, status)
+				if err == errExists {
+					atomic.AddInt64(&summary.skipped, 1)
+					continue
+				}
+				if err == nil && after != nil {
+					err = after(entry, dstObject, etag, manifest, interrupt.done())
+				}
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					atomic.AddInt64(&summary.failed, 1)
+					continue
+				}
+				atomic.AddInt64(&summary.objects, 1)
+				atomic.AddInt64(&summary.bytes, int64(entry.Bytes))
+			}
+		}()
+	}
+	start := time.Now()
+	ticker := time.NewTicker(cli.progressInterval(10 * time.Second))
+	doneChan := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Printf("%d objects so far, %d failed...\n", atomic.LoadInt64(&summary.objects), atomic.LoadInt64(&summary.failed))
+			case <-doneChan:
+				return
+			}
+		}
+	}()
+	err := cli.eachObject(c, srcContainer, srcPrefix, func(entry *ObjectRecord) bool {
+		select {
+		case entryChan <- entry:
+			return true
+		case <-interrupt.done():
+			return false
+		}
+	})
+	close(entryChan)
+	wg.Wait()
+	ticker.Stop()
+	close(doneChan)
+	summary.elapsed = time.Since(start)
+	summary.interrupted = interrupt.interrupted()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		summary.failed++
+	}
+	return summary
+}
+
+// eachContainer calls fn for every container in the account beginning with
+// the prefix, paging through the listing as needed, until fn returns false.
+func (cli *CLIInstance) eachContainer(c Client, prefix string, fn func(entry *ContainerRecord) bool) error {
+	return EachContainer(c, prefix, "", cli.globalFlagHeaders.Headers(), func(entry *ContainerRecord) bool {
+		return entry.Name == "" || fn(entry)
+	})
+}
+
+// eachObject calls fn for every object in the container beginning with the
+// prefix, paging through the listing as needed, until fn returns false.
+func (cli *CLIInstance) eachObject(c Client, container string, prefix string, fn func(entry *ObjectRecord) bool) error {
+	return EachObject(c, container, prefix, "", cli.globalFlagHeaders.Headers(), func(entry *ObjectRecord) bool {
+		return entry.Name == "" || fn(entry)
+	})
+}
+
+func (cli *CLIInstance) initDir(args []string) {
+	if err := cli.InitFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, prefix := parsePath(cli.InitFlags.Args())
+	if container == "" {
+		cli.fatalf(cli, "init requires <container>.\n")
+	}
+	if !*cli.initFlagForce {
+		if _, err := os.Stat(dirMarkerName); err == nil {
+			cli.fatalf(cli, "%s already exists; use -f to replace it.\n", dirMarkerName)
+		}
+	}
+	if err := writeDirMarker(&dirMarker{Container: container, Prefix: prefix}); err != nil {
+		cli.fatalf(cli, "Could not write %s: %s\n", dirMarkerName, err)
+	}
+	fmt.Printf("Recorded %s/%s in %s.\n", container, prefix, dirMarkerName)
+}
+
+func (cli *CLIInstance) move(c Client, args []string) {
+	if err := cli.MoveFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.MoveFlags.Args()
+	if len(args) != 2 {
+		cli.fatalf(cli, "move requires a source and a destination.\n")
+	}
+	srcContainer, srcObject := parsePath(args[:1])
+	dstContainer, dstObject := parsePath(args[1:])
+	if srcContainer == "" || dstContainer == "" {
+		cli.fatalf(cli, "move requires a source and a destination.\n")
+	}
+	if !*cli.moveFlagRecursive {
+		if srcObject == "" {
+			cli.fatalf(cli, "move requires a source object unless -r is given.\n")
+		}
+		if dstObject == "" {
+			dstObject = srcObject
+		}
+		if err := copyOverlap(srcContainer, srcObject, dstContainer, dstObject, false); err != nil {
+			cli.fatal(cli, err)
+		}
+		if *cli.moveFlagDryRun {
+			fmt.Printf("Would move %s/%s to %s/%s\n", srcContainer, srcObject, dstContainer, dstObject)
+			return
+		}
+		if err := cli.moveObject(c, srcContainer, srcObject, dstContainer, dstObject); err != nil {
+			cli.fatalf(cli, "%s\n", err)
+		}
+		return
+	}
+	if err := copyOverlap(srcContainer, srcObject, dstContainer, dstObject, true); err != nil {
+		cli.fatal(cli, err)
+	}
+	if *cli.moveFlagDryRun {
+		var count int
+		if err := cli.eachObject(c, srcContainer, srcObject, func(entry *ObjectRecord) bool {
+			fmt.Printf("Would move %s/%s to %s/%s\n", srcContainer, entry.Name, dstContainer, dstObject+strings.TrimPrefix(entry.Name, srcObject))
+			count++
+			return true
+		}); err != nil {
+			cli.fatalf(cli, "%s\n", err)
+		}
+		fmt.Printf("Would move %d objects.\n", count)
+		return
+	}
+	rate := newRateLimiter(*cli.moveFlagRate)
+	summary := cli.copyPrefix(c, srcContainer, srcObject, dstContainer, dstObject, true, func(entry *ObjectRecord, dstObject string, etag string, manifest http.Header, done <-chan struct{}) error {
+		if !rate.wait(done) {
+			return fmt.Errorf("Interrupted; %s/%s was copied to %s/%s but not removed.", srcContainer, entry.Name, dstContainer, dstObject)
+		}
+		return cli.moveCleanup(c, srcContainer, entry.Name, entry.Hash, dstContainer, dstObject, etag, manifest)
+	})
+	summary.verb = "Moved"
+	if summary.failed > 0 || summary.interrupted {
+		cli.fatalf(cli, "%s\n", summary)
+	}
+	fmt.Println(summary)
+}
+
+// moveObject moves the one object, its manifest only if it is a large object.
+func (cli *CLIInstance) moveObject(c Client, srcContainer string, srcObject string, dstContainer string, dstObject string) error {
+	manifest, header, err := cli.headSource(c, srcContainer, srcObject)
+	if err != nil {
+		return err
+	}
+	srcEtag := strings.Trim(header.Get("Etag"), "\"")
+	etag, err := cli.copyObject(c, srcContainer, srcObject, dstContainer, dstObject, manifest != nil)
+	if err != nil {
+		return err
+	}
+	return cli.moveCleanup(c, srcContainer, srcObject, srcEtag, dstContainer, dstObject, etag, manifest)
+}
+
+// headSource HEADs the source of a move, returning its header, and the header
+// again as manifest if the object is a static or dynamic large object.
+func (cli *CLIInstance) headSource(c Client, container string, object string) (http.Header, http.Header, error) {
+	resp := c.HeadObject(container, object, cli.globalFlagHeaders.Headers())
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, NewResponseError(resp)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Static-Large-Object") != "" || resp.Header.Get("X-Object-Manifest") != "" {
+		return resp.Header, resp.Header, nil
+	}
+	return nil, resp.Header, nil
+}
+
+// moveCleanup deletes the source of a move once the destination's ETag has
+// been verified to match the source's; if the copy response did not include
+// an ETag the destination is checked with a HEAD request. A large object's
+// manifest, the source's header given as manifest, is copied rather than its
+// content, so the copy's ETag is not that of the large object; the
+// destination is HEADed and its ETag and size compared with the source's.
+func (cli *CLIInstance) moveCleanup(c Client, srcContainer string, srcObject string, srcEtag string, dstContainer string, dstObject string, dstEtag string, manifest http.Header) error {
+	if manifest != nil {
+		srcEtag = strings.Trim(manifest.Get("Etag"), "\"")
+		dstEtag = ""
+	}
+	if dstEtag == "" {
+		resp := c.HeadObject(dstContainer, dstObject, cli.globalFlagHeaders.Headers())
+		cli.verboseTransID(resp)
+		if resp.StatusCode/100 != 2 {
+			return NewResponseError(resp)
+		}
+		resp.Body.Close()
+		dstEtag = strings.Trim(resp.Header.Get("Etag"), "\"")
+		if manifest != nil && resp.Header.Get("Content-Length") != manifest.Get("Content-Length") {
+			return fmt.Errorf("Not deleting %s/%s; the size of its copy %s/%s was %s rather than %s", srcContainer, srcObject, dstContainer, dstObject, resp.Header.Get("Content-Length"), manifest.Get("Content-Length"))
+		}
+	}
+	if dstEtag != srcEtag {
+		return fmt.Errorf("Not deleting %s/%s; the ETag of its copy %s/%s was %s rather than %s", srcContainer, srcObject, dstContainer, dstObject, dstEtag, srcEtag)
+	}
+	resp := c.DeleteObject(srcContainer, srcObject, cli.globalFlagHeaders.Headers())
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		return NewResponseError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (cli *CLIInstance) delet(c Client, args []string) {
+	if err := cli.DeleteFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, object := parsePath(cli.DeleteFlags.Args())
+	var resp *http.Response
+	if object != "" && *cli.deleteFlagManifestDelete {
+		resp = DeleteManifest(c, container, object, cli.globalFlagHeaders.Headers())
+	} else if object != "" {
+		resp = c.DeleteObject(container, object, cli.globalFlagHeaders.Headers())
+	} else if container != "" {
+		resp = c.DeleteContainer(container, cli.globalFlagHeaders.Headers())
+	} else {
+		resp = c.DeleteAccount(cli.globalFlagHeaders.Headers())
+	}
+	cli.verboseTransID(resp)
+	if !cli.expectResponse(resp) {
+		cli.fatal(cli, NewResponseError(resp))
+	}
+	resp.Body.Close()
+}
+
+func (cli *CLIInstance) get(c Client, args []string) {
+	if err := cli.GetFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, object := parsePath(cli.GetFlags.Args())
+	if *cli.getFlagRaw || object != "" {
+		var resp *http.Response
+		if object != "" && *cli.getFlagManifest {
+			resp = c.Raw("GET", "/"+nectarutil.EscapeObjectPath(container)+"/"+nectarutil.EscapeObjectPath(object)+"?multipart-manifest=get", cli.globalFlagHeaders.Headers(), nil)
+		} else if object != "" {
+			resp = c.GetObject(container, object, cli.globalFlagHeaders.Headers())
+		} else if container != "" {
+			resp = c.GetContainerRaw(container, *cli.getFlagMarker, *cli.getFlagEndMarker, *cli.getFlagLimit, *cli.getFlagPrefix, *cli.getFlagDelimiter, *cli.getFlagReverse, cli.globalFlagHeaders.Headers())
+		} else {
+			resp = c.GetAccountRaw(*cli.getFlagMarker, *cli.getFlagEndMarker, *cli.getFlagLimit, *cli.getFlagPrefix, *cli.getFlagDelimiter, *cli.getFlagReverse, cli.globalFlagHeaders.Headers())
+		}
+		cli.verboseTransID(resp)
+		// A -H Range: bytes=-N tail of a zero-byte object, or a range from
+		// the end of the object, is just empty.
+		if object != "" && cli.expectFlagStatus == "" && rangeEmpty(resp) {
+			resp.Body.Close()
+			return
+		}
+		if !cli.expectResponse(resp) {
+			cli.fatal(cli, NewResponseError(resp))
+		}
+		if resp.StatusCode/100 != 2 {
+			resp.Body.Close()
+			return
+		}
+		if *cli.getFlagRaw || object == "" {
+			fmt.Println(resp.StatusCode, http.StatusText(resp.StatusCode))
+			cli.table(headerRows(resp.Header), brimtext.NewDefaultAlignOptions())
+		}
+		if _, err := cli.buffers.copy(os.Stdout, resp.Body); err != nil {
+			cli.fatal(cli, err)
+		}
+		return
+	}
+	if container != "" {
+		entries, resp := c.GetContainer(container, *cli.getFlagMarker, *cli.getFlagEndMarker, *cli.getFlagLimit, *cli.getFlagPrefix, *cli.getFlagDelimiter, *cli.getFlagReverse, cli.globalFlagHeaders.Headers())
+		cli.verboseTransID(resp)
+		if !cli.expectResponse(resp) {
+			cli.fatal(cli, NewResponseError(resp))
+		}
+		if resp.StatusCode/100 != 2 {
+			resp.Body.Close()
+			return
+		}
+		if *cli.globalFlagJSON {
+			cli.printJSON(entries)
+		} else if cli.template != nil {
+			for _, entry := range entries {
+				cli.format(entry)
+			}
+		} else if *cli.getFlagNameOnly {
+			for _, entry := range entries {
+				if entry.Subdir != "" {
+					fmt.Println(entry.Subdir)
+				} else {
+					fmt.Println(entry.Name)
+				}
+			}
+		} else {
+			var data [][]string
+			data = [][]string{{"Name", "Bytes", "Content Type", "Last Modified", "Hash"}}
+			for _, entry := range entries {
+				if entry.Subdir != "" {
+					data = append(data, []string{entry.Subdir, "", "", "", ""})
+				} else {
+					data = append(data, []string{entry.Name, fmt.Sprintf("%d", entry.Bytes), entry.ContentType, entry.LastModified, entry.Hash})
+				}
+			}
+			cli.table(data, nil)
+		}
+		return
+	}
+	entries, resp := c.GetAccount(*cli.getFlagMarker, *cli.getFlagEndMarker, *cli.getFlagLimit, *cli.getFlagPrefix, *cli.getFlagDelimiter, *cli.getFlagReverse, cli.globalFlagHeaders.Headers())
+	cli.verboseTransID(resp)
+	if !cli.expectResponse(resp) {
+		cli.fatal(cli, NewResponseError(resp))
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return
+	}
+	if *cli.globalFlagJSON {
+		cli.printJSON(entries)
+	} else if cli.template != nil {
+		for _, entry := range entries {
+			cli.format(entry)
+		}
+	} else if *cli.getFlagNameOnly {
+		for _, entry := range entries {
+			if entry.Subdir != "" {
+				fmt.Println(entry.Subdir)
+			} else {
+				fmt.Println(entry.Name)
+			}
+		}
+	} else {
 		var data [][]string
-		data = [][]string{{"Name", "Count", "Bytes"}}
+		data = [][]string{{"Name", "Count", "Bytes", "Last Modified", "Storage Policy"}}
 		for _, entry := range entries {
-			data = append(data, []string{entry.Name, fmt.Sprintf("%d", entry.Count), fmt.Sprintf("%d", entry.Bytes)})
+			if entry.Subdir != "" {
+				data = append(data, []string{entry.Subdir, "", "", "", ""})
+			} else {
+				data = append(data, []string{entry.Name, fmt.Sprintf("%d", entry.Count), fmt.Sprintf("%d", entry.Bytes), entry.LastModified, entry.StoragePolicy})
+			}
 		}
-		fmt.Print(brimtext.Align(data, nil))
+		cli.table(data, nil)
 	}
 	return
 }
 
 func (cli *CLIInstance) head(c Client, args []string) {
-	container, object := parsePath(args)
+	if err := cli.HeadFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, object := parsePath(cli.HeadFlags.Args())
+	if *cli.headFlagPolicies {
+		if container != "" {
+			cli.fatalf(cli, "head -policies is only for the account.\n")
+		}
+		cli.headPolicies(c)
+		return
+	}
 	var resp *http.Response
 	if object != "" {
 		resp = c.HeadObject(container, object, cli.globalFlagHeaders.Headers())
@@ -1956,161 +3244,645 @@ func (cli *CLIInstance) head(c Client, args []string) {
 	} else {
 		resp = c.HeadAccount(cli.globalFlagHeaders.Headers())
 	}
-	cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
+	cli.verboseTransID(resp)
+	if !cli.expectResponse(resp) {
+		cli.fatal(cli, NewResponseError(resp))
+	}
 	resp.Body.Close()
-	if resp.StatusCode/100 != 2 {
-		cli.fatalf(cli, "%d %s - %s\n", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+	if cli.template != nil || *cli.globalFlagJSON {
+		info := &headInfo{StatusCode: resp.StatusCode, Status: http.StatusText(resp.StatusCode), Header: resp.Header, Metadata: map[string]string{}}
+		for k, vs := range resp.Header {
+			if name := metaHeaderName(k); name != "" && len(vs) > 0 {
+				v := vs[0]
+				if dv, err := url.PathUnescape(v); err == nil {
+					v = dv
+				}
+				info.Metadata[name] = v
+			}
+		}
+		if *cli.globalFlagJSON {
+			cli.printJSON(info)
+		} else {
+			cli.format(info)
+		}
+		return
 	}
+	fmt.Println(resp.StatusCode, http.StatusText(resp.StatusCode))
+	if *cli.headFlagRaw || *cli.globalFlagPlain {
+		cli.table(headerRows(resp.Header), brimtext.NewDefaultAlignOptions())
+		return
+	}
+	cli.table(headerSections(resp.Header), brimtext.NewDefaultAlignOptions())
+}
+
+// headInfo is the data given to the -format template by head.
+type headInfo struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Metadata has the X-*-Meta- prefixes removed and the values URL decoded.
+	Metadata map[string]string
+}
+
+// format outputs the data using the -format template.
+func (cli *CLIInstance) format(data interface{}) {
+	if err := cli.template.Execute(os.Stdout, data); err != nil {
+		cli.fatal(cli, err)
+	}
+}
+
+// printJSON outputs the data as indented JSON.
+func (cli *CLIInstance) printJSON(data interface{}) {
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	fmt.Println(string(b))
+}
+
+// table outputs the rows aligned into columns or, with -plain, as tab
+// separated values.
+func (cli *CLIInstance) table(data [][]string, opts *brimtext.AlignOptions) {
+	if *cli.globalFlagPlain {
+		for _, row := range data {
+			fmt.Println(strings.Join(row, "\t"))
+		}
+		return
+	}
+	fmt.Print(brimtext.Align(data, opts))
+}
+
+// systemHeaderLabels gives friendly labels for well known system headers,
+// including the few metadata headers that have system meaning.
+var systemHeaderLabels = map[string]string{
+	"X-Account-Access-Control":        "Access Control",
+	"X-Account-Bytes-Used":            "Bytes Used",
+	"X-Account-Container-Count":       "Containers",
+	"X-Account-Meta-Quota-Bytes":      "Quota Bytes",
+	"X-Account-Meta-Temp-Url-Key":     "Temp URL Key",
+	"X-Account-Meta-Temp-Url-Key-2":   "Temp URL Key 2",
+	"X-Account-Object-Count":          "Objects",
+	"X-Container-Bytes-Used":          "Bytes Used",
+	"X-Container-Meta-Quota-Bytes":    "Quota Bytes",
+	"X-Container-Meta-Quota-Count":    "Quota Count",
+	"X-Container-Meta-Temp-Url-Key":   "Temp URL Key",
+	"X-Container-Meta-Temp-Url-Key-2": "Temp URL Key 2",
+	"X-Container-Object-Count":        "Objects",
+	"X-Container-Read":                "Read ACL",
+	"X-Container-Sync-Key":            "Sync Key",
+	"X-Container-Sync-To":             "Sync To",
+	"X-Container-Write":               "Write ACL",
+	"X-Delete-At":                     "Delete At",
+	"X-History-Location":              "History Location",
+	"X-Object-Manifest":               "Manifest (DLO)",
+	"X-Static-Large-Object":           "Static Large Object",
+	"X-Storage-Policy":                "Storage Policy",
+	"X-Timestamp":                     "Timestamp",
+	"X-Versions-Location":             "Versions Location",
+}
+
+// headerRows returns a "Name:", value row for each header value, sorted by
+// name.
+func headerRows(header http.Header) [][]string {
 	data := [][]string{}
 	ks := []string{}
-	kls := map[string]string{}
-	for k := range resp.Header {
+	for k := range header {
 		ks = append(ks, k)
-		kls[k] = k
 	}
 	sort.Strings(ks)
 	for _, k := range ks {
-		for _, v := range resp.Header[kls[k]] {
+		for _, v := range header[k] {
 			data = append(data, []string{k + ":", v})
 		}
 	}
-	fmt.Println(resp.StatusCode, http.StatusText(resp.StatusCode))
-	fmt.Print(brimtext.Align(data, brimtext.NewDefaultAlignOptions()))
+	return data
+}
+
+// headerSections returns rows for brimtext.Align grouping the headers into
+// Metadata, System, and Headers sections. Metadata names have their
+// X-*-Meta- prefix removed and values are URL decoded when possible.
+func headerSections(header http.Header) [][]string {
+	var meta, system, other [][]string
+	ks := []string{}
+	for k := range header {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	for _, k := range ks {
+		for _, v := range header[k] {
+			if label, ok := systemHeaderLabels[http.CanonicalHeaderKey(k)]; ok {
+				system = append(system, []string{"  " + label + ":", v})
+			} else if name := metaHeaderName(k); name != "" {
+				if dv, err := url.PathUnescape(v); err == nil {
+					v = dv
+				}
+				meta = append(meta, []string{"  " + name + ":", v})
+			} else {
+				other = append(other, []string{"  " + k + ":", v})
+			}
+		}
+	}
+	var data [][]string
+	if len(meta) > 0 {
+		data = append(data, []string{"Metadata:", ""})
+		data = append(data, meta...)
+	}
+	if len(system) > 0 {
+		data = append(data, []string{"System:", ""})
+		data = append(data, system...)
+	}
+	if len(other) > 0 {
+		data = append(data, []string{"Headers:", ""})
+		data = append(data, other...)
+	}
+	return data
+}
+
+// metaHeaderName returns the user metadata name of the X-Account-Meta-*,
+// X-Container-Meta-*, or X-Object-Meta-* header given, or "" if it is not
+// such a header.
+func metaHeaderName(header string) string {
+	header = http.CanonicalHeaderKey(header)
+	for _, prefix := range []string{"X-Account-Meta-", "X-Container-Meta-", "X-Object-Meta-"} {
+		if strings.HasPrefix(header, prefix) && len(header) > len(prefix) {
+			name := header[len(prefix):]
+			if dn, err := url.PathUnescape(name); err == nil {
+				name = dn
+			}
+			return name
+		}
+	}
+	return ""
 }
 
 func (cli *CLIInstance) put(c Client, args []string) {
-	container, object := parsePath(args)
+	if err := cli.PutFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, object := parsePath(cli.PutFlags.Args())
 	var resp *http.Response
 	if object != "" {
-		resp = c.PutObject(container, object, cli.globalFlagHeaders.Headers(), os.Stdin)
+		resp = c.PutObject(container, object, cli.putFlagMeta.MetaHeaders("X-Object-Meta-", cli.globalFlagHeaders.Headers()), os.Stdin)
 	} else if container != "" {
-		resp = c.PutContainer(container, cli.globalFlagHeaders.Headers())
+		resp = c.PutContainer(container, cli.putFlagMeta.MetaHeaders("X-Container-Meta-", cli.newContainerHeaders(c, container)))
 	} else {
-		resp = c.PutAccount(cli.globalFlagHeaders.Headers())
+		resp = c.PutAccount(cli.putFlagMeta.MetaHeaders("X-Account-Meta-", cli.globalFlagHeaders.Headers()))
 	}
-	cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-	if resp.StatusCode/100 != 2 {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		cli.fatalf(cli, "%d %s - %s\n", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+	cli.verboseTransID(resp)
+	if !cli.expectResponse(resp) {
+		cli.fatal(cli, NewResponseError(resp))
 	}
 	resp.Body.Close()
 }
 
 func (cli *CLIInstance) post(c Client, args []string) {
-	container, object := parsePath(args)
+	if err := cli.PostFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, object := parsePath(cli.PostFlags.Args())
 	var resp *http.Response
 	if object != "" {
-		resp = c.PostObject(container, object, cli.globalFlagHeaders.Headers())
+		resp = c.PostObject(container, object, cli.postFlagMeta.MetaHeaders("X-Object-Meta-", cli.globalFlagHeaders.Headers()))
 	} else if container != "" {
-		resp = c.PostContainer(container, cli.globalFlagHeaders.Headers())
+		resp = c.PostContainer(container, cli.postFlagMeta.MetaHeaders("X-Container-Meta-", cli.globalFlagHeaders.Headers()))
 	} else {
-		resp = c.PostAccount(cli.globalFlagHeaders.Headers())
+		resp = c.PostAccount(cli.postFlagMeta.MetaHeaders("X-Account-Meta-", cli.globalFlagHeaders.Headers()))
 	}
-	cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-	if resp.StatusCode/100 != 2 {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		cli.fatalf(cli, "%d %s - %s\n", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+	cli.verboseTransID(resp)
+	if !cli.expectResponse(resp) {
+		cli.fatal(cli, NewResponseError(resp))
 	}
 	resp.Body.Close()
 }
 
+func (cli *CLIInstance) raw(c Client, args []string) {
+	if err := cli.RawFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.RawFlags.Args()
+	if len(args) < 1 || len(args) > 2 {
+		cli.fatalf(cli, "raw requires <method> and optionally [path].\n")
+	}
+	method := strings.ToUpper(args[0])
+	path := ""
+	if len(args) == 2 && args[1] != "" {
+		path = args[1]
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	var body io.Reader
+	switch method {
+	case "GET", "HEAD", "DELETE", "OPTIONS":
+	default:
+		body = os.Stdin
+	}
+	resp := c.Raw(method, path, cli.globalFlagHeaders.Headers(), body)
+	cli.verboseTransID(resp)
+	fmt.Println(resp.StatusCode, http.StatusText(resp.StatusCode))
+	cli.table(headerRows(resp.Header), brimtext.NewDefaultAlignOptions())
+	if _, err := cli.buffers.copy(os.Stdout, resp.Body); err != nil {
+		cli.fatal(cli, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		cli.fatalf(cli, "%s %s - %d %s\n", method, path, resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+}
+
+func (cli *CLIInstance) tag(c Client, args []string) {
+	if err := cli.TagFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, object := parsePath(cli.TagFlags.Args())
+	if object == "" {
+		cli.fatalf(cli, "tag requires <container> <object>.\n")
+	}
+	for key, values := range cli.tagFlagAdd.Query() {
+		// Of a tag given more than once, the last value is kept.
+		if err := AddTag(c, container, object, key, values[len(values)-1], cli.globalFlagHeaders.Headers()); err != nil {
+			cli.fatal(cli, err)
+		}
+	}
+	for _, key := range cli.tagFlagRemove {
+		if err := RemoveTag(c, container, object, key, cli.globalFlagHeaders.Headers()); err != nil {
+			cli.fatal(cli, err)
+		}
+	}
+	exists, info, err := ObjectExists(c, container, object, cli.globalFlagHeaders.Headers())
+	if err == nil && !exists {
+		err = fmt.Errorf("HEAD /%s/%s - 404 Not Found", container, object)
+	}
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	tags := ObjectTags(info)
+	if *cli.globalFlagJSON {
+		cli.printJSON(tags)
+		return
+	}
+	var keys []string
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	data := [][]string{{"Key", "Value"}}
+	for _, key := range keys {
+		data = append(data, []string{key, tags[key]})
+	}
+	cli.table(data, nil)
+}
+
+func (cli *CLIInstance) tagged(c Client, args []string) {
+	if err := cli.TaggedFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.TaggedFlags.Args()
+	if len(args) != 2 {
+		cli.fatalf(cli, "tagged requires <key>[=<value>] <container>.\n")
+	}
+	key, value := args[0], ""
+	if i := strings.Index(key, "="); i >= 0 {
+		key, value = key[:i], key[i+1:]
+	}
+	concurrency := *cli.globalFlagConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	names, err := ListByTag(c, args[1], *cli.taggedFlagPrefix, key, value, concurrency, cli.globalFlagHeaders.Headers())
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	if *cli.globalFlagJSON {
+		cli.printJSON(names)
+		return
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+}
+
 func (cli *CLIInstance) upload(c Client, args []string) {
+	if err := cli.UploadFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.UploadFlags.Args()
+	if *cli.uploadFlagChecksum != "" {
+		if _, err := newChecksumHash(*cli.uploadFlagChecksum); err != nil {
+			cli.fatal(cli, err)
+		}
+	}
+	marker, err := readDirMarker()
+	if err != nil {
+		cli.fatalf(cli, "Could not read %s: %s\n", dirMarkerName, err)
+	}
 	if len(args) == 0 {
-		cli.fatalf(cli, "<sourcepath> is required for upload.\n")
+		if marker == nil {
+			cli.fatalf(cli, "<sourcepath> is required for upload unless the current directory has a %s file; see init.\n", dirMarkerName)
+		}
+		args = []string{"."}
 	}
 	sourcepath := args[0]
 	container, object := parsePath(args[1:])
-	if container == "" {
+	if container == "" && marker != nil {
+		container, object = marker.Container, marker.Prefix
+	} else if container == "" {
 		abscwd, err := filepath.Abs(".")
 		if err != nil {
 			cli.fatalf(cli, "Could not determine current working directory: %s\n", err)
 		}
-		container = filepath.Base(abscwd)
-	}
-	cli.verbosef(cli, "Ensuring container %q exists.\n", container)
-	resp := c.PutContainer(container, cli.globalFlagHeaders.Headers())
-	cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-	if resp.StatusCode/100 != 2 {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		cli.fatalf(cli, "PUT %s - %d %s - %s\n", container, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
-	}
-	resp.Body.Close()
-	uploadfn := func(path string, appendPath bool) {
-		opath := object
-		if appendPath {
-			opath += path
+		container = filepath.Base(abscwd)
+	}
+	if *cli.uploadFlagEstimate {
+		cli.estimateUpload(c, sourcepath, container)
+		return
+	}
+	if *cli.uploadFlagArchive && (*cli.uploadFlagState != "" || *cli.uploadFlagSkipUnchanged || cli.uploadFlagSegmentSize > 0 || cli.clobberFlagNoClobber || cli.clobberFlagIfUnmodified) {
+		cli.fatalf(cli, "upload -archive cannot be used with -state, -skip-unchanged, -segment-size, -no-clobber, or -if-unmodified.\n")
+	}
+	cli.verbosef(cli, "Ensuring container %q exists.\n", container)
+	resp := c.PutContainer(container, cli.newContainerHeaders(c, container))
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		cli.fatal(cli, NewResponseError(resp))
+	}
+	resp.Body.Close()
+	var journal *transferJournal
+	if *cli.uploadFlagState != "" {
+		var err error
+		if journal, err = openTransferJournal(*cli.uploadFlagState); err != nil {
+			cli.fatalf(cli, "Could not open state file %s: %s\n", *cli.uploadFlagState, err)
+		}
+		defer journal.Close()
+	}
+	if cli.uploadFlagSegmentSize > 0 {
+		segmentContainer := cli.uploadSegmentContainer(container)
+		cli.verbosef(cli, "Ensuring segment container %q exists.\n", segmentContainer)
+		resp := c.PutContainer(segmentContainer, cli.newContainerHeaders(c, segmentContainer))
+		cli.verboseTransID(resp)
+		if resp.StatusCode/100 != 2 {
+			cli.fatal(cli, NewResponseError(resp))
+		}
+		resp.Body.Close()
+	}
+	var existing map[string]*ObjectRecord
+	if *cli.uploadFlagSkipUnchanged {
+		cli.verbosef(cli, "Listing %q %q to find unchanged files.\n", container, object)
+		existing = map[string]*ObjectRecord{}
+		if err := cli.eachObject(c, container, object, func(entry *ObjectRecord) bool {
+			existing[entry.Name] = entry
+			return true
+		}); err != nil {
+			cli.fatal(cli, err)
+		}
+	}
+	fi, err := os.Stat(sourcepath)
+	if err != nil {
+		cli.fatalf(cli, "Could not stat %s: %s\n", sourcepath, err)
+	}
+	if *cli.uploadFlagMetaManifest != "" {
+		if cli.uploadMetaManifest, err = loadMetaManifest(*cli.uploadFlagMetaManifest); err != nil {
+			cli.fatal(cli, err)
+		}
+	}
+	if *cli.uploadFlagArchive {
+		if fi.Mode().IsRegular() {
+			cli.fatalf(cli, "upload -archive requires <sourcepath> to be a directory.\n")
+		}
+		cli.uploadArchive(c, sourcepath, container, object, marker)
+		return
+	}
+	var interrupt *interruption
+	var walk *uploadWalk
+	var discovery fmt.Stringer
+	// This "if" is so a single file upload that happens to be a symlink will work.
+	if !fi.Mode().IsRegular() {
+		// This "if" is to handle when the user-given path is a symlink to a directory; we normally want to skip symlinks, but not in this initial case.
+		if !strings.HasSuffix(sourcepath, string(os.PathSeparator)) {
+			sourcepath += string(os.PathSeparator)
+		}
+		interrupt = cli.notifyInterrupt()
+		defer interrupt.stop()
+		walk = newUploadWalk(sourcepath, *cli.uploadFlagWalkers, *cli.uploadFlagQueue, *cli.uploadFlagPreserve, interrupt)
+		discovery = walk
+	}
+	// Progress is always tracked, though only reported with -report-interval,
+	// so an interrupted upload can say what it completed.
+	events := cli.progressEvents.begin("upload")
+	progress := newTransferProgress(c, "Uploaded", "bytes_sent", cli.reportInterval, 0, 0, discovery, events)
+	var limiter *adaptiveLimiter
+	uploadfn := func(path string, appendPath bool) {
+		opath := object
+		if appendPath {
+			if *cli.uploadFlagMetaSidecar != "" && strings.HasSuffix(path, *cli.uploadFlagMetaSidecar) {
+				return
+			}
+			if marker != nil && path == dirMarkerName {
+				return
+			}
+			opath += cli.uploadFlagTransform.apply(localToObjectPath(path))
+		}
+		// The size and modification time are part of the key so files that
+		// change between runs are uploaded again.
+		var key string
+		if journal != nil {
+			if fi, err := os.Stat(path); err == nil {
+				key = fmt.Sprintf("PUT %q %q %d %d", path, container+"/"+opath, fi.Size(), fi.ModTime().UnixNano())
+				if journal.completed(key) {
+					cli.verbosef(cli, "Skipping %q; already uploaded according to the state file.\n", path)
+					return
+				}
+			}
+		}
+		if entry := existing[opath]; entry != nil && fileUnchanged(path, entry) {
+			cli.verbosef(cli, "Skipping %q; unchanged from %q %q.\n", path, container, opath)
+			return
+		}
+		// failed reports err, exiting unless -continue-on-error.
+		failed := func(err error) {
+			events.failed(path, container, opath, err)
+			if !*cli.globalFlagContinueOnError {
+				cli.fatal(cli, err)
+			}
+			fmt.Fprintln(os.Stderr, err)
+		}
+		var size int64
+		if fi, err := os.Stat(path); err == nil {
+			size = fi.Size()
+		}
+		events.started(path, container, opath, size)
+		headers, err := cli.uploadHeaders(path, opath)
+		if err == nil && *cli.uploadFlagPreserve {
+			// A symlink given as the sourcepath is uploaded as the file it
+			// refers to, as without -preserve.
+			err = fileAttributeHeaders(path, appendPath, headers)
+		}
+		if err == nil && *cli.uploadFlagChecksum != "" && headers[preserveSymlinkHeader] == "" {
+			var sum string
+			if sum, err = fileChecksum(path, *cli.uploadFlagChecksum); err == nil {
+				headers[checksumMetaHeader] = sum
+			}
+		}
+		if err == nil {
+			err = cli.clobberHeaders(c, container, opath, headers)
+		}
+		if err != nil {
+			failed(err)
+			return
+		}
+		// writeFailed reports the unsuccessful response to the write, which
+		// may just be -no-clobber leaving the object alone.
+		writeFailed := func(resp *http.Response) {
+			if err := cli.clobberError(resp, container, opath); err == errExists {
+				cli.verbosef(cli, "Skipping %q; %q %q already exists.\n", path, container, opath)
+				events.skipped(path, container, opath)
+			} else {
+				failed(err)
+			}
+		}
+		if target := headers[preserveSymlinkHeader]; target != "" {
+			cli.verbosef(cli, "Uploading symlink %q to %q as %q %q.\n", path, target, container, opath)
+			limiter.acquire()
+			opStart := time.Now()
+			resp := c.PutObject(container, opath, headers, bytes.NewReader(nil))
+			limiter.release(opStart, resp.StatusCode)
+			cli.verboseTransID(resp)
+			if resp.StatusCode/100 != 2 {
+				writeFailed(resp)
+				return
+			}
+			resp.Body.Close()
+			progress.completed(0)
+			events.completed(path, container, opath, 0)
+			if key != "" {
+				if err := journal.complete(key); err != nil {
+					cli.fatalf(cli, "Could not update state file %s: %s\n", *cli.uploadFlagState, err)
+				}
+			}
+			return
+		}
+		if cli.uploadFlagSegmentSize > 0 {
+			if fi, err := os.Stat(path); err == nil && fi.Size() > int64(cli.uploadFlagSegmentSize) {
+				// The If-None-Match: * only stops the manifest, so the
+				// segments are not uploaded for nothing.
+				if cli.clobberFlagNoClobber {
+					var exists bool
+					if exists, _, err = ObjectExists(c, container, opath, cli.globalFlagHeaders.Headers()); err == nil && exists {
+						err = errExists
+					}
+				}
+				if err == nil {
+					cli.verbosef(cli, "Uploading %q to %q %q as segments.\n", path, container, opath)
+					err = cli.uploadSegmented(c, limiter, path, fi, container, opath, headers)
+				}
+				if err == errExists {
+					cli.verbosef(cli, "Skipping %q; %q %q already exists.\n", path, container, opath)
+					events.skipped(path, container, opath)
+					return
+				}
+				if err != nil {
+					failed(err)
+					return
+				}
+				progress.completed(fi.Size())
+				events.completed(path, container, opath, fi.Size())
+				if key != "" {
+					if err := journal.complete(key); err != nil {
+						cli.fatalf(cli, "Could not update state file %s: %s\n", *cli.uploadFlagState, err)
+					}
+				}
+				return
+			}
 		}
 		cli.verbosef(cli, "Uploading %q to %q %q.\n", path, container, opath)
 		f, err := os.Open(path)
 		if err != nil {
-			if *cli.globalFlagContinueOnError {
-				fmt.Fprintf(os.Stderr, "Cannot open %s while attempting to upload to %s/%s: %s\n", path, container, opath, err)
-				return
-			} else {
-				cli.fatalf(cli, "Cannot open %s while attempting to upload to %s/%s: %s\n", path, container, opath, err)
-			}
+			failed(fmt.Errorf("Cannot open %s while attempting to upload to %s/%s: %s", path, container, opath, err))
+			return
 		}
-		resp := c.PutObject(container, opath, cli.globalFlagHeaders.Headers(), f)
-		cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-		if resp.StatusCode/100 != 2 {
-			bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		limiter.acquire()
+		opStart := time.Now()
+		resp := c.PutObject(container, opath, headers, f)
+		// A 408 stub means the upload stalled; the file has to be reopened
+		// since the transport closes the request body.
+		for attempt := 0; resp.StatusCode == http.StatusRequestTimeout && *cli.globalFlagStallTimeout != "" && attempt < stallRetries; attempt++ {
 			resp.Body.Close()
 			f.Close()
-			if *cli.globalFlagContinueOnError {
-				fmt.Fprintf(os.Stderr, "PUT %s/%s - %d %s - %s\n", container, opath, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
-				return
-			} else {
-				cli.fatalf(cli, "PUT %s/%s - %d %s - %s\n", container, opath, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+			cli.verbosef(cli, "Upload of %q stalled; retrying.\n", path)
+			if f, err = os.Open(path); err != nil {
+				resp = nectarutil.ResponseStub(http.StatusRequestTimeout, err.Error())
+				break
 			}
+			resp = c.PutObject(container, opath, headers, f)
+		}
+		limiter.release(opStart, resp.StatusCode)
+		cli.verboseTransID(resp)
+		if resp.StatusCode/100 != 2 {
+			writeFailed(resp)
+			f.Close()
+			return
 		}
 		resp.Body.Close()
+		// The transport has closed f by now.
+		if fi, err := os.Stat(path); err == nil {
+			progress.completed(fi.Size())
+			events.completed(path, container, opath, fi.Size())
+		}
 		f.Close()
+		if key != "" {
+			if err := journal.complete(key); err != nil {
+				cli.fatalf(cli, "Could not update state file %s: %s\n", *cli.uploadFlagState, err)
+			}
+		}
 	}
-	fi, err := os.Stat(sourcepath)
-	if err != nil {
-		cli.fatalf(cli, "Could not stat %s: %s\n", sourcepath, err)
-	}
-	// This "if" is so a single file upload that happens to be a symlink will work.
-	if fi.Mode().IsRegular() {
+	if walk == nil {
 		uploadfn(sourcepath, false)
 	} else {
 		concurrency := *cli.globalFlagConcurrency
 		if concurrency < 1 {
 			concurrency = 1
 		}
-		uploadChan := make(chan string, concurrency-1)
+		concurrency, limiter = cli.autoConcurrency(concurrency, "Upload")
 		wg := sync.WaitGroup{}
 		wg.Add(concurrency)
 		for i := 0; i < concurrency; i++ {
 			go func() {
-				for {
-					path := <-uploadChan
-					if path == "" {
-						break
+				for path := range walk.queue {
+					// Once interrupted, queued files are just drained.
+					if !interrupt.interrupted() {
+						uploadfn(path, true)
 					}
-					uploadfn(path, true)
 				}
 				wg.Done()
 			}()
 		}
-		// This "if" is to handle when the user-given path is a symlink to a directory; we normally want to skip symlinks, but not in this initial case.
-		if !strings.HasSuffix(sourcepath, string(os.PathSeparator)) {
-			sourcepath += string(os.PathSeparator)
-		}
-		filepath.Walk(sourcepath, func(path string, info os.FileInfo, err error) error {
-			if err != nil || !info.Mode().IsRegular() {
-				return nil
-			}
-			uploadChan <- path
-			return nil
-		})
-		close(uploadChan)
 		wg.Wait()
 	}
+	progress.Close()
+	events.progress("finished", progress, interrupt.interrupted())
+	if interrupt.interrupted() {
+		journal.Close()
+		cli.fatalf(cli, "Interrupted. %s.\n", progress)
+	}
+}
+
+// fileUnchanged returns true if the file's size and MD5 match the listing
+// entry's. The MD5 is only computed if the sizes match.
+func fileUnchanged(path string, entry *ObjectRecord) bool {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Size() != int64(entry.Bytes) {
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	hasher := md5.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return false
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)) == entry.Hash
 }
 
 func (cli *CLIInstance) download(c Client, args []string) {
@@ -2118,24 +3890,41 @@ func (cli *CLIInstance) download(c Client, args []string) {
 		cli.fatal(cli, err)
 	}
 	args = cli.DownloadFlags.Args()
+	var marker *dirMarker
 	if len(args) == 0 {
-		cli.fatalf(cli, "<destpath> is required for download.\n")
+		var err error
+		if marker, err = readDirMarker(); err != nil {
+			cli.fatalf(cli, "Could not read %s: %s\n", dirMarkerName, err)
+		}
+		if marker == nil {
+			cli.fatalf(cli, "<destpath> is required for download unless the current directory has a %s file; see init.\n", dirMarkerName)
+		}
+		args = []string{marker.Container, "."}
 	}
 	destpath := args[len(args)-1]
 	container, object := parsePath(args[:len(args)-1])
 	concurrency := *cli.globalFlagConcurrency
-	// Need at least 2 to queue object downloads while reading a container listing.
-	if concurrency < 2 {
-		concurrency = 2
+	if concurrency < 1 {
+		concurrency = 1
 	}
-	type downloadTask struct {
-		container string
-		object    string
-		destpath  string
+	// The limiter is only applied to object downloads, never listings, so
+	// listings can always queue more work.
+	concurrency, limiter := cli.autoConcurrency(concurrency, "Download")
+	var journal *transferJournal
+	if *cli.downloadFlagState != "" {
+		var err error
+		if journal, err = openTransferJournal(*cli.downloadFlagState); err != nil {
+			cli.fatalf(cli, "Could not open state file %s: %s\n", *cli.downloadFlagState, err)
+		}
+		defer journal.Close()
 	}
+	events := cli.progressEvents.begin("download")
+	var progress *transferProgress
+	var interrupt *interruption
 	downloadChan := make(chan *downloadTask, concurrency-1)
 	var dirExistsLock sync.Mutex
 	dirExists := map[string]bool{}
+	links := &pendingSymlinks{}
 	taskWG := sync.WaitGroup{}
 	taskWG.Add(concurrency)
 	containerWG := sync.WaitGroup{}
@@ -2146,30 +3935,17 @@ func (cli *CLIInstance) download(c Client, args []string) {
 				if task == nil {
 					break
 				}
-				if task.object == "" {
-					entries, resp := c.GetContainer(task.container, "", "", 0, "", "", false, cli.globalFlagHeaders.Headers())
-					cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-					if resp.StatusCode/100 != 2 {
-						bodyBytes, _ := ioutil.ReadAll(resp.Body)
-						resp.Body.Close()
-						containerWG.Done()
-						if *cli.globalFlagContinueOnError {
-							fmt.Fprintf(os.Stderr, "GET %s - %d %s - %s\n", task.container, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
-							continue
-						} else {
-							cli.fatalf(cli, "GET %s - %d %s - %s\n", task.container, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
-						}
-					}
-					resp.Body.Close()
-					for _, entry := range entries {
-						if entry.Name != "" {
-							downloadChan <- &downloadTask{container: task.container, object: entry.Name, destpath: filepath.Join(task.destpath, filepath.FromSlash(entry.Name))}
-						}
-					}
-					containerWG.Done()
+				// Once interrupted, queued tasks are just drained.
+				if interrupt.interrupted() {
+					continue
+				}
+				key := fmt.Sprintf("GET %q %q", task.container+"/"+task.object, task.destpath)
+				if journal.completed(key) {
+					cli.verbosef(cli, "Skipping %s/%s; already downloaded according to the state file.\n", task.container, task.object)
 					continue
 				}
 				cli.verbosef(cli, "Downloading %s/%s to %s.\n", task.container, task.object, task.destpath)
+				events.started(task.destpath, task.container, task.object, task.size)
 				if dstdr := filepath.Dir(task.destpath); dstdr != "." {
 					dirExistsLock.Lock()
 					if !dirExists[dstdr] {
@@ -2184,44 +3960,31 @@ func (cli *CLIInstance) download(c Client, args []string) {
 					}
 					dirExistsLock.Unlock()
 				}
-				f, err := os.Create(task.destpath)
-				if err != nil {
+				if err := cli.downloadObject(c, limiter, links, task.container, task.object, task.destpath); err != nil {
+					events.failed(task.destpath, task.container, task.object, err)
 					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "Could not create %s: %s\n", task.destpath, err)
+						fmt.Fprintln(os.Stderr, err)
 						continue
 					} else {
-						cli.fatalf(cli, "Could not create %s: %s\n", task.destpath, err)
+						cli.fatalf(cli, "%s\n", err)
 					}
 				}
-				resp := c.GetObject(task.container, task.object, cli.globalFlagHeaders.Headers())
-				cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-				if resp.StatusCode/100 != 2 {
-					bodyBytes, _ := ioutil.ReadAll(resp.Body)
-					resp.Body.Close()
-					f.Close()
-					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "GET %s/%s - %d %s - %s\n", task.container, task.object, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
-						continue
-					} else {
-						cli.fatalf(cli, "GET %s/%s - %d %s - %s\n", task.container, task.object, resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
-					}
+				if err := journal.complete(key); err != nil {
+					cli.fatalf(cli, "Could not update state file %s: %s\n", *cli.downloadFlagState, err)
 				}
-				if _, err = io.Copy(f, resp.Body); err != nil {
-					resp.Body.Close()
-					f.Close()
-					if *cli.globalFlagContinueOnError {
-						fmt.Fprintf(os.Stderr, "Could not complete content transfer from %s/%s to %s: %s\n", task.container, task.object, task.destpath, err)
-						continue
-					} else {
-						cli.fatalf(cli, "Could not complete content transfer from %s/%s to %s: %s\n", task.container, task.object, task.destpath, err)
+				progress.completed(task.size)
+				if events != nil {
+					size := task.size
+					if fi, err := os.Stat(task.destpath); err == nil {
+						size = fi.Size()
 					}
+					events.completed(task.destpath, task.container, task.object, size)
 				}
-				resp.Body.Close()
-				f.Close()
 			}
 			taskWG.Done()
 		}()
 	}
+	var tasks []*downloadTask
 	if object != "" {
 		fi, err := os.Stat(destpath)
 		if err != nil {
@@ -2229,9 +3992,9 @@ func (cli *CLIInstance) download(c Client, args []string) {
 				cli.fatalf(cli, "Could not stat %s: %s\n", destpath, err)
 			}
 		} else if fi.IsDir() {
-			destpath = filepath.Join(destpath, object)
+			destpath = filepath.Join(destpath, filepath.FromSlash(object))
 		}
-		downloadChan <- &downloadTask{container: container, object: object, destpath: destpath}
+		tasks = append(tasks, &downloadTask{container: container, object: object, destpath: destpath})
 	} else if container != "" {
 		fi, err := os.Stat(destpath)
 		if err != nil {
@@ -2241,8 +4004,11 @@ func (cli *CLIInstance) download(c Client, args []string) {
 		} else if !fi.IsDir() {
 			cli.fatalf(cli, "Cannot download a container to a single file: %s\n", destpath)
 		}
-		containerWG.Add(1)
-		downloadChan <- &downloadTask{container: container, object: "", destpath: destpath}
+		if marker != nil && marker.Prefix != "" {
+			tasks = append(tasks, &downloadTask{container: container, prefix: marker.Prefix, trimPrefix: marker.Prefix, destpath: destpath})
+		} else {
+			tasks = append(tasks, cli.downloadPrefixTasks(container, destpath)...)
+		}
 	} else if !*cli.downloadFlagAccount {
 		cli.fatalf(cli, "You must specify -a if you wish to download the entire account.\n")
 	} else {
@@ -2254,24 +4020,418 @@ func (cli *CLIInstance) download(c Client, args []string) {
 		} else if !fi.IsDir() {
 			cli.fatalf(cli, "Cannot download an account to a single file: %s\n", destpath)
 		}
-		entries, resp := c.GetAccount("", "", 0, "", "", false, cli.globalFlagHeaders.Headers())
-		cli.verbosef(cli, "X-Trans-Id: %q\n", resp.Header.Get("X-Trans-Id"))
-		if resp.StatusCode/100 != 2 {
-			bodyBytes, _ := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
-			cli.fatalf(cli, "GET - %d %s - %s\n", resp.StatusCode, http.StatusText(resp.StatusCode), string(bodyBytes))
+		if err := cli.eachContainer(c, "", func(entry *ContainerRecord) bool {
+			tasks = append(tasks, cli.downloadPrefixTasks(entry.Name, filepath.Join(destpath, entry.Name))...)
+			return true
+		}); err != nil {
+			cli.fatal(cli, err)
 		}
-		resp.Body.Close()
-		for _, entry := range entries {
-			if entry.Name != "" {
-				containerWG.Add(1)
-				downloadChan <- &downloadTask{container: entry.Name, object: "", destpath: filepath.Join(destpath, entry.Name)}
+	}
+	if *cli.downloadFlagEstimate {
+		close(downloadChan)
+		cli.estimateDownload(c, concurrency, tasks)
+		return
+	}
+	if *cli.downloadFlagPlan {
+		var total int64
+		tasks, total = cli.planDownload(c, concurrency, tasks)
+		question := fmt.Sprintf("About to download %s in %d objects, proceed?", humanBytes(total), len(tasks))
+		if *cli.downloadFlagYes {
+			fmt.Println(question, "Proceeding due to -y.")
+		} else if !confirm(question) {
+			cli.fatalf(cli, "Download cancelled.\n")
+		}
+		progress = newTransferProgress(c, "Downloaded", "bytes_received", cli.progressInterval(time.Second), len(tasks), total, nil, events)
+	} else {
+		// Progress is always tracked, though only reported with
+		// -report-interval, so an interrupted download can say what it
+		// completed.
+		progress = newTransferProgress(c, "Downloaded", "bytes_received", cli.reportInterval, 0, 0, nil, events)
+	}
+	interrupt = cli.notifyInterrupt()
+	defer interrupt.stop()
+	// Listings are done apart from the downloads, up to concurrency at a
+	// time, so downloads always proceed while listings queue more work.
+	listings := make(chan struct{}, concurrency)
+	for _, task := range tasks {
+		if task.object == "" {
+			select {
+			case listings <- struct{}{}:
+			case <-interrupt.done():
+				continue
 			}
+			containerWG.Add(1)
+			go func(task *downloadTask) {
+				cli.downloadListing(c, task, downloadChan, interrupt)
+				<-listings
+				containerWG.Done()
+			}(task)
+			continue
+		}
+		select {
+		case downloadChan <- task:
+		case <-interrupt.done():
 		}
 	}
 	containerWG.Wait()
 	close(downloadChan)
 	taskWG.Wait()
+	for _, err := range links.create(func(frmt string, args ...interface{}) { cli.verbosef(cli, frmt, args...) }) {
+		if *cli.globalFlagContinueOnError {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			cli.fatalf(cli, "%s\n", err)
+		}
+	}
+	progress.Close()
+	events.progress("finished", progress, interrupt.interrupted())
+	if interrupt.interrupted() {
+		journal.Close()
+		cli.fatalf(cli, "Interrupted. %s.\n", progress)
+	}
+}
+
+// downloadPrefixTasks returns the tasks for downloading the container's
+// objects under destpath: one for each -prefix, or one for the whole
+// container if none were given. Prefixes within others given are dropped so
+// no object is listed twice.
+func (cli *CLIInstance) downloadPrefixTasks(container string, destpath string) []*downloadTask {
+	prefixes := append([]string{}, cli.downloadFlagPrefix...)
+	if len(prefixes) == 0 {
+		return []*downloadTask{{container: container, destpath: destpath}}
+	}
+	sort.Strings(prefixes)
+	var tasks []*downloadTask
+	for _, prefix := range prefixes {
+		if len(tasks) > 0 && strings.HasPrefix(prefix, tasks[len(tasks)-1].prefix) {
+			continue
+		}
+		tasks = append(tasks, &downloadTask{container: container, prefix: prefix, destpath: destpath})
+	}
+	return tasks
+}
+
+// downloadListing queues a download for each object listed for the container
+// task, until interrupted.
+func (cli *CLIInstance) downloadListing(c Client, task *downloadTask, downloadChan chan *downloadTask, interrupt *interruption) {
+	if err := cli.eachObject(c, task.container, task.prefix, func(entry *ObjectRecord) bool {
+		if !cli.downloadMatch(entry) {
+			return true
+		}
+		select {
+		case downloadChan <- &downloadTask{container: task.container, object: entry.Name, destpath: task.objectPath(entry.Name), size: int64(entry.Bytes)}:
+			return true
+		case <-interrupt.done():
+			return false
+		}
+	}); err != nil {
+		if !*cli.globalFlagContinueOnError {
+			cli.fatal(cli, err)
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// progressInterval returns the -report-interval if given, or the default.
+func (cli *CLIInstance) progressInterval(def time.Duration) time.Duration {
+	if cli.reportInterval > 0 {
+		return cli.reportInterval
+	}
+	return def
+}
+
+// downloadMatch returns true if the listed object is within any -min-size and
+// -max-size given and matches any -content-type given.
+func (cli *CLIInstance) downloadMatch(entry *ObjectRecord) bool {
+	if *cli.downloadFlagType != "" && entry.ContentType != *cli.downloadFlagType {
+		if matched, _ := path.Match(*cli.downloadFlagType, entry.ContentType); !matched {
+			return false
+		}
+	}
+	if cli.downloadFlagMinSize > 0 && int64(entry.Bytes) < int64(cli.downloadFlagMinSize) {
+		return false
+	}
+	if cli.downloadFlagMaxSize > 0 && int64(entry.Bytes) > int64(cli.downloadFlagMaxSize) {
+		return false
+	}
+	return true
+}
+
+// downloadObject downloads the object to the destpath. Unless -no-atomic is
+// in use, the content is written to destpath.nectar-tmp and only renamed to
+// destpath once the transfer has been verified, so an interrupted download
+// never leaves a truncated file masquerading as a complete one. With
+// -preserve, symlinks are queued to links rather than created.
+func (cli *CLIInstance) downloadObject(c Client, limiter *adaptiveLimiter, links *pendingSymlinks, container string, object string, destpath string) error {
+	path := destpath
+	if !*cli.downloadFlagNoAtomic {
+		path += ".nectar-tmp"
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Could not create %s: %s", path, err)
+	}
+	fail := func(err error) error {
+		f.Close()
+		if path != destpath {
+			os.Remove(path)
+		}
+		return err
+	}
+	limiter.acquire()
+	opStart := time.Now()
+	resp := c.GetObject(container, object, cli.globalFlagHeaders.Headers())
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		limiter.release(opStart, resp.StatusCode)
+		return fail(NewResponseError(resp))
+	}
+	if resp.ContentLength > 0 {
+		// Preallocating avoids fragmentation and allows ranges to be written
+		// in place.
+		err = f.Truncate(resp.ContentLength)
+	}
+	// The ETag is only the MD5 of the content for plain objects, not for
+	// large object manifests.
+	var hasher hash.Hash
+	etag := strings.ToLower(resp.Header.Get("Etag"))
+	if resp.Header.Get("X-Static-Large-Object") == "" && resp.Header.Get("X-Object-Manifest") == "" && len(etag) == 32 {
+		hasher = md5.New()
+	}
+	// A checksum stored by upload -checksum covers large objects too.
+	var checksum hash.Hash
+	var checksumWant string
+	if v := resp.Header.Get(checksumMetaHeader); v != "" && err == nil {
+		checksum, checksumWant, err = parseChecksum(v)
+	}
+	var n int64
+	if err == nil {
+		if *cli.downloadFlagParts > 1 && resp.ContentLength >= int64(*cli.downloadFlagParts)*downloadPartMinSize {
+			if err = cli.downloadParts(c, container, object, f, resp, *cli.downloadFlagParts); err == nil {
+				n = resp.ContentLength
+			}
+			// The parts arrive out of order, so the MD5 and checksum are
+			// computed from the file once complete.
+			var writers []io.Writer
+			if hasher != nil {
+				writers = append(writers, hasher)
+			}
+			if checksum != nil {
+				writers = append(writers, checksum)
+			}
+			if err == nil && len(writers) > 0 {
+				_, err = io.Copy(io.MultiWriter(writers...), io.NewSectionReader(f, 0, n))
+			}
+		} else {
+			writers := []io.Writer{f}
+			if hasher != nil {
+				writers = append(writers, hasher)
+			}
+			if checksum != nil {
+				writers = append(writers, checksum)
+			}
+			w := io.MultiWriter(writers...)
+			n, err = cli.buffers.copy(w, resp.Body)
+			for attempt := 0; err == ErrTransferStalled && attempt < stallRetries; attempt++ {
+				cli.verbosef(cli, "Download of %s/%s stalled at byte %d; resuming.\n", container, object, n)
+				var m int64
+				m, err = cli.resumeDownload(c, container, object, resp.Header.Get("Etag"), n, w)
+				n += m
+			}
+		}
+	}
+	limiter.release(opStart, resp.StatusCode)
+	resp.Body.Close()
+	if err == nil && resp.ContentLength >= 0 && n != resp.ContentLength {
+		err = fmt.Errorf("expected %d bytes, got %d", resp.ContentLength, n)
+	}
+	if err == nil && hasher != nil {
+		if sum := fmt.Sprintf("%x", hasher.Sum(nil)); sum != etag {
+			err = fmt.Errorf("MD5 %s did not match ETag %s", sum, etag)
+		}
+	}
+	if err == nil && checksum != nil {
+		if sum := fmt.Sprintf("%x", checksum.Sum(nil)); sum != checksumWant {
+			err = fmt.Errorf("checksum %s did not match %s %s", sum, checksumMetaHeader, resp.Header.Get(checksumMetaHeader))
+		}
+	}
+	if err != nil {
+		return fail(fmt.Errorf("Could not complete content transfer from %s/%s to %s: %s", container, object, destpath, err))
+	}
+	if err = f.Close(); err != nil {
+		return fail(fmt.Errorf("Could not complete content transfer from %s/%s to %s: %s", container, object, destpath, err))
+	}
+	if *cli.downloadFlagPreserve {
+		if target := resp.Header.Get(preserveSymlinkHeader); target != "" {
+			// The object's empty content is replaced by the symlink, once
+			// every regular file has been written.
+			os.Remove(path)
+			links.add(destpath, target, resp.Header)
+			return nil
+		}
+		if err = restoreFileAttributes(path, resp.Header); err != nil {
+			cli.verbosef(cli, "%s\n", err)
+		}
+	}
+	if path != destpath {
+		if err = os.Rename(path, destpath); err != nil {
+			os.Remove(path)
+			return fmt.Errorf("Could not rename %s to %s: %s", path, destpath, err)
+		}
+	}
+	return nil
+}
+
+// resumeDownload continues a download from the offset, writing the rest of
+// the content to w; the etag, if not empty, ensures the object hasn't changed
+// in the meantime.
+func (cli *CLIInstance) resumeDownload(c Client, container string, object string, etag string, offset int64, w io.Writer) (int64, error) {
+	headers := cli.globalFlagHeaders.Headers()
+	if etag != "" {
+		if !strings.HasPrefix(etag, "\"") {
+			etag = "\"" + etag + "\""
+		}
+		headers["If-Match"] = etag
+	}
+	cr, resp := GetObjectRange(c, container, object, offset, -1, headers)
+	cli.verboseTransID(resp)
+	defer resp.Body.Close()
+	if rangeEmpty(resp) {
+		// The stall was after the last byte; nothing is left.
+		return 0, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("resuming at byte %d: %s", offset, NewResponseError(resp))
+	}
+	if cr != nil && cr.Start != offset {
+		// Such as when the range was ignored, giving the whole object; the
+		// content already written is skipped.
+		if cr.Start > offset {
+			return 0, fmt.Errorf("resuming at byte %d: got content from byte %d", offset, cr.Start)
+		}
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, offset-cr.Start); err != nil {
+			return 0, fmt.Errorf("resuming at byte %d: %s", offset, err)
+		}
+	}
+	return cli.buffers.copy(w, resp.Body)
+}
+
+// downloadPartMinSize is the minimum size of each range for download -parts.
+const downloadPartMinSize = 1024 * 1024
+
+// downloadParts writes the object content to f, which must already be
+// preallocated to resp.ContentLength, as the given number of ranges
+// downloaded concurrently. The first range is read from the existing resp;
+// the rest are requested with ranged GETs conditional on resp's ETag, so an
+// object overwritten mid-download fails rather than mixing old and new
+// content.
+func (cli *CLIInstance) downloadParts(c Client, container string, object string, f *os.File, resp *http.Response, parts int) error {
+	size := resp.ContentLength
+	etag := resp.Header.Get("Etag")
+	if etag != "" && !strings.HasPrefix(etag, "\"") {
+		etag = "\"" + etag + "\""
+	}
+	partSize := (size + int64(parts) - 1) / int64(parts)
+	errs := make(chan error, parts)
+	for part := 1; part < parts; part++ {
+		start := int64(part) * partSize
+		end := start + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		if start >= size {
+			// With rounding, the last parts may have nothing left to cover;
+			// a range of them would be refused with a 416.
+			errs <- nil
+			continue
+		}
+		go func(start int64, end int64) {
+			headers := cli.globalFlagHeaders.Headers()
+			if etag != "" {
+				headers["If-Match"] = etag
+			}
+			cr, presp := GetObjectRange(c, container, object, start, end, headers)
+			cli.verboseTransID(presp)
+			defer presp.Body.Close()
+			if presp.StatusCode == http.StatusPreconditionFailed {
+				errs <- fmt.Errorf("GET %s/%s bytes=%d-%d - the object changed during the download", container, object, start, end)
+				return
+			}
+			if presp.StatusCode != http.StatusPartialContent {
+				errs <- fmt.Errorf("bytes=%d-%d: %s", start, end, NewResponseError(presp))
+				return
+			}
+			if cr != nil && cr.Start != start {
+				errs <- fmt.Errorf("GET %s/%s bytes=%d-%d - got content from byte %d", container, object, start, end, cr.Start)
+				return
+			}
+			n, err := cli.buffers.copy(&offsetWriter{w: f, offset: start}, presp.Body)
+			if err == nil && n != end-start+1 {
+				err = fmt.Errorf("GET %s/%s bytes=%d-%d - expected %d bytes, got %d", container, object, start, end, end-start+1, n)
+			}
+			errs <- err
+		}(start, end)
+	}
+	n, err := cli.buffers.copy(&offsetWriter{w: f}, io.LimitReader(resp.Body, partSize))
+	if err == nil && n != partSize {
+		err = fmt.Errorf("GET %s/%s - expected %d bytes, got %d", container, object, partSize, n)
+	}
+	resp.Body.Close()
+	for part := 1; part < parts; part++ {
+		if perr := <-errs; perr != nil && err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// offsetWriter writes sequentially with WriteAt, starting at offset.
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (ow *offsetWriter) Write(p []byte) (int, error) {
+	n, err := ow.w.WriteAt(p, ow.offset)
+	ow.offset += int64(n)
+	return n, err
+}
+
+// benchContainer returns the name of the nth of a bench's containers. Any {n}
+// placeholder in the container name is replaced with n; otherwise n is
+// appended, unless there is just the one container.
+func benchContainer(container string, containers int, n int) string {
+	if strings.Contains(container, "{n}") {
+		return strings.Replace(container, "{n}", strconv.Itoa(n), -1)
+	}
+	if containers == 1 {
+		return container
+	}
+	return fmt.Sprintf("%s%d", container, n)
+}
+
+// localToObjectPath converts a local file path to the form used within
+// object names: any volume name, such as a Windows drive letter or UNC
+// \\server\share prefix, is dropped along with the separator after it and
+// the OS path separators become slashes.
+func localToObjectPath(path string) string {
+	return volumeToObjectPath(path, filepath.VolumeName(path), os.PathSeparator)
+}
+
+// volumeToObjectPath is localToObjectPath for the volume name and path
+// separator given, so the conversion of Windows paths can be tested anywhere.
+// The separator after a volume name is dropped too, so C:\a\b becomes a/b
+// rather than /a/b, which would give a double slash once appended to an
+// object prefix.
+func volumeToObjectPath(path string, volume string, separator rune) string {
+	path = path[len(volume):]
+	if separator != '/' {
+		path = strings.Replace(path, string(separator), "/", -1)
+	}
+	if volume != "" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return path
 }
 
 func parsePath(args []string) (string, string) {
@@ -2321,6 +4481,135 @@ func (slf *stringListFlag) Headers() map[string]string {
 	return headers
 }
 
+// newContainerHeaders returns the headers for a PUT of the container: the -H
+// headers, plus any -container-header defaults if the container does not
+// exist yet. Only when there are defaults is the container HEADed to find
+// out, as a PUT with, say, a different storage policy would fail for an
+// existing container.
+func (cli *CLIInstance) newContainerHeaders(c Client, container string) map[string]string {
+	headers := cli.globalFlagHeaders.Headers()
+	if len(cli.globalFlagContainerHeader) == 0 {
+		return headers
+	}
+	if exists, _, err := ContainerExists(c, container, headers); exists || err != nil {
+		return headers
+	}
+	for k, v := range cli.globalFlagContainerHeader.Headers() {
+		if _, ok := headers[k]; !ok {
+			headers[k] = v
+		}
+	}
+	return headers
+}
+
+// sizeFlag is a byte count given in bytes or with a K, M, G, T, or P suffix,
+// optionally followed by iB or B, all multiples of 1024; 0 means unset.
+type sizeFlag int64
+
+func (sf *sizeFlag) Set(value string) error {
+	v := strings.ToUpper(strings.TrimSpace(value))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I")
+	multiplier := int64(1)
+	if i := strings.IndexAny(v, "KMGTP"); i >= 0 && i == len(v)-1 {
+		multiplier = 1 << (10 * uint(strings.IndexByte("KMGTP", v[i])+1))
+		v = v[:i]
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*sf = sizeFlag(n * float64(multiplier))
+	return nil
+}
+
+func (sf *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*sf), 10)
+}
+
+// sizeDistributionFlag is a weighted list of sizes given as
+// <size>:<weight>,... with sizes as for sizeFlag.
+type sizeDistributionFlag []weightedSize
+
+type weightedSize struct {
+	source string
+	size   int64
+	weight int
+}
+
+func (sdf *sizeDistributionFlag) Set(value string) error {
+	var dist sizeDistributionFlag
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid size distribution item %q; expected <size>:<weight>", item)
+		}
+		var size sizeFlag
+		if err := size.Set(parts[0]); err != nil {
+			return err
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || weight < 1 {
+			return fmt.Errorf("invalid weight in size distribution item %q", item)
+		}
+		dist = append(dist, weightedSize{source: strings.TrimSpace(item), size: int64(size), weight: weight})
+	}
+	*sdf = dist
+	return nil
+}
+
+func (sdf *sizeDistributionFlag) String() string {
+	var items []string
+	for _, ws := range *sdf {
+		items = append(items, ws.source)
+	}
+	return strings.Join(items, ",")
+}
+
+// pick returns a size chosen at random according to the weights.
+func (sdf sizeDistributionFlag) pick(rnd *rand.Rand) int64 {
+	total := 0
+	for _, ws := range sdf {
+		total += ws.weight
+	}
+	n := rnd.Intn(total)
+	for _, ws := range sdf {
+		if n < ws.weight {
+			return ws.size
+		}
+		n -= ws.weight
+	}
+	return sdf[len(sdf)-1].size
+}
+
+// Query returns the name=value items as query parameters, keeping every
+// value of a name given more than once, in order.
+func (slf *stringListFlag) Query() url.Values {
+	parameters := url.Values{}
+	for _, item := range *slf {
+		splitItem := strings.SplitN(item, "=", 2)
+		if len(splitItem) == 2 {
+			parameters.Add(splitItem[0], splitItem[1])
+		} else {
+			parameters.Add(splitItem[0], "")
+		}
+	}
+	return parameters
+}
+
+// MetaHeaders adds the key=value items as headers with the given prefix,
+// such as X-Object-Meta-, to the headers given, returning the headers.
+func (slf *stringListFlag) MetaHeaders(prefix string, headers map[string]string) map[string]string {
+	for _, item := range *slf {
+		splitItem := strings.SplitN(item, "=", 2)
+		if len(splitItem) == 2 {
+			headers[prefix+strings.TrimSpace(splitItem[0])] = strings.TrimSpace(splitItem[1])
+		} else {
+			headers[prefix+strings.TrimSpace(splitItem[0])] = ""
+		}
+	}
+	return headers
+}
+
 // lockedSource allows a random number generator to be used by multiple goroutines concurrently.
 // The code is very similar to math/rand.lockedSource.
 type lockedSource struct {
@@ -0,0 +1,213 @@
+package nectar
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/troubling/nectar/nectarutil"
+)
+
+func TestLocalToObjectPath(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{path: filepath.Join("dir", "sub", "file"), want: "dir/sub/file"},
+		{path: "file", want: "file"},
+	} {
+		if got := localToObjectPath(test.path); got != test.want {
+			t.Errorf("localToObjectPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestVolumeToObjectPath(t *testing.T) {
+	for _, test := range []struct {
+		path      string
+		volume    string
+		separator rune
+		want      string
+	}{
+		{path: "dir/sub/file", separator: '/', want: "dir/sub/file"},
+		{path: "/dir/file", separator: '/', want: "/dir/file"},
+		{path: `dir\sub\file`, separator: '\\', want: "dir/sub/file"},
+		{path: `C:\a\b`, volume: "C:", separator: '\\', want: "a/b"},
+		{path: `C:a\b`, volume: "C:", separator: '\\', want: "a/b"},
+		{path: `\\server\share\a`, volume: `\\server\share`, separator: '\\', want: "a"},
+		{path: `\\server\share`, volume: `\\server\share`, separator: '\\', want: ""},
+	} {
+		if got := volumeToObjectPath(test.path, test.volume, test.separator); got != test.want {
+			t.Errorf("volumeToObjectPath(%q, %q, %q) = %q, want %q", test.path, test.volume, test.separator, got, test.want)
+		}
+	}
+}
+
+// moveObject is an object held by moveClient.
+type moveObject struct {
+	header http.Header
+	hash   string
+}
+
+// moveClient holds objects in memory for testing move: a plain server-side
+// copy of a large object gives an object of its content, whose ETag is not
+// the large object's, whereas one with ?multipart-manifest=get copies the
+// manifest itself.
+type moveClient struct {
+	Client
+	lock    sync.Mutex
+	objects map[string]*moveObject
+	copies  []string
+}
+
+func (c *moveClient) response(status int, header http.Header) *http.Response {
+	resp := nectarutil.ResponseStub(status, "")
+	if header != nil {
+		resp.Header = header
+	}
+	resp.Request = &http.Request{Method: "GET", URL: &url.URL{Path: "/v1/AUTH_test"}, Header: http.Header{}}
+	return resp
+}
+
+func (c *moveClient) PutContainer(container string, headers map[string]string) *http.Response {
+	return c.response(http.StatusCreated, nil)
+}
+
+func (c *moveClient) GetContainer(container string, marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) ([]*ObjectRecord, *http.Response) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var names []string
+	for name := range c.objects {
+		if strings.HasPrefix(name, container+"/"+prefix) && name > container+"/"+marker {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var entries []*ObjectRecord
+	for _, name := range names {
+		size, _ := strconv.Atoi(c.objects[name].header.Get("Content-Length"))
+		entries = append(entries, &ObjectRecord{Name: strings.TrimPrefix(name, container+"/"), Hash: c.objects[name].hash, Bytes: size})
+	}
+	return entries, c.response(http.StatusOK, nil)
+}
+
+func (c *moveClient) HeadObject(container string, obj string, headers map[string]string) *http.Response {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	o := c.objects[container+"/"+obj]
+	if o == nil {
+		return c.response(http.StatusNotFound, nil)
+	}
+	return c.response(http.StatusOK, o.header)
+}
+
+func (c *moveClient) copy(container string, obj string, headers map[string]string, manifest bool) *http.Response {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	src := c.objects[strings.TrimPrefix(headers["X-Copy-From"], "/")]
+	if src == nil {
+		return c.response(http.StatusNotFound, nil)
+	}
+	dst := &moveObject{header: http.Header{}, hash: src.hash}
+	if manifest {
+		for k, v := range src.header {
+			dst.header[k] = v
+		}
+	} else {
+		// The content of a large object, as a plain object.
+		dst.header.Set("Etag", "content-md5")
+		dst.header.Set("Content-Length", src.header.Get("Content-Length"))
+		dst.hash = "content-md5"
+		if src.header.Get("X-Static-Large-Object") == "" {
+			dst.header.Set("Etag", src.header.Get("Etag"))
+			dst.hash = src.hash
+		}
+	}
+	c.objects[container+"/"+obj] = dst
+	c.copies = append(c.copies, container+"/"+obj)
+	resp := c.response(http.StatusCreated, nil)
+	resp.Header.Set("Etag", dst.header.Get("Etag"))
+	if manifest {
+		resp.Header.Set("Etag", "manifest-md5")
+	}
+	return resp
+}
+
+func (c *moveClient) PutObject(container string, obj string, headers map[string]string, src io.Reader) *http.Response {
+	return c.copy(container, obj, headers, false)
+}
+
+func (c *moveClient) Raw(method string, urlAfterAccount string, headers map[string]string, body io.Reader) *http.Response {
+	path := strings.TrimSuffix(strings.TrimPrefix(urlAfterAccount, "/"), "?multipart-manifest=get")
+	parts := strings.SplitN(path, "/", 2)
+	return c.copy(parts[0], parts[1], headers, strings.HasSuffix(urlAfterAccount, "?multipart-manifest=get"))
+}
+
+func (c *moveClient) DeleteObject(container string, obj string, headers map[string]string) *http.Response {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.objects[container+"/"+obj] == nil {
+		return c.response(http.StatusNotFound, nil)
+	}
+	delete(c.objects, container+"/"+obj)
+	return c.response(http.StatusNoContent, nil)
+}
+
+func newMoveClient() *moveClient {
+	return &moveClient{objects: map[string]*moveObject{
+		"src/a/slo": {hash: "manifest-hash", header: http.Header{
+			"Etag":                  {`"slo-etag"`},
+			"Content-Length":        {"3000"},
+			"X-Static-Large-Object": {"True"},
+		}},
+		"src/a/plain": {hash: "plain-md5", header: http.Header{
+			"Etag":           {"plain-md5"},
+			"Content-Length": {"10"},
+		}},
+	}}
+}
+
+func TestMoveLargeObject(t *testing.T) {
+	var failures []string
+	cli := newCLIInstance("nectar", func(cli *CLIInstance, err error) {
+		failures = append(failures, err.Error())
+	}, func(cli *CLIInstance, frmt string, args ...interface{}) {
+		failures = append(failures, fmt.Sprintf(frmt, args...))
+	}, nil, nil)
+	c := newMoveClient()
+	if err := cli.moveObject(c, "src", "a/slo", "dst", "a/slo"); err != nil {
+		t.Fatal(err)
+	}
+	if c.objects["src/a/slo"] != nil {
+		t.Errorf("the source was not deleted")
+	}
+	if dst := c.objects["dst/a/slo"]; dst == nil || dst.header.Get("X-Static-Large-Object") == "" {
+		t.Errorf("the manifest was not copied: %+v", dst)
+	}
+
+	c = newMoveClient()
+	cli.move(c, []string{"-r", "src/a/", "dst/b/"})
+	if len(failures) > 0 {
+		t.Errorf("move -r failed: %s", failures)
+	}
+	for _, name := range []string{"dst/b/slo", "dst/b/plain"} {
+		if c.objects[name] == nil {
+			t.Errorf("%s was not created", name)
+		}
+	}
+	for _, name := range []string{"src/a/slo", "src/a/plain"} {
+		if c.objects[name] != nil {
+			t.Errorf("%s was not deleted", name)
+		}
+	}
+	if dst := c.objects["dst/b/slo"]; dst != nil && dst.header.Get("X-Static-Large-Object") == "" {
+		t.Errorf("the manifest was not copied")
+	}
+}
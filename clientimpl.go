@@ -2,6 +2,7 @@ package nectar
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -9,27 +10,234 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/troubling/nectar/nectarutil"
 )
 
-// userClient is a Client to be used by end-users.  It knows how to authenticate with auth v1 and v2.
+// userClient is a Client to be used by end-users. It authenticates with an
+// AuthProvider, by default one for auth v1 or Keystone v2 or v3.
 type userClient struct {
-	client                                              *http.Client
-	ServiceURLs                                         []string
-	AuthToken                                           string
-	tenant, username, password, apikey, region, authurl string
-	private                                             bool
-	overrideURLs                                        []string
-	userAgent                                           string
+	// requestIDSeq and stats are first to ensure 64-bit alignment for
+	// atomic use.
+	requestIDSeq uint64
+	stats        clientStats
+	client       *http.Client
+	ServiceURLs  []string
+	AuthToken    string
+	// tokenLock guards AuthToken once the token renewal is running.
+	tokenLock        sync.RWMutex
+	renewAhead       time.Duration
+	authConfig       *AuthConfig
+	authProviderName string
+	auth             AuthProvider
+	overrideURLs     []string
+	userAgent        string
+	requestIDHeader  string
+	requestIDRun     string
+	observer         func(info *RequestInfo)
+	signer           func(req *http.Request) error
+	breaker          *circuitBreaker
+	budget           *retryBudget
+	retry            *RetryPolicy
+	query            string
+	stallTimeout     time.Duration
+	account          string
+	serviceRegion    string
+	// ctx is from WithContext, or nil.
+	ctx context.Context
+	// authLock serializes every use of auth, as providers are not safe for
+	// concurrent use and the token renewal may be refreshing it.
+	authLock sync.Mutex
+}
+
+// clientStats are the counters reported by Stats; they are only accessed
+// atomically.
+type clientStats struct {
+	requests        int64
+	active          int64
+	retries         int64
+	stalls          int64
+	authentications int64
+	bytesSent       int64
+	bytesReceived   int64
+	connsOpened     int64
+	connsReused     int64
+	tlsHandshakes   int64
+	http2Conns      int64
+}
+
+// Stats returns the client's internal counters: requests sent, requests
+// active (sent but not yet completed), retries, stalls, authentications,
+// request and response body bytes sent and received, and connections opened
+// and reused, TLS handshakes done, and connections that negotiated HTTP/2.
+// This is part of the ClientStats interface.
+func (c *userClient) Stats() map[string]int64 {
+	return map[string]int64{
+		"requests":        atomic.LoadInt64(&c.stats.requests),
+		"active":          atomic.LoadInt64(&c.stats.active),
+		"retries":         atomic.LoadInt64(&c.stats.retries),
+		"stalls":          atomic.LoadInt64(&c.stats.stalls),
+		"authentications": atomic.LoadInt64(&c.stats.authentications),
+		"bytes_sent":      atomic.LoadInt64(&c.stats.bytesSent),
+		"bytes_received":  atomic.LoadInt64(&c.stats.bytesReceived),
+		"conns_opened":    atomic.LoadInt64(&c.stats.connsOpened),
+		"conns_reused":    atomic.LoadInt64(&c.stats.connsReused),
+		"tls_handshakes":  atomic.LoadInt64(&c.stats.tlsHandshakes),
+		"http2_conns":     atomic.LoadInt64(&c.stats.http2Conns),
+	}
+}
+
+// connTrace counts the connections requests are sent on, and the TLS
+// handshakes done for them, in the client's stats.
+func (c *userClient) connTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&c.stats.connsReused, 1)
+			} else {
+				atomic.AddInt64(&c.stats.connsOpened, 1)
+			}
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				atomic.AddInt64(&c.stats.tlsHandshakes, 1)
+				if state.NegotiatedProtocol == "h2" {
+					atomic.AddInt64(&c.stats.http2Conns, 1)
+				}
+			}
+		},
+	}
+}
+
+// tracedTransport adds a trace from newTrace to every request, including those
+// made by the auth provider. A trace is made for each request as
+// httptrace.WithClientTrace modifies the trace given to it.
+type tracedTransport struct {
+	http.RoundTripper
+	newTrace func() *httptrace.ClientTrace
+}
+
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.RoundTripper.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), t.newTrace())))
+}
+
+// ClientOption configures optional behavior of a client created with
+// NewClient or NewInsecureClient.
+type ClientOption func(c *userClient)
+
+// WithRequestIDs causes every request to be stamped with a client-generated
+// identifier in the given header, X-Trans-Id-Extra if header is empty. The
+// identifier is the runID followed by a per-request sequence number; if runID
+// is empty a random UUID will be used. With Swift|Hummingbird, the
+// X-Trans-Id-Extra value is appended to the server's transaction ID, allowing
+// client and server logs to be correlated precisely.
+func WithRequestIDs(header string, runID string) ClientOption {
+	return func(c *userClient) {
+		if header == "" {
+			header = "X-Trans-Id-Extra"
+		}
+		if runID == "" {
+			runID = nectarutil.UUID()
+		}
+		c.requestIDHeader = header
+		c.requestIDRun = runID
+	}
+}
+
+// WithHTTP2 offers HTTP/2 when connecting to https endpoints, using it where
+// the server agrees during the TLS handshake; otherwise HTTP/1.1 is always
+// used. With HTTP/2, requests to an endpoint are multiplexed over a single
+// connection rather than each having their own. The protocol used for a
+// request is given by its response's Proto.
+func WithHTTP2() ClientOption {
+	return func(c *userClient) {
+		if ht := c.transport(); ht != nil {
+			ht.TLSNextProto = nil
+			ht.ForceAttemptHTTP2 = true
+		}
+	}
+}
+
+// WithHTTPClient has the client make its requests, including those to
+// authenticate, with a copy of the given http.Client rather than its own, for
+// callers that need their own Transport, proxying, or timeouts. Should its
+// Transport be an *http.Transport, that too is copied, and options such as
+// WithTLSConfig and WithHTTP2 given after this one adjust the copy; the given
+// client and its Transport are never modified.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *userClient) {
+		hc := *client
+		if hc.Transport == nil {
+			hc.Transport = http.DefaultTransport
+		}
+		if ht, ok := hc.Transport.(*http.Transport); ok {
+			hc.Transport = ht.Clone()
+		}
+		hc.Transport = &tracedTransport{RoundTripper: hc.Transport, newTrace: c.connTrace}
+		c.client = &hc
+		c.authConfig.HTTPClient = c.client
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for https endpoints, such as
+// to trust a private cluster's internal CA or present a client certificate;
+// see LoadTLSConfig. It replaces the TLS configuration NewInsecureClient
+// would use.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(c *userClient) {
+		if ht := c.transport(); ht != nil {
+			ht.TLSClientConfig = config
+		}
+	}
+}
+
+// WithTimeout sets the overall time limit for each request, including reading
+// the response body; the default is 30 minutes and zero means no limit.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *userClient) {
+		c.client.Timeout = timeout
+	}
+}
+
+// WithContext makes every request the client makes, including any retries,
+// the waits between them, and reauthentication, with the context, so
+// cancelling it or its deadline passing ends them all at once; such as for a
+// deadline on a whole transfer, each request of which still has its own
+// WithTimeout.
+func WithContext(ctx context.Context) ClientOption {
+	return func(c *userClient) {
+		c.ctx = ctx
+		c.authConfig.Context = ctx
+	}
+}
+
+// transport returns the client's *http.Transport, for options to adjust, or
+// nil if WithHTTPClient gave it some other http.RoundTripper.
+func (c *userClient) transport() *http.Transport {
+	if t, ok := c.client.Transport.(*tracedTransport); ok {
+		if ht, ok := t.RoundTripper.(*http.Transport); ok {
+			return ht
+		}
+	}
+	return nil
+}
+
+// http1Only is the Transport.TLSNextProto that keeps HTTP/2 from being
+// negotiated unless WithHTTP2 is given.
+func http1Only() map[string]func(string, *tls.Conn) http.RoundTripper {
+	return map[string]func(string, *tls.Conn) http.RoundTripper{}
 }
 
 // NewClient creates a new end-user client. It authenticates immediately, and
 // returns the error response if unable to.
-func NewClient(tenant string, username string, password string, apikey string, region string, authurl string, private bool, overrideURLs []string) (Client, *http.Response) {
+func NewClient(tenant string, username string, password string, apikey string, region string, authurl string, private bool, overrideURLs []string, opts ...ClientOption) (Client, *http.Response) {
 	c := &userClient{
 		client: &http.Client{
 			Timeout: 30 * time.Minute,
@@ -38,34 +246,41 @@ func NewClient(tenant string, username string, password string, apikey string, r
 				MaxIdleConns:        0,
 				IdleConnTimeout:     5 * time.Second,
 				DisableCompression:  true,
+				TLSNextProto:        http1Only(),
 			},
 		},
-		tenant:    tenant,
-		username:  username,
-		password:  password,
-		apikey:    apikey,
-		region:    region,
-		authurl:   authurl,
-		private:   private,
 		userAgent: "Nectar",
 	}
+	c.client.Transport = &tracedTransport{RoundTripper: c.client.Transport, newTrace: c.connTrace}
+	c.authConfig = &AuthConfig{AuthURL: authurl, Tenant: tenant, Username: username, Password: password, APIKey: apikey, Region: region, Private: private, HTTPClient: c.client}
 	for _, u := range overrideURLs {
 		if u != "" {
 			c.overrideURLs = append(c.overrideURLs, u)
 		}
 	}
+	c.authConfig.OverrideURLs = c.overrideURLs
+	for _, opt := range opts {
+		opt(c)
+	}
+	var err error
+	if c.auth, err = newAuthProvider(c.authProviderName, c.authConfig); err != nil {
+		return nil, nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	}
 	if aResp := c.authenticate(); aResp.StatusCode/100 != 2 {
 		return nil, aResp
 	} else {
 		aResp.Body.Close()
 	}
+	if c.renewAhead > 0 {
+		go c.renewTokens()
+	}
 	return c, nil
 }
 
 // NewInsecureClient creates a new end-user client with SSL verification turned
 // off. It authenticates immediately, and returns the error response if unable
 // to.
-func NewInsecureClient(tenant string, username string, password string, apikey string, region string, authurl string, private bool) (Client, *http.Response) {
+func NewInsecureClient(tenant string, username string, password string, apikey string, region string, authurl string, private bool, opts ...ClientOption) (Client, *http.Response) {
 	c := &userClient{
 		client: &http.Client{
 			Timeout: 30 * time.Minute,
@@ -75,49 +290,538 @@ func NewInsecureClient(tenant string, username string, password string, apikey s
 				MaxIdleConns:        0,
 				IdleConnTimeout:     5 * time.Second,
 				DisableCompression:  true,
+				TLSNextProto:        http1Only(),
 			},
 		},
-		tenant:    tenant,
-		username:  username,
-		password:  password,
-		apikey:    apikey,
-		region:    region,
-		authurl:   authurl,
-		private:   private,
 		userAgent: "Nectar",
 	}
+	c.client.Transport = &tracedTransport{RoundTripper: c.client.Transport, newTrace: c.connTrace}
+	c.authConfig = &AuthConfig{AuthURL: authurl, Tenant: tenant, Username: username, Password: password, APIKey: apikey, Region: region, Private: private, HTTPClient: c.client}
+	for _, opt := range opts {
+		opt(c)
+	}
+	var err error
+	if c.auth, err = newAuthProvider(c.authProviderName, c.authConfig); err != nil {
+		return nil, nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	}
 	if aResp := c.authenticate(); aResp.StatusCode/100 != 2 {
 		return nil, aResp
 	} else {
 		aResp.Body.Close()
 	}
+	if c.renewAhead > 0 {
+		go c.renewTokens()
+	}
 	return c, nil
 }
 
+// RequestInfo describes a completed request; see WithRequestObserver.
+type RequestInfo struct {
+	Method string
+	URL    string
+	// Proto is the protocol of the response, such as HTTP/1.1 or HTTP/2.0,
+	// or empty if Err is set.
+	Proto string
+	// RemoteAddr is the address of the connection the request was sent
+	// over, such as 192.0.2.1:443, or empty if no connection was made.
+	RemoteAddr string
+	// Status will be the status code of the response, or of the stub
+	// response if Err is set.
+	Status int
+	Err    error
+	// BytesSent is the number of request body bytes read by the transport.
+	BytesSent int64
+	// BytesReceived is the number of response body bytes read by the caller.
+	BytesReceived int64
+	// HeadersElapsed is the time until the response headers were received.
+	HeadersElapsed time.Duration
+	// Elapsed is the time until the response body was closed.
+	Elapsed time.Duration
+	Request *http.Request
+	Header  http.Header
+}
+
+// WithRequestObserver will call fn once each request completes, meaning once
+// its response body has been closed or the request failed outright. Note that
+// fn may be called concurrently from many goroutines. This option may be given
+// more than once, with each observer called in turn.
+func WithRequestObserver(fn func(info *RequestInfo)) ClientOption {
+	return func(c *userClient) {
+		if prev := c.observer; prev != nil {
+			c.observer = func(info *RequestInfo) {
+				prev(info)
+				fn(info)
+			}
+		} else {
+			c.observer = fn
+		}
+	}
+}
+
+// WithRequestSigner will call fn with each request just before it is sent,
+// after the X-Auth-Token and all other headers are set, allowing it to add
+// signatures or other headers required by a gateway in front of the cluster,
+// such as HMAC or JWT headers. The request is not sent if fn returns an error.
+// Retries to other endpoints are signed again, as their URLs differ. Note that
+// fn may be called concurrently from many goroutines. This option may be given
+// more than once, with each signer called in turn.
+func WithRequestSigner(fn func(req *http.Request) error) ClientOption {
+	return func(c *userClient) {
+		if prev := c.signer; prev != nil {
+			c.signer = func(req *http.Request) error {
+				if err := prev(req); err != nil {
+					return err
+				}
+				return fn(req)
+			}
+		} else {
+			c.signer = fn
+		}
+	}
+}
+
+// WithCircuitBreaker will stop sending requests to a service endpoint for the
+// cooldown period once the given number of consecutive failures (transport
+// errors or 5xx responses) have occurred with that endpoint. Requests will
+// instead go to other endpoints, if any; if all endpoints are unavailable a
+// 503 response stub will be returned.
+func WithCircuitBreaker(failures int, cooldown time.Duration) ClientOption {
+	return func(c *userClient) {
+		if failures > 0 {
+			c.breaker = newCircuitBreaker(failures, cooldown)
+		}
+	}
+}
+
+// WithRetryBudget allows requests failing with transport errors or 5xx
+// responses to be retried against other service endpoints, limiting the
+// total retries to the ratio (such as 0.1 for 10%) of requests made, plus a
+// small reserve. This prevents retry storms during cluster incidents. Requests
+// whose bodies cannot be rewound will not be retried.
+func WithRetryBudget(ratio float64) ClientOption {
+	return func(c *userClient) {
+		if ratio > 0 {
+			c.budget = newRetryBudget(ratio)
+		}
+	}
+}
+
+// WithRetryPolicy retries requests failing with transport errors or the
+// policy's retryable statuses, such as 429 or 503, waiting with exponential
+// backoff between attempts. Seekable request bodies, such as files, are
+// rewound for each attempt; requests whose bodies cannot be rewound are not
+// retried. With WithRetryBudget, these retries also draw on the budget.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *userClient) {
+		if policy.MaxAttempts > 1 {
+			c.retry = &policy
+		}
+	}
+}
+
+// WithTokenRenewal renews the auth token in the background the given time
+// ahead of its expiry, as reported by the auth provider, so long-running work
+// does not hit a window of 401s as the token expires under load. If the
+// provider does not report when its tokens expire, the token is renewed every
+// ahead instead. A failed renewal is retried shortly after, with the current
+// token still in use. The renewal runs for the life of the process, or until
+// the context given to WithContext is done.
+func WithTokenRenewal(ahead time.Duration) ClientOption {
+	return func(c *userClient) {
+		c.renewAhead = ahead
+	}
+}
+
+// WithQuery causes the given query parameters to be appended to every request
+// URL, allowing access to middleware features the Client does not explicitly
+// model. A parameter may be given more than once.
+func WithQuery(parameters url.Values) ClientOption {
+	return func(c *userClient) {
+		if len(parameters) > 0 {
+			c.query = "?" + parameters.Encode()
+		}
+	}
+}
+
+// WithAuthProvider authenticates with the provider registered under the name
+// with RegisterAuthProvider, rather than the built-in provider for the auth
+// URL.
+func WithAuthProvider(name string) ClientOption {
+	return func(c *userClient) {
+		c.authProviderName = name
+	}
+}
+
+// WithAccount directs requests to the named account, such as AUTH_xyz, in
+// place of the account in the storage URLs given by auth. This is for
+// operators whose reseller admin or service tokens are valid for accounts
+// other than their own.
+func WithAccount(account string) ClientOption {
+	return func(c *userClient) {
+		c.account = account
+	}
+}
+
 var _ Client = &userClient{}
 
+type requestTargetKey struct{}
+
+type requestRetriesKey struct{}
+
+// ResponseRetries returns how many times the request for the response was
+// retried, by a retry policy or by a retry budget moving it to another
+// endpoint, before the response was received; 0 if not retried or not known.
+func ResponseRetries(resp *http.Response) int {
+	if resp == nil || resp.Request == nil {
+		return 0
+	}
+	if retries, ok := resp.Request.Context().Value(requestRetriesKey{}).(*int64); ok {
+		return int(atomic.LoadInt64(retries))
+	}
+	return 0
+}
+
+// countRetry records a retry of the request, both in the client's stats and
+// for ResponseRetries.
+func (c *userClient) countRetry(req *http.Request) {
+	atomic.AddInt64(&c.stats.retries, 1)
+	if retries, ok := req.Context().Value(requestRetriesKey{}).(*int64); ok {
+		atomic.AddInt64(retries, 1)
+	}
+}
+
+// requestTarget records the service endpoint and the path after it for a
+// request, allowing the request to be redirected to another endpoint.
+type requestTarget struct {
+	endpoint string
+	path     string
+	// closer is the original request body, if made rewindable, to be
+	// closed once the request is finished with.
+	closer io.Closer
+}
+
 func (c *userClient) authedRequest(method string, path string, body io.Reader, headers map[string]string) (*http.Request, error) {
+	if c.query != "" {
+		if strings.Contains(path, "?") {
+			path += "&" + c.query[1:]
+		} else {
+			path += c.query
+		}
+	}
 	surl := c.ServiceURLs[rand.Intn(len(c.ServiceURLs))]
-	req, err := http.NewRequest(method, surl+path, body)
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, surl+path, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("X-Auth-Token", c.AuthToken)
+	target := &requestTarget{endpoint: surl, path: path}
+	rewindable(req, body, target)
+	ctx = context.WithValue(ctx, requestTargetKey{}, target)
+	ctx = context.WithValue(ctx, requestRetriesKey{}, new(int64))
+	req = req.WithContext(withPhaseTrace(ctx))
+	req.Header.Set("X-Auth-Token", c.GetToken())
 	req.Header.Set("User-Agent", c.userAgent)
+	if c.requestIDHeader != "" {
+		req.Header.Set(c.requestIDHeader, fmt.Sprintf("%s-%d", c.requestIDRun, atomic.AddUint64(&c.requestIDSeq, 1)))
+	}
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
 	return req, nil
 }
 
+// rewindable lets a request with a seekable body, such as a file, be sent
+// again by setting GetBody to seek back to where the body began. The
+// transport would close such a body after the first attempt, so it is instead
+// closed by do once the request is finished with.
+func rewindable(req *http.Request, body io.Reader, target *requestTarget) {
+	seeker, ok := body.(io.Seeker)
+	if !ok || req.GetBody != nil {
+		return
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if closer, ok := body.(io.Closer); ok {
+		target.closer = closer
+	}
+	req.Body = ioutil.NopCloser(body)
+	req.GetBody = func() (io.ReadCloser, error) {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(body), nil
+	}
+}
+
+// do sends the request, retrying as the retry policy allows, if any.
 func (c *userClient) do(req *http.Request) *http.Response {
-	resp, err := c.client.Do(req)
+	target, _ := req.Context().Value(requestTargetKey{}).(*requestTarget)
+	if target != nil && target.closer != nil {
+		defer target.closer.Close()
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := c.attempt(req)
+		if c.retry == nil || target == nil || attempt >= c.retry.MaxAttempts || req.Context().Err() != nil || !c.retry.retryable(resp, err) {
+			return finalResponse(req, resp, err)
+		}
+		nreq, rerr := redirectRequest(req, target, c.ServiceURLs[rand.Intn(len(c.ServiceURLs))])
+		if rerr != nil || (c.budget != nil && !c.budget.withdraw()) {
+			return finalResponse(req, resp, err)
+		}
+		delay := c.retry.delay(attempt, resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		c.countRetry(req)
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return finalResponse(req, nil, req.Context().Err())
+		}
+		req = nreq
+	}
+}
+
+// finalResponse returns the response, or a stub for err, with the request set
+// so ResponseRetries works for stubs too.
+func finalResponse(req *http.Request, resp *http.Response, err error) *http.Response {
 	if err != nil {
-		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+		resp = errorResponse(err)
+	}
+	if resp.Request == nil {
+		resp.Request = req
 	}
 	return resp
 }
 
+// attempt sends the request once, though any circuit breaker and retry budget
+// may move it to other endpoints.
+func (c *userClient) attempt(req *http.Request) (*http.Response, error) {
+	if c.breaker != nil || c.budget != nil {
+		return c.doWithFailover(req)
+	}
+	return c.send(req)
+}
+
+// doWithFailover sends the request while honoring any circuit breaker and
+// retry budget, moving the request to other endpoints as needed.
+func (c *userClient) doWithFailover(req *http.Request) (*http.Response, error) {
+	target, _ := req.Context().Value(requestTargetKey{}).(*requestTarget)
+	if target == nil {
+		return c.send(req)
+	}
+	if c.budget != nil {
+		c.budget.deposit()
+	}
+	tried := map[string]bool{}
+	for {
+		if c.breaker != nil && !c.breaker.allow(target.endpoint) {
+			tried[target.endpoint] = true
+			next := c.untriedEndpoint(tried)
+			if next == "" {
+				return nectarutil.ResponseStub(http.StatusServiceUnavailable, "Circuit breaker open for all endpoints."), nil
+			}
+			nreq, err := redirectRequest(req, target, next)
+			if err != nil {
+				return nectarutil.ResponseStub(http.StatusServiceUnavailable, "Circuit breaker open for "+target.endpoint+": "+err.Error()), nil
+			}
+			req = nreq
+			target = req.Context().Value(requestTargetKey{}).(*requestTarget)
+			continue
+		}
+		tried[target.endpoint] = true
+		resp, err := c.send(req)
+		failed := err != nil || resp.StatusCode/100 == 5
+		if c.breaker != nil {
+			c.breaker.record(target.endpoint, !failed)
+		}
+		if !failed || c.budget == nil {
+			return resp, err
+		}
+		next := c.untriedEndpoint(tried)
+		var nreq *http.Request
+		if next != "" {
+			nreq, _ = redirectRequest(req, target, next)
+		}
+		if nreq == nil || !c.budget.withdraw() {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		c.countRetry(req)
+		req = nreq
+		target = req.Context().Value(requestTargetKey{}).(*requestTarget)
+	}
+}
+
+// untriedEndpoint returns a random service endpoint not yet tried, or "" if
+// there are none.
+func (c *userClient) untriedEndpoint(tried map[string]bool) string {
+	var untried []string
+	for _, u := range c.ServiceURLs {
+		if !tried[u] {
+			untried = append(untried, u)
+		}
+	}
+	if len(untried) == 0 {
+		return ""
+	}
+	return untried[rand.Intn(len(untried))]
+}
+
+// redirectRequest returns a copy of the request aimed at another endpoint,
+// rewinding the body if needed; an error is returned if the body cannot be
+// rewound.
+func redirectRequest(req *http.Request, target *requestTarget, endpoint string) (*http.Request, error) {
+	var body io.ReadCloser
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("request body cannot be rewound")
+		}
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	nreq, err := http.NewRequest(req.Method, endpoint+target.path, body)
+	if err != nil {
+		return nil, err
+	}
+	nreq.ContentLength = req.ContentLength
+	nreq.GetBody = req.GetBody
+	nreq.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		nreq.Header[k] = v
+	}
+	return nreq.WithContext(context.WithValue(req.Context(), requestTargetKey{}, &requestTarget{endpoint: endpoint, path: target.path})), nil
+}
+
+// send signs the request, if configured, and issues it, watching for stalls
+// if configured.
+func (c *userClient) send(req *http.Request) (*http.Response, error) {
+	if c.signer != nil {
+		if err := c.signer(req); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, fmt.Errorf("signing %s %s: %s", req.Method, req.URL, err)
+		}
+	}
+	atomic.AddInt64(&c.stats.active, 1)
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &statsBody{ReadCloser: req.Body, bytes: &c.stats.bytesSent}
+	}
+	var resp *http.Response
+	var err error
+	if c.stallTimeout > 0 {
+		resp, err = c.sendWatched(req)
+	} else {
+		resp, err = c.sendObserved(req)
+	}
+	if err != nil {
+		atomic.AddInt64(&c.stats.active, -1)
+		return nil, err
+	}
+	resp.Body = &statsBody{ReadCloser: resp.Body, bytes: &c.stats.bytesReceived, active: &c.stats.active}
+	return resp, nil
+}
+
+// statsBody adds the bytes read through it to the bytes counter and, if
+// active is not nil, decrements the active counter once closed.
+type statsBody struct {
+	io.ReadCloser
+	bytes  *int64
+	active *int64
+	once   sync.Once
+}
+
+func (b *statsBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(b.bytes, int64(n))
+	return n, err
+}
+
+func (b *statsBody) Close() error {
+	if b.active != nil {
+		b.once.Do(func() { atomic.AddInt64(b.active, -1) })
+	}
+	return b.ReadCloser.Close()
+}
+
+// sendObserved issues the request once, reporting it to the observer, if any.
+func (c *userClient) sendObserved(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&c.stats.requests, 1)
+	if c.observer == nil {
+		return c.client.Do(req)
+	}
+	info := &RequestInfo{Method: req.Method, URL: req.URL.String(), Request: req}
+	var sent *countingReadCloser
+	if req.Body != nil && req.Body != http.NoBody {
+		sent = &countingReadCloser{ReadCloser: req.Body}
+		req.Body = sent
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(connInfo httptrace.GotConnInfo) {
+			info.RemoteAddr = connInfo.Conn.RemoteAddr().String()
+		},
+	}))
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	info.HeadersElapsed = time.Since(start)
+	if sent != nil {
+		info.BytesSent = atomic.LoadInt64(&sent.n)
+	}
+	if err != nil {
+		info.Err = err
+		info.Status = http.StatusBadRequest
+		info.Elapsed = info.HeadersElapsed
+		c.observer(info)
+		return nil, err
+	}
+	info.Status = resp.StatusCode
+	info.Proto = resp.Proto
+	info.Header = resp.Header
+	resp.Body = &observedBody{countingReadCloser: countingReadCloser{ReadCloser: resp.Body}, start: start, info: info, observer: c.observer}
+	return resp, nil
+}
+
+// countingReadCloser counts the bytes read through it.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(&r.n, int64(n))
+	return n, err
+}
+
+// observedBody reports its request's RequestInfo once closed.
+type observedBody struct {
+	countingReadCloser
+	start    time.Time
+	info     *RequestInfo
+	observer func(info *RequestInfo)
+	once     sync.Once
+}
+
+func (b *observedBody) Close() error {
+	err := b.countingReadCloser.Close()
+	b.once.Do(func() {
+		b.info.BytesReceived = atomic.LoadInt64(&b.n)
+		b.info.Elapsed = time.Since(b.start)
+		b.observer(b.info)
+	})
+	return err
+}
+
 func (c *userClient) doRequest(method string, path string, body io.Reader, headers map[string]string) *http.Response {
 	req, err := c.authedRequest(method, path, body, headers)
 	if err != nil {
@@ -133,11 +837,22 @@ func (c *userClient) GetURL() string {
 	return c.ServiceURLs[0]
 }
 
+// GetURLs returns every service endpoint in use. This is part of the
+// ClientEndpoints interface.
 func (c *userClient) GetURLs() []string {
 	return c.ServiceURLs
 }
 
+// GetRegion returns the region the service endpoints were chosen for, or ""
+// if not known, such as with auth v1 or override URLs. This is part of the
+// ClientEndpoints interface.
+func (c *userClient) GetRegion() string {
+	return c.serviceRegion
+}
+
 func (c *userClient) GetToken() string {
+	c.tokenLock.RLock()
+	defer c.tokenLock.RUnlock()
 	return c.AuthToken
 }
 
@@ -204,11 +919,11 @@ func (c *userClient) DeleteAccount(headers map[string]string) *http.Response {
 }
 
 func (c *userClient) PutContainer(container string, headers map[string]string) *http.Response {
-	return c.doRequest("PUT", "/"+container, nil, headers)
+	return c.doRequest("PUT", "/"+nectarutil.EscapeObjectPath(container), nil, headers)
 }
 
 func (c *userClient) PostContainer(container string, headers map[string]string) *http.Response {
-	return c.doRequest("POST", "/"+container, nil, headers)
+	return c.doRequest("POST", "/"+nectarutil.EscapeObjectPath(container), nil, headers)
 }
 
 func (c *userClient) GetContainer(container string, marker string, endMarker string, limit int, prefix string, delimiter string, reverse bool, headers map[string]string) ([]*ObjectRecord, *http.Response) {
@@ -220,7 +935,7 @@ func (c *userClient) GetContainer(container string, marker string, endMarker str
 	if reverse {
 		reverseStr = "true"
 	}
-	path := "/" + container + nectarutil.Mkquery(map[string]string{"marker": marker, "end_marker": endMarker, "prefix": prefix, "delimiter": delimiter, "limit": limitStr, "reverse": reverseStr})
+	path := "/" + nectarutil.EscapeObjectPath(container) + nectarutil.Mkquery(map[string]string{"marker": marker, "end_marker": endMarker, "prefix": prefix, "delimiter": delimiter, "limit": limitStr, "reverse": reverseStr})
 	req, err := c.authedRequest("GET", path, nil, headers)
 	if err != nil {
 		return nil, nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
@@ -248,7 +963,7 @@ func (c *userClient) GetContainerRaw(container string, marker string, endMarker
 	if reverse {
 		reverseStr = "true"
 	}
-	path := "/" + container + nectarutil.Mkquery(map[string]string{"marker": marker, "end_marker": endMarker, "prefix": prefix, "delimiter": delimiter, "limit": limitStr, "reverse": reverseStr})
+	path := "/" + nectarutil.EscapeObjectPath(container) + nectarutil.Mkquery(map[string]string{"marker": marker, "end_marker": endMarker, "prefix": prefix, "delimiter": delimiter, "limit": limitStr, "reverse": reverseStr})
 	req, err := c.authedRequest("GET", path, nil, headers)
 	if err != nil {
 		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
@@ -258,312 +973,560 @@ func (c *userClient) GetContainerRaw(container string, marker string, endMarker
 }
 
 func (c *userClient) HeadContainer(container string, headers map[string]string) *http.Response {
-	return c.doRequest("HEAD", "/"+container, nil, headers)
+	return c.doRequest("HEAD", "/"+nectarutil.EscapeObjectPath(container), nil, headers)
 }
 
 func (c *userClient) DeleteContainer(container string, headers map[string]string) *http.Response {
-	return c.doRequest("DELETE", "/"+container, nil, headers)
+	return c.doRequest("DELETE", "/"+nectarutil.EscapeObjectPath(container), nil, headers)
 }
 
 func (c *userClient) PutObject(container string, obj string, headers map[string]string, src io.Reader) *http.Response {
-	return c.doRequest("PUT", "/"+container+"/"+obj, src, headers)
+	return c.doRequest("PUT", "/"+nectarutil.EscapeObjectPath(container)+"/"+nectarutil.EscapeObjectPath(obj), src, headers)
 }
 
 func (c *userClient) PostObject(container string, obj string, headers map[string]string) *http.Response {
-	return c.doRequest("POST", "/"+container+"/"+obj, nil, headers)
+	return c.doRequest("POST", "/"+nectarutil.EscapeObjectPath(container)+"/"+nectarutil.EscapeObjectPath(obj), nil, headers)
 }
 
 func (c *userClient) GetObject(container string, obj string, headers map[string]string) *http.Response {
-	return c.doRequest("GET", "/"+container+"/"+obj, nil, headers)
+	return c.doRequest("GET", "/"+nectarutil.EscapeObjectPath(container)+"/"+nectarutil.EscapeObjectPath(obj), nil, headers)
+}
+
+// GetObjectIf GETs the object only if the conditions are met, returning a
+// *ResponseError otherwise that errors.Is ErrNotModified, for a failed
+// IfNoneMatch or IfModifiedSince, or ErrPreconditionFailed, for a failed
+// IfMatch or IfUnmodifiedSince. Any other response but a 2xx is returned as a
+// *ResponseError too; the response body is then closed.
+func GetObjectIf(c Client, container string, obj string, conditions *Conditions, headers map[string]string) (*http.Response, error) {
+	resp := c.GetObject(container, obj, conditions.headers(headers))
+	if resp.StatusCode/100 != 2 {
+		return resp, NewResponseError(resp)
+	}
+	return resp, nil
+}
+
+// PutObjectIf PUTs the object only if the conditions are met, as GetObjectIf
+// GETs, for optimistic concurrency: IfMatch with the ETag last read to
+// overwrite only what was read, or IfNoneMatch of * to only create the
+// object. A failed condition is a *ResponseError that errors.Is
+// ErrPreconditionFailed.
+func PutObjectIf(c Client, container string, obj string, conditions *Conditions, headers map[string]string, src io.Reader) (*http.Response, error) {
+	resp := c.PutObject(container, obj, conditions.headers(headers), src)
+	if resp.StatusCode/100 != 2 {
+		return resp, NewResponseError(resp)
+	}
+	return resp, nil
+}
+
+// headers returns a copy of the headers with the conditions added.
+func (conditions *Conditions) headers(headers map[string]string) map[string]string {
+	h := map[string]string{}
+	for k, v := range headers {
+		h[k] = v
+	}
+	if conditions == nil {
+		return h
+	}
+	quote := func(etag string) string {
+		if etag == "*" || strings.HasPrefix(etag, "\"") || strings.HasPrefix(etag, "W/") {
+			return etag
+		}
+		return "\"" + etag + "\""
+	}
+	if conditions.IfMatch != "" {
+		h["If-Match"] = quote(conditions.IfMatch)
+	}
+	if conditions.IfNoneMatch != "" {
+		h["If-None-Match"] = quote(conditions.IfNoneMatch)
+	}
+	if !conditions.IfModifiedSince.IsZero() {
+		h["If-Modified-Since"] = conditions.IfModifiedSince.UTC().Format(http.TimeFormat)
+	}
+	if !conditions.IfUnmodifiedSince.IsZero() {
+		h["If-Unmodified-Since"] = conditions.IfUnmodifiedSince.UTC().Format(http.TimeFormat)
+	}
+	return h
+}
+
+// GetObjectRange GETs the bytes of the object from start to end, inclusive,
+// with a Range header; a negative end reads to the end of the object, and a
+// negative start too reads the last -start bytes. The ContentRange gives where
+// the response's content lies in the object, including when a server ignores
+// the range and responds with the whole object, or just the object's Size with
+// a 416; it is nil if not known, such as for other errors.
+func GetObjectRange(c Client, container string, obj string, start int64, end int64, headers map[string]string) (*ContentRange, *http.Response) {
+	h := map[string]string{}
+	for k, v := range headers {
+		h[k] = v
+	}
+	switch {
+	case start < 0 && end < 0:
+		h["Range"] = fmt.Sprintf("bytes=%d", start)
+	case end < 0:
+		h["Range"] = fmt.Sprintf("bytes=%d-", start)
+	default:
+		h["Range"] = fmt.Sprintf("bytes=%d-%d", start, end)
+	}
+	resp := c.GetObject(container, obj, h)
+	return responseContentRange(resp), resp
 }
 
 func (c *userClient) HeadObject(container string, obj string, headers map[string]string) *http.Response {
-	return c.doRequest("HEAD", "/"+container+"/"+obj, nil, headers)
+	return c.doRequest("HEAD", "/"+nectarutil.EscapeObjectPath(container)+"/"+nectarutil.EscapeObjectPath(obj), nil, headers)
 }
 
 func (c *userClient) DeleteObject(container string, obj string, headers map[string]string) *http.Response {
-	return c.doRequest("DELETE", "/"+container+"/"+obj, nil, headers)
+	return c.doRequest("DELETE", "/"+nectarutil.EscapeObjectPath(container)+"/"+nectarutil.EscapeObjectPath(obj), nil, headers)
 }
 
-func (c *userClient) Raw(method, urlAfterAccount string, headers map[string]string, body io.Reader) *http.Response {
-	return c.doRequest(method, urlAfterAccount, body, headers)
+// bulkReport is the JSON the bulk middleware gives for ?bulk-delete and
+// ?extract-archive requests.
+type bulkReport struct {
+	NumberDeleted      int        `json:"Number Deleted"`
+	NumberNotFound     int        `json:"Number Not Found"`
+	NumberFilesCreated int        `json:"Number Files Created"`
+	ResponseStatus     string     `json:"Response Status"`
+	ResponseBody       string     `json:"Response Body"`
+	Errors             [][]string `json:"Errors"`
 }
 
-func (c *userClient) authenticatev1() *http.Response {
-	req, err := http.NewRequest("GET", c.authurl, nil)
-	if err != nil {
-		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+// err returns a *ResponseError if the request, given by resp, failed as a
+// whole rather than just for some of its items, or nil otherwise. The status
+// of the response itself is 200 either way, the real status being reported in
+// the body.
+func (r *bulkReport) err(resp *http.Response) error {
+	if strings.HasPrefix(r.ResponseStatus, "2") || len(r.Errors) > 0 {
+		return nil
 	}
-	au := c.username
-	if c.tenant != "" {
-		au = c.tenant + ":" + c.username
+	status, _ := strconv.Atoi(strings.SplitN(r.ResponseStatus, " ", 2)[0])
+	if status == 0 {
+		status = http.StatusInternalServerError
 	}
-	req.Header.Set("X-Auth-User", au)
-	ak := c.apikey
-	if ak == "" {
-		ak = c.password
+	stub := nectarutil.ResponseStub(status, r.ResponseBody)
+	stub.Header = resp.Header
+	stub.Request = resp.Request
+	return NewResponseError(stub)
+}
+
+// bulkDeleteMax is the most paths sent in one bulk delete request, the bulk
+// middleware's default max_deletes_per_request.
+const bulkDeleteMax = 10000
+
+// BulkDelete deletes the paths, each container/object or an empty container,
+// with the bulk middleware's ?bulk-delete, many per request. If the cluster
+// lacks the middleware, each path is deleted with its own DELETE instead. The
+// error is a *ResponseError for a request that failed as a whole; paths that
+// failed individually are in the result's Errors.
+func BulkDelete(c Client, paths []string, headers map[string]string) (*BulkDeleteResult, error) {
+	result := &BulkDeleteResult{Errors: map[string]string{}}
+	for start := 0; start < len(paths); start += bulkDeleteMax {
+		end := start + bulkDeleteMax
+		if end > len(paths) {
+			end = len(paths)
+		}
+		supported, err := bulkDelete(c, paths[start:end], headers, result)
+		if err != nil {
+			return result, err
+		}
+		if !supported {
+			deleteEach(c, paths[start:], headers, result)
+			break
+		}
 	}
-	req.Header.Set("X-Auth-Key", ak)
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	return result, nil
+}
+
+// bulkDelete deletes the paths with a single ?bulk-delete request, adding to
+// the result, and returns false if the cluster lacks the bulk middleware.
+func bulkDelete(c Client, paths []string, headers map[string]string, result *BulkDeleteResult) (bool, error) {
+	var body bytes.Buffer
+	// Errors are given for the paths as sent, so they are mapped back.
+	sent := map[string]string{}
+	for _, path := range paths {
+		escaped := "/" + nectarutil.EscapeObjectPath(strings.TrimPrefix(path, "/"))
+		sent[escaped] = path
+		body.WriteString(escaped + "\n")
 	}
-	if resp.StatusCode/100 != 2 {
-		return resp
+	h := map[string]string{"Accept": "application/json", "Content-Type": "text/plain"}
+	for k, v := range headers {
+		h[k] = v
 	}
-	c.AuthToken = resp.Header.Get("X-Auth-Token")
-	if c.AuthToken == "" {
-		resp.Body.Close()
-		return nectarutil.ResponseStub(http.StatusInternalServerError, "Response did not have X-Auth-Token header.")
+	resp := c.Raw("POST", "?bulk-delete", h, &body)
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return true, NewResponseError(resp)
 	}
-	if len(c.overrideURLs) > 0 {
-		c.ServiceURLs = make([]string, len(c.overrideURLs))
-		copy(c.ServiceURLs, c.overrideURLs)
-	} else {
-		surl := resp.Header.Get("X-Storage-Url")
-		if surl == "" {
-			resp.Body.Close()
-			return nectarutil.ResponseStub(http.StatusInternalServerError, "Response did not have X-Storage-Url header.")
+	// Without the middleware, the POST goes to the account, usually giving a
+	// 204 and never the JSON report.
+	var report bulkReport
+	err := json.NewDecoder(resp.Body).Decode(&report)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || err != nil || report.ResponseStatus == "" {
+		return false, nil
+	}
+	result.NumberDeleted += report.NumberDeleted
+	result.NumberNotFound += report.NumberNotFound
+	for _, e := range report.Errors {
+		if len(e) != 2 {
+			continue
 		}
-		c.ServiceURLs = []string{surl}
+		path, ok := sent[e[0]]
+		if !ok {
+			path = e[0]
+		}
+		result.Errors[path] = e[1]
 	}
-	return resp
+	return true, report.err(resp)
 }
 
-type keystoneRequestV2 struct {
-	Auth interface{} `json:"auth"`
-}
-
-type keystonePasswordAuthV2 struct {
-	TenantName          string `json:"tenantName"`
-	PasswordCredentials struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	} `json:"passwordCredentials"`
-}
-
-type raxAPIKeyAuthV2 struct {
-	APIKeyCredentials struct {
-		Username string `json:"username"`
-		APIKey   string `json:"apiKey"`
-	} `json:"RAX-KSKEY:apiKeyCredentials"`
-}
-
-type keystoneResponseV2 struct {
-	Access struct {
-		Token struct {
-			ID     string `json:"id"`
-			Tenant struct {
-				Name string `json:"name"`
-				ID   string `json:"id"`
-			} `json:"tenant"`
-		} `json:"token"`
-		ServiceCatalog []struct {
-			Endpoints []struct {
-				PublicURL   string `json:"publicURL"`
-				InternalURL string `json:"internalURL"`
-				Region      string `json:"region"`
-			} `json:"endpoints"`
-			Type string `json:"type"`
-		} `json:"serviceCatalog"`
-		User struct {
-			RaxDefaultRegion string `json:"RAX-AUTH:defaultRegion"`
-		} `json:"user"`
-	} `json:"access"`
-}
-
-func (c *userClient) authenticatev2() *http.Response {
-	if !strings.HasSuffix(c.authurl, "tokens") {
-		if c.authurl[len(c.authurl)-1] == '/' {
-			c.authurl = c.authurl + "tokens"
-		} else {
-			c.authurl = c.authurl + "/tokens"
-		}
-	}
-	var authReq []byte
-	var err error
-	if c.password != "" {
-		creds := &keystonePasswordAuthV2{TenantName: c.tenant}
-		creds.PasswordCredentials.Username = c.username
-		creds.PasswordCredentials.Password = c.password
-		authReq, err = json.Marshal(&keystoneRequestV2{Auth: creds})
-	} else if c.apikey != "" {
-		creds := &raxAPIKeyAuthV2{}
-		creds.APIKeyCredentials.Username = c.username
-		creds.APIKeyCredentials.APIKey = c.apikey
-		authReq, err = json.Marshal(&keystoneRequestV2{Auth: creds})
-	} else {
-		return nectarutil.ResponseStub(http.StatusInternalServerError, "Couldn't figure out what credentials to use.")
+// ExtractArchive uploads the archive from src, of the format tar, tar.gz, or
+// tar.bz2, for the bulk middleware's ?extract-archive to expand server-side,
+// each file becoming an object named for its path in the archive under the
+// container and prefix, which may be "". With no container, the first
+// directory of each path names its container. The error is a *ResponseError
+// for a request that failed as a whole; files that failed individually are
+// in the result's Errors.
+func ExtractArchive(c Client, container string, prefix string, format string, src io.Reader, headers map[string]string) (*ExtractArchiveResult, error) {
+	switch format {
+	case "tar", "tar.gz", "tar.bz2":
+	default:
+		return nil, fmt.Errorf("unknown archive format %q; use tar, tar.gz, or tar.bz2", format)
 	}
-	if err != nil {
-		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	path := ""
+	if container != "" {
+		path = "/" + nectarutil.EscapeObjectPath(container)
+		if prefix = strings.Trim(prefix, "/"); prefix != "" {
+			path += "/" + nectarutil.EscapeObjectPath(prefix)
+		}
 	}
-	resp, err := c.client.Post(c.authurl, "application/json", bytes.NewBuffer(authReq))
-	if err != nil {
-		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	h := map[string]string{"Accept": "application/json"}
+	for k, v := range headers {
+		h[k] = v
 	}
+	resp := c.Raw("PUT", path+"?extract-archive="+format, h, src)
+	defer resp.Body.Close()
 	if resp.StatusCode/100 != 2 {
-		return resp
-	}
-	var authResponse keystoneResponseV2
-	if err := json.NewDecoder(resp.Body).Decode(&authResponse); err != nil {
-		resp.Body.Close()
-		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+		return nil, NewResponseError(resp)
 	}
-	resp.Body.Close()
-	c.AuthToken = authResponse.Access.Token.ID
-	region := c.region
-	if region == "" {
-		region = authResponse.Access.User.RaxDefaultRegion
+	var report bulkReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil || report.ResponseStatus == "" {
+		// Without the middleware, the PUT creates the container instead.
+		return nil, fmt.Errorf("PUT %s?extract-archive=%s gave no report; the cluster may not have the bulk middleware", path, format)
 	}
-	if len(c.overrideURLs) > 0 {
-		c.ServiceURLs = make([]string, len(c.overrideURLs))
-		copy(c.ServiceURLs, c.overrideURLs)
-	} else {
-		c.ServiceURLs = nil
-		for _, s := range authResponse.Access.ServiceCatalog {
-			if s.Type == "object-store" {
-				for _, e := range s.Endpoints {
-					if e.Region == region || region == "" || len(s.Endpoints) == 1 {
-						if c.private {
-							c.ServiceURLs = append(c.ServiceURLs, e.InternalURL)
-						} else {
-							c.ServiceURLs = append(c.ServiceURLs, e.PublicURL)
-						}
-					}
-				}
-			}
+	result := &ExtractArchiveResult{NumberFilesCreated: report.NumberFilesCreated, Errors: map[string]string{}}
+	for _, e := range report.Errors {
+		if len(e) == 2 {
+			result.Errors[strings.TrimPrefix(e[0], "/")] = e[1]
 		}
 	}
-	if len(c.ServiceURLs) < 1 {
-		return nectarutil.ResponseStub(http.StatusInternalServerError, "Didn't find endpoint")
-	}
-	return nectarutil.ResponseStub(http.StatusOK, "")
-}
-
-type keystoneRequestV3 struct {
-	Auth struct {
-		Identity struct {
-			Methods  []string `json:"methods"`
-			Password struct {
-				User struct {
-					Name   string `json:"name"`
-					Domain struct {
-						Name string `json:"name"`
-					} `json:"domain"`
-					Password string `json:"password"`
-				} `json:"user"`
-			} `json:"password"`
-		} `json:"identity"`
-	} `json:"auth"`
-}
-
-type keystoneResponseV3 struct {
-	Token struct {
-		Catalog []struct {
-			Type      string `json:"type"`
-			Endpoints []struct {
-				Region    string `json:"region"`
-				URL       string `json:"url"`
-				Interface string `json:"interface"`
-			} `json:"endpoints"`
-		} `json:"catalog"`
-	} `json:"token"`
-}
-
-func (c *userClient) authenticatev3() *http.Response {
-	if !strings.HasSuffix(c.authurl, "auth/tokens") {
-		if c.authurl[len(c.authurl)-1] == '/' {
-			c.authurl = c.authurl + "auth/tokens"
+	return result, report.err(resp)
+}
+
+// deleteEach deletes the paths one DELETE at a time, adding to the result.
+func deleteEach(c Client, paths []string, headers map[string]string, result *BulkDeleteResult) {
+	for _, path := range paths {
+		var resp *http.Response
+		parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+		if len(parts) == 1 {
+			resp = c.DeleteContainer(parts[0], headers)
 		} else {
-			c.authurl = c.authurl + "/auth/tokens"
+			resp = c.DeleteObject(parts[0], parts[1], headers)
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode/100 == 2:
+			result.NumberDeleted++
+		case resp.StatusCode == http.StatusNotFound:
+			result.NumberNotFound++
+		default:
+			result.Errors[path] = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
 		}
 	}
-	var authReq []byte
-	var err error
-	if c.password != "" {
-		creds := &keystoneRequestV3{}
-		creds.Auth.Identity.Methods = []string{"password"}
-		creds.Auth.Identity.Password.User.Domain.Name = "Default"
-		creds.Auth.Identity.Password.User.Name = c.username
-		creds.Auth.Identity.Password.User.Password = c.password
-		authReq, err = json.Marshal(creds)
-	} else if c.apikey != "" {
-		panic("v3 by api key not implemented yet: please use password instead")
-	} else {
-		return nectarutil.ResponseStub(http.StatusInternalServerError, "Couldn't figure out what credentials to use.")
+}
+
+// CopyObject copies the source object to the destination server-side, so the
+// data never passes through the client, using a PUT with X-Copy-From. The
+// headers are for the destination; the source's metadata is kept unless
+// X-Fresh-Metadata: true is given.
+func CopyObject(c Client, srcContainer string, srcObj string, dstContainer string, dstObj string, headers map[string]string) *http.Response {
+	h := map[string]string{}
+	for k, v := range headers {
+		h[k] = v
 	}
-	if err != nil {
-		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	h["X-Copy-From"] = "/" + nectarutil.EscapeObjectPath(srcContainer) + "/" + nectarutil.EscapeObjectPath(srcObj)
+	return c.PutObject(dstContainer, dstObj, h, nil)
+}
+
+// ContainerExists HEADs the container, returning false with a nil error if it
+// does not exist; any response other than a 2xx or 404 is returned as a
+// *ResponseError. The response body is always closed.
+func ContainerExists(c Client, container string, headers map[string]string) (bool, *ContainerInfo, error) {
+	resp := c.HeadContainer(container, headers)
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return false, nil, nil
 	}
-	resp, err := c.client.Post(c.authurl, "application/json", bytes.NewBuffer(authReq))
-	if err != nil {
-		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	if resp.StatusCode/100 != 2 {
+		return false, nil, NewResponseError(resp)
+	}
+	resp.Body.Close()
+	return true, newContainerInfo(resp.Header), nil
+}
+
+// ObjectExists HEADs the object, returning false with a nil error if it does
+// not exist; any response other than a 2xx or 404 is returned as a
+// *ResponseError. The response body is always closed.
+func ObjectExists(c Client, container string, obj string, headers map[string]string) (bool, *ObjectInfo, error) {
+	resp := c.HeadObject(container, obj, headers)
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return false, nil, nil
 	}
 	if resp.StatusCode/100 != 2 {
-		return resp
+		return false, nil, NewResponseError(resp)
 	}
-	defer resp.Body.Close()
-	c.AuthToken = resp.Header.Get("X-Subject-Token")
-	if c.AuthToken == "" {
-		return nectarutil.ResponseStub(http.StatusInternalServerError, "No X-Subject-Token in response.")
+	resp.Body.Close()
+	return true, newObjectInfo(resp.Header), nil
+}
+
+// HeadAccountInfo HEADs the account, returning the information parsed from
+// the response headers; any response other than a 2xx is returned as a
+// *ResponseError. The response body is always closed.
+func HeadAccountInfo(c Client, headers map[string]string) (*AccountInfo, error) {
+	resp := c.HeadAccount(headers)
+	if resp.StatusCode/100 != 2 {
+		return nil, NewResponseError(resp)
 	}
-	var authResponse keystoneResponseV3
-	if err := json.NewDecoder(resp.Body).Decode(&authResponse); err != nil {
-		return nectarutil.ResponseStub(http.StatusInternalServerError, err.Error())
+	resp.Body.Close()
+	info := &AccountInfo{Metadata: headerMetadata(resp.Header), Header: resp.Header}
+	info.ContainerCount, _ = strconv.ParseInt(resp.Header.Get("X-Account-Container-Count"), 10, 64)
+	info.ObjectCount, _ = strconv.ParseInt(resp.Header.Get("X-Account-Object-Count"), 10, 64)
+	info.BytesUsed, _ = strconv.ParseInt(resp.Header.Get("X-Account-Bytes-Used"), 10, 64)
+	return info, nil
+}
+
+// HeadContainerInfo HEADs the container as HeadAccountInfo does the account;
+// a container that does not exist is a *ResponseError with StatusCode 404,
+// whereas ContainerExists does not treat it as an error.
+func HeadContainerInfo(c Client, container string, headers map[string]string) (*ContainerInfo, error) {
+	resp := c.HeadContainer(container, headers)
+	if resp.StatusCode/100 != 2 {
+		return nil, NewResponseError(resp)
 	}
-	intrfc := "public"
-	if c.private {
-		intrfc = "private"
+	resp.Body.Close()
+	return newContainerInfo(resp.Header), nil
+}
+
+// HeadObjectInfo HEADs the object as HeadContainerInfo does the container.
+func HeadObjectInfo(c Client, container string, obj string, headers map[string]string) (*ObjectInfo, error) {
+	resp := c.HeadObject(container, obj, headers)
+	if resp.StatusCode/100 != 2 {
+		return nil, NewResponseError(resp)
 	}
-	if len(c.overrideURLs) > 0 {
-		c.ServiceURLs = make([]string, len(c.overrideURLs))
-		copy(c.ServiceURLs, c.overrideURLs)
-	} else {
-		c.ServiceURLs = nil
-		for _, s := range authResponse.Token.Catalog {
-			if s.Type == "object-store" {
-				for _, e := range s.Endpoints {
-					if ((e.Region == c.region || c.region == "") && e.Interface == intrfc) || len(s.Endpoints) == 1 {
-						c.ServiceURLs = append(c.ServiceURLs, e.URL)
-					}
-				}
-			}
+	resp.Body.Close()
+	return newObjectInfo(resp.Header), nil
+}
+
+// newContainerInfo returns the ContainerInfo from a container HEAD's header.
+func newContainerInfo(header http.Header) *ContainerInfo {
+	info := &ContainerInfo{StoragePolicy: header.Get("X-Storage-Policy"), Metadata: headerMetadata(header), Header: header}
+	info.ObjectCount, _ = strconv.ParseInt(header.Get("X-Container-Object-Count"), 10, 64)
+	info.BytesUsed, _ = strconv.ParseInt(header.Get("X-Container-Bytes-Used"), 10, 64)
+	return info
+}
+
+// newObjectInfo returns the ObjectInfo from an object HEAD's header.
+func newObjectInfo(header http.Header) *ObjectInfo {
+	info := &ObjectInfo{ContentType: header.Get("Content-Type"), ETag: strings.Trim(header.Get("Etag"), "\""), Metadata: headerMetadata(header), Header: header}
+	info.ContentLength, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	info.LastModified, _ = http.ParseTime(header.Get("Last-Modified"))
+	return info
+}
+
+// headerMetadata returns the user metadata in the header keyed by name.
+func headerMetadata(header http.Header) map[string]string {
+	metadata := map[string]string{}
+	for k, vs := range header {
+		if name := metaHeaderName(k); name != "" && len(vs) > 0 {
+			metadata[name] = vs[0]
 		}
 	}
-	if len(c.ServiceURLs) < 1 {
-		return nectarutil.ResponseStub(http.StatusInternalServerError, "Didn't find endpoint")
-	}
-	return nectarutil.ResponseStub(http.StatusOK, "")
+	return metadata
+}
+
+func (c *userClient) Raw(method, urlAfterAccount string, headers map[string]string, body io.Reader) *http.Response {
+	return c.doRequest(method, urlAfterAccount, body, headers)
 }
 
 func (c *userClient) authenticate() *http.Response {
 	var resp *http.Response
+	var state *authState
 	sleep := time.Second
 	for attempt := 1; attempt <= 3 && (resp == nil || resp.StatusCode/100 != 2); attempt++ {
 		if resp != nil && resp.StatusCode/100 != 2 {
 			time.Sleep(sleep)
 			sleep *= 2
 		}
-		if strings.Contains(c.authurl, "/v3") {
-			resp = c.authenticatev3()
-		} else if strings.Contains(c.authurl, "/v2") {
-			resp = c.authenticatev2()
-		} else {
-			resp = c.authenticatev1()
-		}
+		resp, state = c.refreshAuth(c.auth.Refresh)
+		atomic.AddInt64(&c.stats.authentications, 1)
 	}
 	if resp.StatusCode/100 == 2 {
-		resp2 := c.HeadAccount(nil)
-		if resp2.StatusCode/100 != 2 {
-			bodyBytes, _ := ioutil.ReadAll(resp2.Body)
-			resp2.Body.Close()
-			return nectarutil.ResponseStub(resp2.StatusCode, fmt.Sprintf("Error response from HEAD on account %v :\r\n\r\n %s", c.ServiceURLs, bodyBytes))
+		return c.useAuth(resp, state)
+	}
+	return resp
+}
+
+// authState is what the auth provider gave after a successful refresh.
+type authState struct {
+	token     string
+	endpoints []string
+	region    string
+}
+
+// refreshAuth calls refresh, the provider's Refresh or SwitchProject, and on
+// success returns the token and service URLs the provider then gives, all
+// under authLock.
+func (c *userClient) refreshAuth(refresh func() *http.Response) (*http.Response, *authState) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+	resp := refresh()
+	if resp.StatusCode/100 != 2 {
+		return resp, nil
+	}
+	state := &authState{token: c.auth.GetToken(), endpoints: append([]string(nil), c.auth.GetEndpoints()...)}
+	if r, ok := c.auth.(interface {
+		GetRegion() string
+	}); ok {
+		state.region = r.GetRegion()
+	}
+	return resp, state
+}
+
+// useAuth takes the token and service URLs from the auth provider's state,
+// applying any override URLs and WithAccount, and then checks the account can
+// be reached; resp is the provider's successful response, returned if all is
+// well.
+func (c *userClient) useAuth(resp *http.Response, state *authState) *http.Response {
+	c.tokenLock.Lock()
+	c.AuthToken = state.token
+	c.tokenLock.Unlock()
+	c.serviceRegion = ""
+	if len(c.overrideURLs) > 0 {
+		c.ServiceURLs = make([]string, len(c.overrideURLs))
+		copy(c.ServiceURLs, c.overrideURLs)
+	} else {
+		c.ServiceURLs = state.endpoints
+		c.serviceRegion = state.region
+	}
+	if len(c.ServiceURLs) < 1 {
+		resp.Body.Close()
+		return nectarutil.ResponseStub(http.StatusInternalServerError, "Didn't find endpoint")
+	}
+	c.applyAccount()
+	return c.checkAccount(resp)
+}
+
+// applyAccount replaces the account, the last path segment, of each service
+// URL with the one given by WithAccount, if any.
+func (c *userClient) applyAccount() {
+	if c.account == "" {
+		return
+	}
+	for i, surl := range c.ServiceURLs {
+		surl = strings.TrimSuffix(surl, "/")
+		if slash := strings.LastIndex(surl, "/"); slash >= 0 {
+			c.ServiceURLs[i] = surl[:slash+1] + c.account
 		}
 	}
+}
+
+// checkAccount returns resp if a HEAD of the account succeeds, or otherwise a
+// stub describing the failure.
+func (c *userClient) checkAccount(resp *http.Response) *http.Response {
+	resp2 := c.HeadAccount(nil)
+	if resp2.StatusCode/100 != 2 {
+		bodyBytes, _ := ioutil.ReadAll(resp2.Body)
+		resp2.Body.Close()
+		return nectarutil.ResponseStub(resp2.StatusCode, fmt.Sprintf("Error response from HEAD on account %v :\r\n\r\n %s", c.ServiceURLs, bodyBytes))
+	}
+	resp2.Body.Close()
 	return resp
 }
 
+// SwitchProject re-scopes the client's token to the named project, if the
+// auth provider supports that as the Keystone v2 and v3 providers do, and
+// takes the service URLs from the new catalog; the credentials are not sent
+// again, and any later reauthentication is for the new project. It must not be
+// called while other requests are in progress. This is part of the
+// ClientProject interface.
+func (c *userClient) SwitchProject(project string) *http.Response {
+	switcher, ok := c.auth.(interface {
+		SwitchProject(project string) *http.Response
+	})
+	if !ok {
+		return nectarutil.ResponseStub(http.StatusBadRequest, "Switching projects requires Keystone auth v2 or v3.")
+	}
+	resp, state := c.refreshAuth(func() *http.Response { return switcher.SwitchProject(project) })
+	atomic.AddInt64(&c.stats.authentications, 1)
+	if resp.StatusCode/100 != 2 {
+		return resp
+	}
+	return c.useAuth(resp, state)
+}
+
+// tokenRenewalRetry is how long after a failed token renewal it is retried,
+// and the least time between renewals.
+const tokenRenewalRetry = 30 * time.Second
+
+// renewTokens renews the token ahead of its expiry; see WithTokenRenewal.
+// Only the token is taken from the renewal, as the service URLs may be in use.
+func (c *userClient) renewTokens() {
+	var done <-chan struct{}
+	if c.ctx != nil {
+		done = c.ctx.Done()
+	}
+	sleep := func(d time.Duration) bool {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	for {
+		wait := c.renewAhead
+		c.authLock.Lock()
+		if e, ok := c.auth.(interface {
+			GetExpiry() time.Time
+		}); ok {
+			if expiry := e.GetExpiry(); !expiry.IsZero() {
+				wait = time.Until(expiry) - c.renewAhead
+			}
+		}
+		c.authLock.Unlock()
+		if wait < tokenRenewalRetry {
+			wait = tokenRenewalRetry
+		}
+		if !sleep(wait) {
+			return
+		}
+		var state *authState
+		for state == nil {
+			var resp *http.Response
+			resp, state = c.refreshAuth(c.auth.Refresh)
+			atomic.AddInt64(&c.stats.authentications, 1)
+			resp.Body.Close()
+			if state == nil && !sleep(tokenRenewalRetry) {
+				return
+			}
+		}
+		c.tokenLock.Lock()
+		c.AuthToken = state.token
+		c.tokenLock.Unlock()
+	}
+}
+
 func (c *userClient) SetUserAgent(v string) {
 	c.userAgent = v
 }
@@ -0,0 +1,55 @@
+package nectar
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// errExists is returned for a write that -no-clobber stopped as the
+// destination object already exists.
+var errExists = errors.New("destination object exists")
+
+// clobberHeaders adds the conditions of the upload and copy -no-clobber and
+// -if-unmodified options to the headers for writing container/object. With
+// -if-unmodified, the object is HEADed now so the write can be made
+// conditional on it being unchanged since.
+func (cli *CLIInstance) clobberHeaders(c Client, container string, object string, headers map[string]string) error {
+	if cli.clobberFlagNoClobber {
+		headers["If-None-Match"] = "*"
+		return nil
+	}
+	if !cli.clobberFlagIfUnmodified {
+		return nil
+	}
+	exists, info, err := ObjectExists(c, container, object, cli.globalFlagHeaders.Headers())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		// Another writer creating it in between is a change too.
+		headers["If-None-Match"] = "*"
+		return nil
+	}
+	headers["If-Match"] = `"` + info.ETag + `"`
+	if lastModified := info.Header.Get("Last-Modified"); lastModified != "" {
+		headers["If-Unmodified-Since"] = lastModified
+	}
+	return nil
+}
+
+// clobberError returns the error for the unsuccessful response to writing
+// container/object, errExists if stopped by -no-clobber.
+func (cli *CLIInstance) clobberError(resp *http.Response, container string, object string) error {
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		if cli.clobberFlagNoClobber {
+			resp.Body.Close()
+			return errExists
+		}
+		if cli.clobberFlagIfUnmodified {
+			err := NewResponseError(resp)
+			return fmt.Errorf("%s/%s was changed by another writer since it was checked, so was not overwritten: %s", container, object, err)
+		}
+	}
+	return NewResponseError(resp)
+}
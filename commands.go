@@ -0,0 +1,395 @@
+package nectar
+
+import (
+	"flag"
+	"sort"
+	"sync"
+)
+
+// Command is a subcommand of the CLI; see RegisterCommand.
+type Command struct {
+	// Name is what the command is invoked as.
+	Name string
+	// Usage is the synopsis following the name in the help text, such as
+	// "[options] <container> [object]".
+	Usage string
+	// Help describes the command in the help text, wrapped for output.
+	Help string
+	// Flags returns the flag set of the command's options, with
+	// flag.ContinueOnError, for the help text and for Run to parse its
+	// arguments with. It is called afresh for each run of the command, so
+	// concurrent runs do not share flag values. It may be nil if the command
+	// has no options.
+	Flags func(cli *CLIInstance) *flag.FlagSet
+	// Run executes the command with its arguments, still to be parsed with
+	// flags, the flag set Flags returned for this run, if any. The client c
+	// is authenticated, unless NoAuth. Run should report failures with
+	// cli.Fatal or cli.Fatalf.
+	Run func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string)
+	// NoAuth has CLI run the command without authenticating, with a nil
+	// client, for commands that only work with local files.
+	NoAuth bool
+}
+
+var commandsLock sync.Mutex
+var commands = map[string]*Command{}
+
+// RegisterCommand adds the command to those run by CLI and CLIInstance.Run,
+// replacing any, including a built-in command, with the same name; so
+// distributions can add cluster-specific commands without patching nectar.
+// Commands should be registered before calling CLI, such as from an init
+// function. Registered commands are listed with the built-in ones in the help
+// text, in name order.
+func RegisterCommand(cmd *Command) {
+	commandsLock.Lock()
+	commands[cmd.Name] = cmd
+	commandsLock.Unlock()
+}
+
+// lookupCommand returns the command registered with the name, or nil.
+func lookupCommand(name string) *Command {
+	commandsLock.Lock()
+	defer commandsLock.Unlock()
+	return commands[name]
+}
+
+// sortedCommands returns the registered commands in name order.
+func sortedCommands() []*Command {
+	commandsLock.Lock()
+	defer commandsLock.Unlock()
+	cmds := make([]*Command, 0, len(commands))
+	for _, cmd := range commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// Fatal reports err with the CLIInstance's fatal function, which for
+// flag.ErrHelp outputs the help text; for registered commands.
+func (cli *CLIInstance) Fatal(err error) {
+	cli.fatal(cli, err)
+}
+
+// Fatalf reports the formatted message with the CLIInstance's fatalf
+// function; for registered commands.
+func (cli *CLIInstance) Fatalf(frmt string, args ...interface{}) {
+	cli.fatalf(cli, frmt, args...)
+}
+
+// Verbosef outputs the formatted message if verbose output is on; for
+// registered commands.
+func (cli *CLIInstance) Verbosef(frmt string, args ...interface{}) {
+	cli.verbosef(cli, frmt, args...)
+}
+
+func init() {
+	for _, cmd := range builtinCommands {
+		RegisterCommand(cmd)
+	}
+}
+
+// builtinCommands are the commands nectar itself provides.
+var builtinCommands = []*Command{
+	{
+		Name:  "auth",
+		Usage: "[options]",
+		Help: `
+Displays information retrieved after authentication, such as the Account URL. With -v, a summary of the account itself is included. With -project, the token is first re-scoped to another project, giving the Account URL and token to use for it.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.AuthFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.auth(c, args) },
+	},
+	{
+		Name:  "backup",
+		Usage: "[options] <sourcepath> <container>",
+		Help: `
+Backs up a directory to the container, uploading its regular files, -C at a time, under <name>/<timestamp>/ and then writing a manifest object, <name>/manifests/<timestamp>.json, listing every file with its object, size, MD5, mode, and modification time. The backup is incremental: files whose size and modification time are unchanged since the previous backup with the same name are not uploaded again, their manifest entries referring to the earlier objects instead, so every manifest still describes the complete directory. An interrupted backup writes no manifest. See restore.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.BackupFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.backup(c, args) },
+	},
+	{
+		Name:  "bench-delete",
+		Usage: "[options] <container> [object]",
+		Help: `
+Benchmark tests DELETEs. By default, 1000 DELETEs are done against the named <container>. If you specify [object] it will be used as a prefix for the object names, otherwise "bench-" will be used. Generally, you would use bench-put to populate the containers and objects, and then use bench-delete with the same options to test the deletions.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.BenchDeleteFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.benchDelete(c, args) },
+	},
+	{
+		Name:  "bench-get",
+		Usage: "[options] <container> [object]",
+		Help: `
+Benchmark tests GETs. By default, 1000 GETs are done from the named <container>. If you specify [object] it will be used as the prefix for the object names, otherwise "bench-" will be used. Generally, you would use bench-put to populate the containers and objects, and then use bench-get with the same options with the possible addition of -iterations to lengthen the test time.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.BenchGetFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.benchGet(c, args) },
+	},
+	{
+		Name:  "bench-head",
+		Usage: "[options] <container> [object]",
+		Help: `
+Benchmark tests HEADs. By default, 1000 HEADs are done from the named <container>. If you specify [object] it will be used as the prefix for the object names, otherwise "bench-" will be used. Generally, you would use bench-put to populate the containers and objects, and then use bench-head with the same options with the possible addition of -iterations to lengthen the test time.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.BenchHeadFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.benchHead(c, args) },
+	},
+	{
+		Name:  "bench-mixed",
+		Usage: "[options] <container> [object]",
+		Help: `
+Benchmark tests mixed request workloads. If you specify [object] it will be used as a prefix for the object names, otherwise "bench-" will be used. This test is made to be run for a specific span of time (10 minutes by default). You probably want to run with the -continue-on-error global flag; due to the eventual consistency model of Swift|Hummingbird, a few requests may 404.
+
+Note: The concurrency setting for this test will be used for each request type separately. So, with five request types (PUT, POST, GET, HEAD, DELETE), this means five times the concurrency value specified.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.BenchMixedFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.benchMixed(c, args) },
+	},
+	{
+		Name:  "bench-post",
+		Usage: "[options] <container> [object]",
+		Help: `
+Benchmark tests POSTs. By default, 1000 POSTs are done against the named <container>. If you specify [object] it will be used as a prefix for the object names, otherwise "bench-" will be used. Generally, you would use bench-put to populate the containers and objects, and then use bench-post with the same options to test POSTing.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.BenchPostFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.benchPost(c, args) },
+	},
+	{
+		Name:  "bench-put",
+		Usage: "[options] <container> [object]",
+		Help: `
+Benchmark tests PUTs. By default, 1000 PUTs are done into the named <container>. If you specify [object] it will be used as a prefix for the object names, otherwise "bench-" will be used.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.BenchPutFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.benchPut(c, args) },
+	},
+	{
+		Name:  "copy",
+		Usage: "[options] <container>[/object] <container>[/object]",
+		Help: `
+Copies an object, server-side, from the first location to the second; if the destination object name is omitted the source object name is used. With -r, every object under the source prefix is copied concurrently (see -C), with the listing paged through as needed and a summary of any failures given at the end.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.CopyFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.copy(c, args) },
+	},
+	{
+		Name:  "delete",
+		Usage: "[options] [container] [object]",
+		Help: `
+Performs a DELETE request. A DELETE, as probably expected, is used to remove the target.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.DeleteFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.delet(c, args) },
+	},
+	{
+		Name:  "download",
+		Usage: "[options] [container] [object] <destpath>",
+		Help: `
+Downloads an object or objects to a local file or files. The <destpath> indicates where you want the file or files to be created; it may only be left out in a directory set up with init. If you don't give [container] [object] the entire account will be downloaded (requires -a for confirmation). If you just give [container] that entire container will be downloaded. Perhaps obviously, if you give [container] [object] just that object will be downloaded. Each file is written under a temporary .nectar-tmp name and renamed into place only once its size, and MD5 where the ETag allows, has been verified, along with any checksum stored by upload -checksum.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.DownloadFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.download(c, args) },
+	},
+	{
+		Name:  "direct",
+		Usage: "[options] <container> <object>",
+		Help: `
+For operators, GETs or HEADs the object through the proxy and then directly from each of the object servers holding it, bypassing the proxy, outputting a table of each response's status, timing, size, ETag, and timestamp; useful for diagnosing whether slowness lives in the proxy or storage tier. The object servers are found from the object ring with -ring or given with -server; either way, the partition is calculated using the cluster's hash path prefix and suffix unless given with -partition. The requests are made one at a time so their timings do not affect each other.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.DirectFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.direct(c, args) },
+	},
+	{
+		Name:  "du",
+		Usage: "[options] [container] ...",
+		Help: `
+Outputs a table of the object count and bytes used by each container named, or with -a by every container in the account, along with the totals. The figures come from HEADing each container, -C at a time, rather than paging through the object listings, so they are as current as the container's own, possibly lagging, statistics.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.DuFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.du(c, args) },
+	},
+	{
+		Name:  "export-listing",
+		Usage: "[options] <container> <file>",
+		Help: `
+Writes the container's full listing, each object's name, ETag, size, last modified time, and content type, to <file>, or to standard output if <file> is -, as JSON or CSV, for external systems to reconcile against; the listing is paged through as needed and written as it is read. See import-check.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.ExportListingFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.exportListing(c, args) },
+	},
+	{
+		Name:  "get",
+		Usage: "[options] [container] [object]",
+		Help: `
+Performs a GET request. A GET on an account or container will output the listing of containers or objects, respectively. A GET on an object will output the content of the object to standard output.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.GetFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.get(c, args) },
+	},
+	{
+		Name:  "head",
+		Usage: "[options] [container] [object]",
+		Help: `
+Performs a HEAD request, giving overall information about the account, container, or object. User metadata will be listed in a Metadata section with the header prefix stripped and the values URL decoded, well known system headers such as quotas, storage policy, and ACLs will be labeled in a System section, and the remaining headers will be listed as is. With the global -plain option all headers are listed as is, as tab separated values.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.HeadFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.head(c, args) },
+	},
+	{
+		Name:  "help",
+		Usage: "[subcommand] ...",
+		Help: `
+Outputs the help text for just the subcommands given, or for everything, including the global options, if none are given.
+`,
+		Run:    func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.help(args) },
+		NoAuth: true,
+	},
+	{
+		Name:  "import-check",
+		Usage: "[options] <container> <file>",
+		Help: `
+Checks that every object in <file>, a listing as written by export-listing or from standard input if <file> is -, still exists in the container with the same ETag, and outputs a line for each that does not, such as "missing photos/cat.jpg" or "changed photos/dog.jpg"; with the global -json option, the names are output as JSON lists of missing and changed. A CSV file need only have a header row with a name column, and a hash column for the ETags to be checked. The container is listed, from the longest prefix the names share, rather than each object HEADed. Exits with status 1 if any object is missing or changed.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.ImportCheckFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.importCheck(c, args) },
+	},
+	{
+		Name:  "init",
+		Usage: "[options] <container> [prefix]",
+		Help: `
+Records the container, and optionally an object name prefix such as site/v1/, in a .nectar file in the current directory. Running upload or download in that directory without arguments then uploads the directory to, or downloads the directory from, that container and prefix; with upload, the .nectar file itself is not uploaded, and with download, the prefix is removed from the local file names. Giving upload a <sourcepath> but no [container] also uses the recorded container and prefix. No authentication is needed.
+`,
+		Flags:  func(cli *CLIInstance) *flag.FlagSet { return cli.InitFlags },
+		Run:    func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.initDir(args) },
+		NoAuth: true,
+	},
+	{
+		Name:  "move",
+		Usage: "[options] <container>[/object] <container>[/object]",
+		Help: `
+Moves an object from the first location to the second by copying it server-side and then, once the copy's ETag has been verified against the source's, deleting the source; if the destination object name is omitted the source object name is used. Of a static or dynamic large object, just the manifest is moved, its segments staying where they are; the copy is verified by the ETag and size of the large object instead. With -r, every object under the source prefix is moved concurrently (see -C) with a summary of any failures given at the end.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.MoveFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.move(c, args) },
+	},
+	{
+		Name:  "post",
+		Usage: "[options] [container] [object]",
+		Help: `
+Performs a POST request. POSTs allow you to update the metadata for the target. Without [container], the target is the account, so -m sets or, with an empty value, removes X-Account-Meta- items, which head then lists in its Metadata section. For containers and the account, items not given are left as they were; for objects, a POST replaces all their metadata.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.PostFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.post(c, args) },
+	},
+	{
+		Name:  "put",
+		Usage: "[options] [container] [object]",
+		Help: `
+Performs a PUT request. A PUT to an account or container will create them. A PUT to an object will create it using the content from standard input.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.PutFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.put(c, args) },
+	},
+	{
+		Name:  "raw",
+		Usage: "[options] <method> [path]",
+		Help: `
+Performs a request with any method to the path after the account URL, such as /container/object?multipart-manifest=get, for debugging middleware or reaching features nectar does not otherwise support. The global -H and -Q options add headers and query parameters. Standard input is sent as the request body for methods other than GET, HEAD, DELETE, and OPTIONS. The response status, headers, and body are output as is; the exit status is 1 if the response was not a success.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.RawFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.raw(c, args) },
+	},
+	{
+		Name:  "restore",
+		Usage: "[options] <container> <name> <destpath>",
+		Help: `
+Rebuilds the directory tree of a backup made with backup under <destpath>, from the latest backup with the name or the one chosen with -manifest, downloading its files -C at a time and restoring their modes and modification times. Files already present with the right size and MD5 are not downloaded again, so an interrupted restore can simply be rerun. Files in <destpath> that are not in the backup are left alone.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.RestoreFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.restore(c, args) },
+	},
+	{
+		Name:  "segments-gc",
+		Usage: "[options] <segment container>",
+		Help: `
+Outputs the names of the objects in the segment container that no large object manifest refers to, such as those left by failed uploads or by manifests since overwritten or deleted without their segments; with -confirm, they are deleted. Every object in the manifest containers is HEADed, -C at a time, and the manifests of static large objects read, following any nested within them; everything under a dynamic large object's prefix is considered in use. Any failure while finding the manifests is fatal, even with -continue-on-error, as segments still in use would otherwise be reported. Manifests in containers not given with -manifest-container are not known of, so their segments will be reported too. Segments whose age the listing does not give are skipped, and reported on standard error.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.SegmentsGCFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.segmentsGC(c, args) },
+	},
+	{
+		Name:  "shards",
+		Usage: "[options] <container>",
+		Help: `
+Outputs the sharding state of the container and, where the cluster exposes them, its shard ranges: the bounds, state, object count, and bytes of each shard and its share of the objects, useful when benchmarking very large containers. The shard ranges are requested with X-Backend-Record-Type: shard, which the gatekeeper middleware usually removes for all but internal clients; the sharding state likewise comes from X-Backend-Sharding-State, if given.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.ShardsFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.shards(c, args) },
+	},
+	{
+		Name:  "snapshot-diff",
+		Usage: "[options] <older snapshot> <newer snapshot>",
+		Help: `
+Compares two snapshots saved by snapshot-list and outputs a line for each object created, deleted, or modified (its ETag or size changed) between them, such as "created photos/cat.jpg"; with the global -json option, the names are output as JSON lists of created, deleted, and modified. With -v, the counts are also output.
+`,
+		Flags:  func(cli *CLIInstance) *flag.FlagSet { return cli.SnapshotDiffFlags },
+		Run:    func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.snapshotDiff(c, args) },
+		NoAuth: true,
+	},
+	{
+		Name:  "snapshot-list",
+		Usage: "[options] <container> <snapshot>",
+		Help: `
+Saves the container's listing, each object's name, ETag, and size, as JSON to the local file <snapshot>, or with -object to an object, for comparing with a later snapshot with snapshot-diff; a lightweight way of tracking changes. The listing is paged through as needed.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.SnapshotListFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.snapshotList(c, args) },
+	},
+	{
+		Name:  "tag",
+		Usage: "[options] <container> <object>",
+		Help: `
+Sets or removes tags on an object and then outputs its tags. Tags are key/value labels, like S3 object tags, kept as X-Object-Meta-Tag-<key> metadata; keys are case insensitive. Since a metadata POST replaces all of an object's metadata, the object's other metadata is read and sent again with the change; changes made by others in between will be lost.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.TagFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.tag(c, args) },
+	},
+	{
+		Name:  "tagged",
+		Usage: "[options] <key>[=<value>] <container>",
+		Help: `
+Outputs the names of the objects in the container having the tag, with the value if given. As listings do not include metadata, every object is HEADed, -C at a time.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.TaggedFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.tagged(c, args) },
+	},
+	{
+		Name:  "tempurl",
+		Usage: "[options] <container> [object]",
+		Help: `
+Outputs a temporary URL for the object: a URL anyone can use, without authenticating, for the -method until it expires, signed with the container's or account's Temp-URL-Key. The cluster must have the tempurl middleware enabled. With -prefix, the URL is valid for every object beginning with [object].
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.TempURLFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.tempurl(c, args) },
+	},
+	{
+		Name:  "upload",
+		Usage: "[options] <sourcepath> [container] [object]",
+		Help: `
+Uploads local files as objects. If you don't specify [container] the container recorded by init, or else the name of the current directory, will be used. If you don't specify [object] the relative path name from the current directory will be used. If you do specify [object] while uploading a directory, [object] will be used as a prefix to the resulting object names. Note that when uploading a directory, only regular files will be uploaded, and not necessarily in name order; with -report-interval, the files found so far are reported along with the upload progress.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.UploadFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.upload(c, args) },
+	},
+	{
+		Name:  "versioning",
+		Usage: "[options] <container> [object]",
+		Help: `
+Outputs whether versioning is enabled for the container, and in which mode to which archive container; -enable and -disable change it first. In stack mode, deleting an object restores its previous version; in history mode, deleting an object archives it too. With [object], the previous versions of the object in the archive container are listed instead, or with -restore, one of them is copied, server-side, over the object; in stack mode, the object's current version is itself archived first.
+`,
+		Flags: func(cli *CLIInstance) *flag.FlagSet { return cli.VersioningFlags },
+		Run:   func(cli *CLIInstance, c Client, flags *flag.FlagSet, args []string) { cli.versioning(c, args) },
+	},
+}
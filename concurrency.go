@@ -0,0 +1,99 @@
+package nectar
+
+import (
+	"sync"
+	"time"
+)
+
+// adaptiveLimiter limits the number of concurrent operations, adjusting the
+// limit based on observed latency and errors: additive increase while things
+// look healthy, multiplicative decrease when errors occur or latency climbs
+// well above the best seen (AIMD). A nil *adaptiveLimiter is valid and
+// imposes no limits.
+type adaptiveLimiter struct {
+	lock       sync.Mutex
+	cond       *sync.Cond
+	limit      int
+	max        int
+	active     int
+	count      int
+	failures   int
+	latencySum time.Duration
+	baseline   time.Duration
+	onChange   func(limit int)
+}
+
+// newAdaptiveLimiter returns a limiter starting at a concurrency of 1 that
+// will never exceed max; onChange, if not nil, is called whenever the limit
+// changes.
+func newAdaptiveLimiter(max int, onChange func(limit int)) *adaptiveLimiter {
+	if max < 1 {
+		max = 1
+	}
+	l := &adaptiveLimiter{limit: 1, max: max, onChange: onChange}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+// acquire blocks until an operation may begin.
+func (l *adaptiveLimiter) acquire() {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+	l.lock.Unlock()
+}
+
+// release marks the end of an operation started at the given time; status is
+// the response status code, with 5xx, 429, and 498 considered signs of
+// overload.
+func (l *adaptiveLimiter) release(start time.Time, status int) {
+	if l == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	l.lock.Lock()
+	l.active--
+	l.count++
+	l.latencySum += elapsed
+	if status/100 == 5 || status == 429 || status == 498 {
+		l.failures++
+	}
+	window := l.limit
+	if window < 8 {
+		window = 8
+	}
+	if l.count >= window {
+		avg := l.latencySum / time.Duration(l.count)
+		// The baseline slowly drifts upward so one unusually fast window
+		// doesn't hold the limit down forever.
+		l.baseline += l.baseline / 100
+		if l.baseline == 0 || avg < l.baseline {
+			l.baseline = avg
+		}
+		limit := l.limit
+		if l.failures > 0 || avg > l.baseline*2 {
+			limit /= 2
+			if limit < 1 {
+				limit = 1
+			}
+		} else if limit < l.max {
+			limit++
+		}
+		l.count = 0
+		l.failures = 0
+		l.latencySum = 0
+		if limit != l.limit {
+			l.limit = limit
+			if l.onChange != nil {
+				l.onChange(limit)
+			}
+		}
+	}
+	l.cond.Broadcast()
+	l.lock.Unlock()
+}
@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package nectar
+
+import "time"
+
+// processCPUTime is not supported on this platform.
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,22 @@
+package nectar
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns the user and kernel CPU time used by the process.
+func processCPUTime() (time.Duration, bool) {
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, false
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err = syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return 0, false
+	}
+	// Filetimes here are durations in 100ns units, not times.
+	ticks := uint64(kernel.HighDateTime)<<32 | uint64(kernel.LowDateTime)
+	ticks += uint64(user.HighDateTime)<<32 | uint64(user.LowDateTime)
+	return time.Duration(ticks * 100), true
+}
@@ -0,0 +1,82 @@
+package nectar
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// overTimeCSV writes the -csvot rows of a bench: the number of requests
+// completed in each fixed bucket of time from the start of the run, one
+// column per kind of request. Rows are written at exactly start+N*interval,
+// whether or not anything completed, with the elapsed seconds alongside so
+// the output of different runs can be overlaid directly; the final row
+// covers the partial bucket at the end of the run. A nil *overTimeCSV is valid
+// and records nothing.
+type overTimeCSV struct {
+	w        *csvWriter
+	interval time.Duration
+	counts   []int64
+	start    time.Time
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// newOverTimeCSV returns nil if w is nil; otherwise it writes the header
+// with a column for each name given.
+func newOverTimeCSV(w *csvWriter, interval time.Duration, columns ...string) *overTimeCSV {
+	if w == nil {
+		return nil
+	}
+	w.Write(append([]string{"time_unix_nano", "elapsed_seconds"}, columns...))
+	return &overTimeCSV{w: w, interval: interval, counts: make([]int64, len(columns)), stop: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+// begin writes the initial row of zeros for the start time and starts the
+// bucketing.
+func (o *overTimeCSV) begin(start time.Time) {
+	if o == nil {
+		return
+	}
+	o.start = start
+	o.write(start)
+	go func() {
+		for bucket := time.Duration(1); ; bucket++ {
+			timer := time.NewTimer(time.Until(start.Add(bucket * o.interval)))
+			select {
+			case <-timer.C:
+				o.write(start.Add(bucket * o.interval))
+			case <-o.stop:
+				timer.Stop()
+				close(o.stopped)
+				return
+			}
+		}
+	}()
+}
+
+// add records a completed request of the kind in the given column.
+func (o *overTimeCSV) add(column int) {
+	if o == nil {
+		return
+	}
+	atomic.AddInt64(&o.counts[column], 1)
+}
+
+// finish writes the final, partial bucket ending at stop.
+func (o *overTimeCSV) finish(stop time.Time) {
+	if o == nil {
+		return
+	}
+	close(o.stop)
+	<-o.stopped
+	o.write(stop)
+}
+
+func (o *overTimeCSV) write(at time.Time) {
+	row := []string{fmt.Sprintf("%d", at.UnixNano()), fmt.Sprintf("%.03f", at.Sub(o.start).Seconds())}
+	for i := range o.counts {
+		row = append(row, fmt.Sprintf("%d", atomic.SwapInt64(&o.counts[i], 0)))
+	}
+	o.w.Write(row)
+}
@@ -0,0 +1,108 @@
+package nectar
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// csvWriter writes CSV records to a file from a dedicated goroutine so the
+// callers, usually bench request goroutines, do not contend on a lock and disk
+// I/O for every record. If the filename ends in .gz the output will be gzip
+// compressed. Write is safe for concurrent use; Close must be called once all
+// writes are done.
+type csvWriter struct {
+	f        *os.File
+	buf      *bufio.Writer
+	gz       *gzip.Writer
+	csvw     *csv.Writer
+	recChan  chan []string
+	doneChan chan struct{}
+	err      error
+}
+
+func newCSVWriter(filename string) (*csvWriter, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	w := &csvWriter{
+		f:        f,
+		buf:      bufio.NewWriterSize(f, 256*1024),
+		recChan:  make(chan []string, 1024),
+		doneChan: make(chan struct{}),
+	}
+	var dst io.Writer = w.buf
+	if strings.HasSuffix(filename, ".gz") {
+		w.gz = gzip.NewWriter(w.buf)
+		dst = w.gz
+	}
+	w.csvw = csv.NewWriter(dst)
+	go w.run()
+	return w, nil
+}
+
+func (w *csvWriter) run() {
+	// The periodic flush keeps the file reasonably current in case the
+	// process exits without calling Close, such as on a fatal error.
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case rec, ok := <-w.recChan:
+			if !ok {
+				close(w.doneChan)
+				return
+			}
+			if err := w.csvw.Write(rec); err != nil && w.err == nil {
+				w.err = err
+			}
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+func (w *csvWriter) flush() {
+	w.csvw.Flush()
+	if err := w.csvw.Error(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+	if err := w.buf.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+// Write queues the record to be written.
+func (w *csvWriter) Write(rec []string) {
+	w.recChan <- rec
+}
+
+// Close writes any queued records, flushes all buffers, and closes the file,
+// returning the first error encountered, if any.
+func (w *csvWriter) Close() error {
+	close(w.recChan)
+	<-w.doneChan
+	w.flush()
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil && w.err == nil {
+			w.err = err
+		}
+		if err := w.buf.Flush(); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+	if err := w.f.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
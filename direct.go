@@ -0,0 +1,295 @@
+package nectar
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/troubling/nectar/nectarutil"
+)
+
+// objectRing is the part of a Swift or Hummingbird object ring needed to find
+// the primary devices for an object.
+type objectRing struct {
+	partShift        uint
+	devs             []*ringDevice
+	replica2part2dev [][]uint16
+}
+
+type ringDevice struct {
+	ID     int    `json:"id"`
+	IP     string `json:"ip"`
+	Port   int    `json:"port"`
+	Device string `json:"device"`
+}
+
+// loadObjectRing reads a ring file, such as object.ring.gz, in the version 1
+// R1NG format written by Swift and Hummingbird.
+func loadObjectRing(path string) (*objectRing, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("Could not read ring %s: %s", path, err)
+	}
+	var magic [4]byte
+	if _, err = io.ReadFull(gz, magic[:]); err != nil || string(magic[:]) != "R1NG" {
+		return nil, fmt.Errorf("%s is not a ring in the R1NG format", path)
+	}
+	var version uint16
+	if err = binary.Read(gz, binary.BigEndian, &version); err != nil {
+		return nil, fmt.Errorf("Could not read ring %s: %s", path, err)
+	}
+	if version != 1 {
+		return nil, fmt.Errorf("Ring %s is in format version %d; only version 1 is supported", path, version)
+	}
+	var length uint32
+	if err = binary.Read(gz, binary.BigEndian, &length); err != nil {
+		return nil, fmt.Errorf("Could not read ring %s: %s", path, err)
+	}
+	b := make([]byte, length)
+	if _, err = io.ReadFull(gz, b); err != nil {
+		return nil, fmt.Errorf("Could not read ring %s: %s", path, err)
+	}
+	var meta struct {
+		ByteOrder    string        `json:"byteorder"`
+		PartShift    uint          `json:"part_shift"`
+		ReplicaCount float64       `json:"replica_count"`
+		Devs         []*ringDevice `json:"devs"`
+	}
+	if err = json.Unmarshal(b, &meta); err != nil {
+		return nil, fmt.Errorf("Could not parse ring %s: %s", path, err)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if meta.ByteOrder == "little" {
+		order = binary.LittleEndian
+	}
+	ring := &objectRing{partShift: meta.PartShift, devs: meta.Devs}
+	parts := 1 << (32 - meta.PartShift)
+	replicas := int(math.Ceil(meta.ReplicaCount))
+	for r := 0; r < replicas; r++ {
+		b := make([]byte, 2*parts)
+		n, err := io.ReadFull(gz, b)
+		if err == io.ErrUnexpectedEOF && r == replicas-1 {
+			// With a fractional replica count, the last replica covers
+			// only some of the partitions.
+			b = b[:n]
+		} else if err != nil {
+			return nil, fmt.Errorf("Could not read ring %s: %s", path, err)
+		}
+		row := make([]uint16, len(b)/2)
+		for i := range row {
+			row[i] = order.Uint16(b[2*i:])
+		}
+		ring.replica2part2dev = append(ring.replica2part2dev, row)
+	}
+	return ring, nil
+}
+
+// primaries returns the primary devices for the partition.
+func (r *objectRing) primaries(partition uint32) []*ringDevice {
+	var devs []*ringDevice
+	seen := map[int]bool{}
+	for _, row := range r.replica2part2dev {
+		if int(partition) >= len(row) {
+			continue
+		}
+		id := int(row[partition])
+		if id >= len(r.devs) || r.devs[id] == nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		devs = append(devs, r.devs[id])
+	}
+	return devs
+}
+
+// objectPartition returns the partition of the object, given the ring's
+// partition shift and the cluster's hash path prefix and suffix.
+func objectPartition(partShift uint, prefix string, suffix string, account string, container string, object string) uint32 {
+	sum := md5.Sum([]byte(prefix + "/" + account + "/" + container + "/" + object + suffix))
+	return binary.BigEndian.Uint32(sum[:4]) >> partShift
+}
+
+// readHashPathConf returns the swift_hash_path_prefix and
+// swift_hash_path_suffix set in the config file, such as swift.conf or
+// hummingbird.conf.
+func readHashPathConf(path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	var prefix, suffix string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(parts[0]) {
+		case "swift_hash_path_prefix":
+			prefix = strings.TrimSpace(parts[1])
+		case "swift_hash_path_suffix":
+			suffix = strings.TrimSpace(parts[1])
+		}
+	}
+	return prefix, suffix, scanner.Err()
+}
+
+// directResult is a row of direct output: the timing of one request, to the
+// proxy or an object server.
+type directResult struct {
+	Target         string  `json:"target"`
+	Status         int     `json:"status"`
+	Error          string  `json:"error,omitempty"`
+	HeadersElapsed float64 `json:"headers_elapsed_seconds"`
+	Elapsed        float64 `json:"elapsed_seconds"`
+	Bytes          int64   `json:"bytes"`
+	ETag           string  `json:"etag"`
+	Timestamp      string  `json:"timestamp"`
+}
+
+// timeDirect times the request, reading any body to the end.
+func timeDirect(target string, send func() (*http.Response, error)) *directResult {
+	result := &directResult{Target: target}
+	start := time.Now()
+	resp, err := send()
+	result.HeadersElapsed = float64(time.Since(start)) / float64(time.Second)
+	if err != nil {
+		result.Error = err.Error()
+		result.Elapsed = result.HeadersElapsed
+		return result
+	}
+	result.Bytes, err = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	result.Elapsed = float64(time.Since(start)) / float64(time.Second)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Status = resp.StatusCode
+	result.ETag = strings.Trim(resp.Header.Get("Etag"), `"`)
+	result.Timestamp = resp.Header.Get("X-Backend-Timestamp")
+	if result.Timestamp == "" {
+		result.Timestamp = resp.Header.Get("X-Timestamp")
+	}
+	return result
+}
+
+func (cli *CLIInstance) direct(c Client, args []string) {
+	if err := cli.DirectFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, object := parsePath(cli.DirectFlags.Args())
+	if object == "" {
+		cli.fatalf(cli, "direct requires <container> <object>.\n")
+	}
+	if *cli.directFlagRing == "" && len(cli.directFlagServer) == 0 {
+		cli.fatalf(cli, "direct requires -ring or -server.\n")
+	}
+	account := *cli.directFlagAccount
+	if account == "" {
+		surl := strings.TrimSuffix(c.GetURL(), "/")
+		account = surl[strings.LastIndex(surl, "/")+1:]
+	}
+	prefix, suffix := *cli.directFlagHashPrefix, *cli.directFlagHashSuffix
+	if prefix == "" && suffix == "" && *cli.directFlagConf != "" {
+		var err error
+		if prefix, suffix, err = readHashPathConf(*cli.directFlagConf); err != nil {
+			cli.fatal(cli, err)
+		}
+	}
+	var servers []string
+	partition := *cli.directFlagPartition
+	if *cli.directFlagRing != "" {
+		ring, err := loadObjectRing(*cli.directFlagRing)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		if partition < 0 {
+			partition = int64(objectPartition(ring.partShift, prefix, suffix, account, container, object))
+		}
+		for _, dev := range ring.primaries(uint32(partition)) {
+			servers = append(servers, "http://"+net.JoinHostPort(dev.IP, strconv.Itoa(dev.Port))+"/"+dev.Device)
+		}
+	}
+	if partition < 0 {
+		if *cli.directFlagPartPower < 1 || *cli.directFlagPartPower > 32 {
+			cli.fatalf(cli, "direct -server requires -partition, -part-power, or -ring to find the partition.\n")
+		}
+		partition = int64(objectPartition(uint(32-*cli.directFlagPartPower), prefix, suffix, account, container, object))
+	}
+	for _, server := range cli.directFlagServer {
+		servers = append(servers, strings.TrimSuffix(server, "/"))
+	}
+	method := "GET"
+	if *cli.directFlagHead {
+		method = "HEAD"
+	}
+	cli.verbosef(cli, "Partition %d of /%s/%s/%s.\n", partition, account, container, object)
+	// The requests are made one at a time so their timings do not affect each
+	// other.
+	results := []*directResult{timeDirect("proxy", func() (*http.Response, error) {
+		var resp *http.Response
+		if method == "HEAD" {
+			resp = c.HeadObject(container, object, cli.globalFlagHeaders.Headers())
+		} else {
+			resp = c.GetObject(container, object, cli.globalFlagHeaders.Headers())
+		}
+		cli.verboseTransID(resp)
+		return resp, nil
+	})}
+	backend := &http.Client{Timeout: time.Minute}
+	path := fmt.Sprintf("/%d/%s/%s/%s", partition, nectarutil.EscapeObjectPath(account), nectarutil.EscapeObjectPath(container), nectarutil.EscapeObjectPath(object))
+	var failures int
+	for _, server := range servers {
+		result := timeDirect(server, func() (*http.Response, error) {
+			req, err := http.NewRequestWithContext(cli.ctx, method, server+path, nil)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range cli.globalFlagHeaders.Headers() {
+				req.Header.Set(k, v)
+			}
+			if *cli.directFlagPolicyIndex != "" {
+				req.Header.Set("X-Backend-Storage-Policy-Index", *cli.directFlagPolicyIndex)
+			}
+			return backend.Do(req)
+		})
+		if result.Error != "" {
+			failures++
+		}
+		results = append(results, result)
+	}
+	if *cli.globalFlagJSON {
+		cli.printJSON(results)
+	} else {
+		data := [][]string{{"Target", "Status", "Headers", "Total", "Bytes", "ETag", "Timestamp"}}
+		for _, result := range results {
+			status := fmt.Sprintf("%d %s", result.Status, http.StatusText(result.Status))
+			if result.Error != "" {
+				status = result.Error
+			}
+			data = append(data, []string{result.Target, status, fmt.Sprintf("%.05fs", result.HeadersElapsed), fmt.Sprintf("%.05fs", result.Elapsed), fmt.Sprintf("%d", result.Bytes), result.ETag, result.Timestamp})
+		}
+		cli.table(data, nil)
+	}
+	if failures > 0 {
+		cli.fatalf(cli, "%d of %d object servers could not be reached.\n", failures, len(servers))
+	}
+}
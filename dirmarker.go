@@ -0,0 +1,43 @@
+package nectar
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// dirMarkerName is the file written by the init command recording the default
+// container and object name prefix for the directory it is in.
+const dirMarkerName = ".nectar"
+
+// dirMarker is the content of a .nectar file, kept as JSON.
+type dirMarker struct {
+	Container string `json:"container"`
+	Prefix    string `json:"prefix,omitempty"`
+}
+
+// readDirMarker returns the .nectar file in the current directory, or nil if
+// there is none.
+func readDirMarker() (*dirMarker, error) {
+	b, err := ioutil.ReadFile(dirMarkerName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m dirMarker
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// writeDirMarker writes the .nectar file in the current directory.
+func writeDirMarker(m *dirMarker) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dirMarkerName, append(b, '\n'), 0644)
+}
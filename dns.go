@@ -0,0 +1,157 @@
+package nectar
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithDNSRoundRobin spreads new connections across all the addresses the
+// host of an endpoint resolves to, in turn, rather than whichever the system
+// resolver happens to give first, and resolves the host again once the
+// addresses are older than refresh. Should the addresses change, idle
+// connections are closed so that later requests dial the new ones. Without
+// this, long running benches and transfers can pin every connection to the
+// single address resolved when they started. Should a dial fail, the host's
+// other addresses are tried in turn.
+func WithDNSRoundRobin(refresh time.Duration) ClientOption {
+	return func(c *userClient) {
+		if ht := c.transport(); ht != nil {
+			d := &roundRobinDialer{
+				dial:     transportDial(ht),
+				resolver: net.DefaultResolver,
+				refresh:  refresh,
+				hosts:    map[string]*resolvedHost{},
+				changed:  ht.CloseIdleConnections,
+			}
+			ht.DialContext = d.DialContext
+		}
+	}
+}
+
+// WithIPVersion has connections made only over IPv4, for a version of 4, or
+// IPv6, for 6, rather than over whichever connects first of the addresses an
+// endpoint's host resolves to, as is otherwise done; for debugging dual-stack
+// clusters where one family's routes behave differently. The address of each
+// request's connection is given by its RequestInfo.RemoteAddr.
+func WithIPVersion(version int) ClientOption {
+	return func(c *userClient) {
+		network := "tcp4"
+		if version == 6 {
+			network = "tcp6"
+		}
+		if ht := c.transport(); ht != nil {
+			dial := transportDial(ht)
+			ht.DialContext = func(ctx context.Context, _ string, address string) (net.Conn, error) {
+				return dial(ctx, network, address)
+			}
+		}
+	}
+}
+
+// transportDial returns the transport's DialContext, or that of a dialer
+// like the one http.DefaultTransport uses if it has none.
+func transportDial(ht *http.Transport) func(ctx context.Context, network string, address string) (net.Conn, error) {
+	if ht.DialContext != nil {
+		return ht.DialContext
+	}
+	return (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+}
+
+// resolvedHost is the addresses a host last resolved to.
+type resolvedHost struct {
+	addrs    []string
+	resolved time.Time
+	next     int
+}
+
+// roundRobinDialer is the Transport.DialContext for WithDNSRoundRobin.
+type roundRobinDialer struct {
+	dial     func(ctx context.Context, network string, address string) (net.Conn, error)
+	resolver *net.Resolver
+	refresh  time.Duration
+	// changed is called when a host resolves to different addresses.
+	changed func()
+	lock    sync.Mutex
+	hosts   map[string]*resolvedHost
+}
+
+// lookup returns the addresses for the host, in the order they should be
+// tried for the next connection.
+func (d *roundRobinDialer) lookup(ctx context.Context, network string, host string) ([]string, error) {
+	d.lock.Lock()
+	rh := d.hosts[host]
+	stale := rh == nil || time.Since(rh.resolved) >= d.refresh
+	d.lock.Unlock()
+	if stale {
+		ipaddrs, err := d.resolver.LookupIPAddr(ctx, host)
+		var addrs []string
+		for _, ipaddr := range ipaddrs {
+			addrs = append(addrs, ipaddr.String())
+		}
+		sort.Strings(addrs)
+		d.lock.Lock()
+		if err == nil && len(addrs) > 0 {
+			if rh == nil {
+				rh = &resolvedHost{}
+				d.hosts[host] = rh
+			} else if strings.Join(rh.addrs, " ") != strings.Join(addrs, " ") && d.changed != nil {
+				go d.changed()
+			}
+			rh.addrs = addrs
+			rh.resolved = time.Now()
+		} else if rh == nil {
+			d.lock.Unlock()
+			if err == nil {
+				err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			}
+			return nil, err
+		}
+		// If the host cannot be resolved again, the addresses it last
+		// resolved to are kept for now.
+		d.lock.Unlock()
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var addrs []string
+	for i := range rh.addrs {
+		addr := rh.addrs[(rh.next+i)%len(rh.addrs)]
+		ip := net.ParseIP(addr)
+		if (strings.HasSuffix(network, "4") && ip.To4() == nil) || (strings.HasSuffix(network, "6") && ip.To4() != nil) {
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	rh.next++
+	return addrs, nil
+}
+
+// DialContext dials the next of the addresses the host of address resolves
+// to, trying the others in turn should that fail.
+func (d *roundRobinDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dial(ctx, network, address)
+	}
+	addrs, err := d.lookup(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	for _, addr := range addrs {
+		var conn net.Conn
+		if conn, err = d.dial(ctx, network, net.JoinHostPort(addr, port)); err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
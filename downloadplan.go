@@ -0,0 +1,118 @@
+package nectar
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// downloadTask is an object to download to destpath or, if object is "", a
+// container whose objects beginning with the prefix are to be downloaded
+// under destpath, with any trimPrefix removed from their names. The size is
+// as listed, or as HEADed for planned downloads, and 0 if not yet known.
+type downloadTask struct {
+	container  string
+	object     string
+	prefix     string
+	trimPrefix string
+	destpath   string
+	size       int64
+}
+
+// objectPath returns the path under the container task's destpath for the
+// object.
+func (task *downloadTask) objectPath(object string) string {
+	return filepath.Join(task.destpath, filepath.FromSlash(strings.TrimPrefix(object, task.trimPrefix)))
+}
+
+// planDownload expands any container tasks into their objects and HEADs every
+// object concurrently, returning the object tasks along with their total size.
+// Objects that cannot be HEADed are reported and left out of the plan, or are
+// fatal without -continue-on-error. Objects are HEADed rather than sized from
+// the listings as the listed size of a large object is that of its manifest.
+func (cli *CLIInstance) planDownload(c Client, concurrency int, tasks []*downloadTask) ([]*downloadTask, int64) {
+	var objects []*downloadTask
+	for _, task := range tasks {
+		if task.object != "" {
+			objects = append(objects, task)
+			continue
+		}
+		if err := cli.eachObject(c, task.container, task.prefix, func(entry *ObjectRecord) bool {
+			if cli.downloadMatch(entry) {
+				objects = append(objects, &downloadTask{container: task.container, object: entry.Name, destpath: task.objectPath(entry.Name)})
+			}
+			return true
+		}); err != nil {
+			if !*cli.globalFlagContinueOnError {
+				cli.fatal(cli, err)
+			}
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	sizes := make([]int64, len(objects))
+	found := make([]bool, len(objects))
+	indexes := make(chan int, concurrency)
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for i := range indexes {
+				exists, info, err := ObjectExists(c, objects[i].container, objects[i].object, cli.globalFlagHeaders.Headers())
+				if err == nil && !exists {
+					err = fmt.Errorf("HEAD /%s/%s - 404 Not Found", objects[i].container, objects[i].object)
+				}
+				if err != nil {
+					if !*cli.globalFlagContinueOnError {
+						cli.fatal(cli, err)
+					}
+					fmt.Fprintln(os.Stderr, err)
+					continue
+				}
+				sizes[i] = info.ContentLength
+				found[i] = true
+			}
+			wg.Done()
+		}()
+	}
+	for i := range objects {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	var planned []*downloadTask
+	var total int64
+	for i, task := range objects {
+		if found[i] {
+			task.size = sizes[i]
+			planned = append(planned, task)
+			total += sizes[i]
+		}
+	}
+	return planned, total
+}
+
+// confirm asks the question and returns true only if the answer read from
+// stdin begins with y.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y")
+}
+
+// humanBytes formats n using the largest binary unit that keeps it at least 1.
+func humanBytes(n int64) string {
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+	if n < 1024 {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	v := float64(n) / 1024
+	unit := 0
+	for v >= 1024 && unit < len(units)-1 {
+		v /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.02f %s", v, units[unit])
+}
@@ -0,0 +1,173 @@
+package nectar
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// containerUsage is a row of du output, from a container HEAD.
+type containerUsage struct {
+	Name    string `json:"name"`
+	Objects int64  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// headContainers HEADs the containers, -C at a time, returning their
+// information in the same order, nil for any that failed, and the number that
+// failed. Failures are fatal unless -continue-on-error is given.
+func (cli *CLIInstance) headContainers(c Client, containers []string) ([]*ContainerInfo, int) {
+	concurrency := *cli.globalFlagConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	infos := make([]*ContainerInfo, len(containers))
+	indexes := make(chan int, len(containers))
+	for i := range containers {
+		indexes <- i
+	}
+	close(indexes)
+	var lock sync.Mutex
+	var failures int
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				// A container deleted since being listed, or never there
+				// if named, is a 404 error.
+				info, err := HeadContainerInfo(c, containers[i], cli.globalFlagHeaders.Headers())
+				if err != nil {
+					if !*cli.globalFlagContinueOnError {
+						cli.fatal(cli, err)
+					}
+					fmt.Fprintln(os.Stderr, err)
+					lock.Lock()
+					failures++
+					lock.Unlock()
+					continue
+				}
+				infos[i] = info
+			}
+		}()
+	}
+	wg.Wait()
+	return infos, failures
+}
+
+func (cli *CLIInstance) du(c Client, args []string) {
+	if err := cli.DuFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	containers := cli.DuFlags.Args()
+	if *cli.duFlagAll {
+		if len(containers) > 0 {
+			cli.fatalf(cli, "du -a does not take containers.\n")
+		}
+		if err := cli.eachContainer(c, "", func(entry *ContainerRecord) bool {
+			containers = append(containers, entry.Name)
+			return true
+		}); err != nil {
+			cli.fatal(cli, err)
+		}
+	} else if len(containers) == 0 {
+		cli.fatalf(cli, "du requires <container> ... or -a for every container in the account.\n")
+	}
+	switch *cli.duFlagSort {
+	case "bytes", "objects", "name":
+	default:
+		cli.fatalf(cli, "Unknown -sort: %s\n", *cli.duFlagSort)
+	}
+	infos, failures := cli.headContainers(c, containers)
+	rows := []*containerUsage{}
+	total := &containerUsage{Name: "Total"}
+	for i, info := range infos {
+		if info != nil {
+			rows = append(rows, &containerUsage{Name: containers[i], Objects: info.ObjectCount, Bytes: info.BytesUsed})
+			total.Objects += info.ObjectCount
+			total.Bytes += info.BytesUsed
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		switch *cli.duFlagSort {
+		case "bytes":
+			return rows[i].Bytes > rows[j].Bytes
+		case "objects":
+			return rows[i].Objects > rows[j].Objects
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	if *cli.globalFlagJSON {
+		cli.printJSON(map[string]interface{}{"containers": rows, "total": total})
+	} else {
+		data := [][]string{{"Container", "Objects", "Bytes", "Size"}}
+		for _, u := range append(rows, total) {
+			data = append(data, []string{u.Name, fmt.Sprintf("%d", u.Objects), fmt.Sprintf("%d", u.Bytes), humanBytes(u.Bytes)})
+		}
+		cli.table(data, nil)
+	}
+	if failures > 0 {
+		cli.fatalf(cli, "%d containers could not be HEADed and are not in the totals.\n", failures)
+	}
+}
+
+// policyUsage is a row of head -policies output.
+type policyUsage struct {
+	Policy     string `json:"policy"`
+	Containers int64  `json:"containers"`
+	Objects    int64  `json:"objects"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// headPolicies outputs the account's usage broken down by storage policy,
+// found by listing the containers and HEADing each.
+func (cli *CLIInstance) headPolicies(c Client) {
+	var containers []string
+	if err := cli.eachContainer(c, "", func(entry *ContainerRecord) bool {
+		containers = append(containers, entry.Name)
+		return true
+	}); err != nil {
+		cli.fatal(cli, err)
+	}
+	infos, failures := cli.headContainers(c, containers)
+	byPolicy := map[string]*policyUsage{}
+	rows := []*policyUsage{}
+	total := &policyUsage{Policy: "Total"}
+	for _, info := range infos {
+		if info == nil {
+			continue
+		}
+		policy := info.StoragePolicy
+		if policy == "" {
+			policy = "(unknown)"
+		}
+		u := byPolicy[policy]
+		if u == nil {
+			u = &policyUsage{Policy: policy}
+			byPolicy[policy] = u
+			rows = append(rows, u)
+		}
+		for _, u := range []*policyUsage{u, total} {
+			u.Containers++
+			u.Objects += info.ObjectCount
+			u.Bytes += info.BytesUsed
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Policy < rows[j].Policy
+	})
+	if *cli.globalFlagJSON {
+		cli.printJSON(map[string]interface{}{"policies": rows, "total": total})
+	} else {
+		data := [][]string{{"Policy", "Containers", "Objects", "Bytes", "Size"}}
+		for _, u := range append(rows, total) {
+			data = append(data, []string{u.Policy, fmt.Sprintf("%d", u.Containers), fmt.Sprintf("%d", u.Objects), fmt.Sprintf("%d", u.Bytes), humanBytes(u.Bytes)})
+		}
+		cli.table(data, nil)
+	}
+	if failures > 0 {
+		cli.fatalf(cli, "%d containers could not be HEADed and are not in the totals.\n", failures)
+	}
+}
@@ -0,0 +1,145 @@
+package nectar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNotModified and ErrPreconditionFailed match, with errors.Is, the
+// *ResponseError for a 304 Not Modified or a 412 Precondition Failed, such as
+// GetObjectIf and PutObjectIf return when their Conditions are not met.
+var (
+	ErrNotModified        = errors.New("not modified")
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// responseErrorBodyMax is the most of a response body kept by a
+// ResponseError.
+const responseErrorBodyMax = 1024
+
+// ResponseError describes an unsuccessful response, including the
+// transaction ID operators need to find the request in the server logs.
+// Library users can get one for any response with CheckResponse rather than
+// examining the response themselves, and find it in a wrapped error with
+// errors.As.
+type ResponseError struct {
+	StatusCode int
+	Method     string
+	// Path is the request path after the account, or the full URL path for
+	// requests outside the account, such as auth requests.
+	Path    string
+	TransID string
+	// Body is the start of the response body, with "..." appended if it was
+	// truncated.
+	Body string
+}
+
+// Error is another name for ResponseError, as nectar.Error.
+type Error = ResponseError
+
+// NewResponseError returns a ResponseError describing the response, reading
+// an excerpt of its body and then closing it.
+func NewResponseError(resp *http.Response) *ResponseError {
+	return newResponseError(resp, false)
+}
+
+// CheckResponse returns the response as given along with a *ResponseError if
+// its status is not 2xx, or a nil error otherwise, so a Client call can be
+// checked as with:
+//
+//	resp, err := nectar.CheckResponse(c.HeadObject(container, object, nil))
+//
+// Unlike with NewResponseError, the response remains as usable as before: the
+// excerpt of the body read for the error is put back ahead of the rest, and
+// the body must still be closed.
+func CheckResponse(resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	return resp, newResponseError(resp, true)
+}
+
+// newResponseError is NewResponseError, but with keepBody it leaves the
+// response body open and readable from its start.
+func newResponseError(resp *http.Response, keepBody bool) *ResponseError {
+	e := &ResponseError{StatusCode: resp.StatusCode, TransID: resp.Header.Get("X-Trans-Id")}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		path := resp.Request.URL.RequestURI()
+		if target, ok := resp.Request.Context().Value(requestTargetKey{}).(*requestTarget); ok {
+			path = target.path
+		}
+		query := ""
+		if i := strings.Index(path, "?"); i >= 0 {
+			// Listing requests send every parameter; only those set are of
+			// interest.
+			if values, err := url.ParseQuery(path[i+1:]); err == nil {
+				for k, v := range values {
+					if len(v) == 0 || v[0] == "" {
+						delete(values, k)
+					}
+				}
+				if len(values) > 0 {
+					query = "?" + values.Encode()
+				}
+			} else {
+				query = path[i:]
+			}
+			path = path[:i]
+		}
+		if p, err := url.PathUnescape(path); err == nil {
+			path = p
+		}
+		e.Path = path + query
+	}
+	if resp.Body != nil {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, responseErrorBodyMax+1))
+		if keepBody {
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(b), resp.Body), resp.Body}
+		} else {
+			resp.Body.Close()
+		}
+		if len(b) > responseErrorBodyMax {
+			e.Body = string(b[:responseErrorBodyMax]) + "..."
+		} else {
+			e.Body = string(b)
+		}
+		e.Body = strings.TrimSpace(e.Body)
+	}
+	return e
+}
+
+// Is reports whether the error is for the status ErrNotModified or
+// ErrPreconditionFailed stands for, for errors.Is.
+func (e *ResponseError) Is(target error) bool {
+	switch target {
+	case ErrNotModified:
+		return e.StatusCode == http.StatusNotModified
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	}
+	return false
+}
+
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Method != "" {
+		msg = e.Method + " " + e.Path + " - " + msg
+	}
+	if e.TransID != "" {
+		msg += " - " + e.TransID
+	}
+	if e.Body != "" {
+		msg += " - " + e.Body
+	}
+	return msg
+}
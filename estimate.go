@@ -0,0 +1,164 @@
+package nectar
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultEstimateThroughput is the per-connection throughput assumed by
+// -estimate unless -estimate-throughput is given.
+const defaultEstimateThroughput = 10 << 20
+
+// transferEstimate is the result of -estimate: the objects, bytes, and
+// requests an upload or download would involve, found with listings and
+// stats only. The expected duration assumes each request costs the latency
+// measured for the requests made while estimating, plus its bytes at the
+// per-connection throughput, with concurrency requests in flight at once.
+type transferEstimate struct {
+	verb       string
+	objects    int64
+	bytes      int64
+	requests   int64
+	measured   int64
+	latencySum time.Duration
+}
+
+// timed records a request made while estimating that took elapsed.
+func (e *transferEstimate) timed(elapsed time.Duration) {
+	e.measured++
+	e.latencySum += elapsed
+}
+
+// report outputs the estimate for the concurrency and per-connection
+// throughput, in bytes per second.
+func (e *transferEstimate) report(concurrency int, throughput int64) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if throughput < 1 {
+		throughput = defaultEstimateThroughput
+	}
+	var latency time.Duration
+	if e.measured > 0 {
+		latency = e.latencySum / time.Duration(e.measured)
+	}
+	expected := (time.Duration(e.requests)*latency + time.Duration(float64(e.bytes)/float64(throughput)*float64(time.Second))) / time.Duration(concurrency)
+	if expected < time.Second {
+		expected = expected.Round(time.Millisecond)
+	} else {
+		expected = expected.Round(time.Second)
+	}
+	fmt.Printf("Would %s %d objects, %s, in %d requests.\n", e.verb, e.objects, humanBytes(e.bytes), e.requests)
+	fmt.Printf("Expected to take about %s at %d concurrency, assuming %s per request as measured and %s/s per connection.\n", expected, concurrency, latency.Round(time.Microsecond), humanBytes(throughput))
+}
+
+// estimateUpload reports the -estimate for uploading sourcepath to the
+// container, only stat-ing the local files and HEADing the container.
+func (cli *CLIInstance) estimateUpload(c Client, sourcepath string, container string) {
+	e := &transferEstimate{verb: "upload"}
+	start := time.Now()
+	exists, _, err := ContainerExists(c, container, cli.globalFlagHeaders.Headers())
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	e.timed(time.Since(start))
+	if !exists {
+		e.requests++
+	}
+	if cli.uploadFlagSegmentSize > 0 {
+		exists, _, err := ContainerExists(c, cli.uploadSegmentContainer(container), cli.globalFlagHeaders.Headers())
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		if !exists {
+			e.requests++
+		}
+	}
+	add := func(size int64) {
+		e.objects++
+		e.bytes += size
+		if cli.uploadFlagSegmentSize > 0 && size > int64(cli.uploadFlagSegmentSize) {
+			// The segments plus the manifest.
+			e.requests += (size+int64(cli.uploadFlagSegmentSize)-1)/int64(cli.uploadFlagSegmentSize) + 1
+		} else {
+			e.requests++
+		}
+	}
+	fi, err := os.Stat(sourcepath)
+	if err != nil {
+		cli.fatalf(cli, "Could not stat %s: %s\n", sourcepath, err)
+	}
+	if fi.Mode().IsRegular() {
+		add(fi.Size())
+	} else {
+		if !strings.HasSuffix(sourcepath, string(os.PathSeparator)) {
+			sourcepath += string(os.PathSeparator)
+		}
+		walk := newUploadWalk(sourcepath, *cli.uploadFlagWalkers, *cli.uploadFlagQueue, *cli.uploadFlagPreserve, nil)
+		for path := range walk.queue {
+			if *cli.uploadFlagMetaSidecar != "" && strings.HasSuffix(path, *cli.uploadFlagMetaSidecar) {
+				continue
+			}
+			if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				add(0)
+			} else if fi, err := os.Stat(path); err == nil {
+				add(fi.Size())
+			}
+		}
+	}
+	e.report(*cli.globalFlagConcurrency, int64(cli.uploadFlagEstimateThroughput))
+}
+
+// estimateDownload reports the -estimate for the download tasks, listing the
+// containers and HEADing only the objects named explicitly. Listed objects
+// are sized as listed, so a large object is sized by its manifest.
+func (cli *CLIInstance) estimateDownload(c Client, concurrency int, tasks []*downloadTask) {
+	e := &transferEstimate{verb: "download"}
+	add := func(size int64) {
+		e.objects++
+		e.bytes += size
+		e.requests++
+		if *cli.downloadFlagParts > 1 && size >= int64(*cli.downloadFlagParts)*downloadPartMinSize {
+			e.requests += int64(*cli.downloadFlagParts) - 1
+		}
+	}
+	for _, task := range tasks {
+		if task.object != "" {
+			start := time.Now()
+			exists, info, err := ObjectExists(c, task.container, task.object, cli.globalFlagHeaders.Headers())
+			if err == nil && !exists {
+				err = fmt.Errorf("HEAD /%s/%s - 404 Not Found", task.container, task.object)
+			}
+			if err != nil {
+				cli.fatal(cli, err)
+			}
+			e.timed(time.Since(start))
+			add(info.ContentLength)
+			continue
+		}
+		marker := ""
+		for {
+			start := time.Now()
+			entries, resp := c.GetContainer(task.container, marker, "", 0, task.prefix, "", false, cli.globalFlagHeaders.Headers())
+			cli.verboseTransID(resp)
+			if resp.StatusCode/100 != 2 {
+				cli.fatal(cli, NewResponseError(resp))
+			}
+			resp.Body.Close()
+			e.timed(time.Since(start))
+			e.requests++
+			if len(entries) == 0 {
+				break
+			}
+			for _, entry := range entries {
+				if entry.Name != "" && cli.downloadMatch(entry) {
+					add(int64(entry.Bytes))
+				}
+			}
+			marker = entries[len(entries)-1].Name
+		}
+	}
+	e.report(concurrency, int64(cli.downloadFlagEstimateThroughput))
+}
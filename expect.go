@@ -0,0 +1,66 @@
+package nectar
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// expectResponse checks the response against any -expect-status and
+// -expect-header given, exiting with the first mismatch described. It returns
+// true if the response is to be treated as a success: it was 2xx with no
+// -expect-status given, or its status was as expected.
+func (cli *CLIInstance) expectResponse(resp *http.Response) bool {
+	if cli.expectFlagStatus != "" {
+		matched, err := statusMatches(cli.expectFlagStatus, resp.StatusCode)
+		if err != nil {
+			resp.Body.Close()
+			cli.fatal(cli, err)
+		}
+		if !matched {
+			cli.fatalf(cli, "Expected status %s, got %s\n", cli.expectFlagStatus, NewResponseError(resp))
+		}
+	} else if resp.StatusCode/100 != 2 {
+		return false
+	}
+	for _, expect := range cli.expectFlagHeaders {
+		name, value := expect, ""
+		hasValue := false
+		if i := strings.Index(expect, "="); i >= 0 {
+			name, value, hasValue = strings.TrimSpace(expect[:i]), expect[i+1:], true
+		}
+		values, ok := resp.Header[http.CanonicalHeaderKey(name)]
+		if !ok || len(values) == 0 {
+			resp.Body.Close()
+			cli.fatalf(cli, "Expected header %s, but it was not in the %d %s response\n", name, resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+		if hasValue && values[0] != value {
+			resp.Body.Close()
+			cli.fatalf(cli, "Expected header %s to be %q, got %q\n", name, value, values[0])
+		}
+	}
+	return true
+}
+
+// statusMatches returns true if the status code is one of the comma
+// separated codes, each such as 204 or a class such as 2xx.
+func statusMatches(codes string, status int) (bool, error) {
+	for _, code := range strings.Split(codes, ",") {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if len(code) == 3 && strings.HasSuffix(code, "xx") && code[0] >= '1' && code[0] <= '5' {
+			if status/100 == int(code[0]-'0') {
+				return true, nil
+			}
+			continue
+		}
+		n, err := strconv.Atoi(code)
+		if err != nil {
+			return false, fmt.Errorf("Invalid -expect-status %q; give status codes such as 204 or classes such as 2xx", codes)
+		}
+		if n == status {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,81 @@
+package nectar
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// interruption lets a bench or bulk transfer stop cleanly on SIGINT or
+// SIGTERM, or once any -max-duration or -deadline passes: the feeder stops
+// handing out work, the requests in flight are drained, and the CSVs and
+// summary are written for the work completed. Once interrupted, the signals revert to
+// their default handling so a second one ends the process immediately. A nil
+// *interruption is valid and is never interrupted.
+type interruption struct {
+	signals chan os.Signal
+	ch      chan struct{}
+	stopped chan struct{}
+}
+
+// notifyInterrupt starts catching the signals, and watching for any
+// deadline; stop must be called once the interruptible work is over.
+func (cli *CLIInstance) notifyInterrupt() *interruption {
+	i := &interruption{signals: make(chan os.Signal, 1), ch: make(chan struct{}), stopped: make(chan struct{})}
+	signal.Notify(i.signals, os.Interrupt, syscall.SIGTERM)
+	var timer *time.Timer
+	var deadline <-chan time.Time
+	if !cli.deadline.IsZero() {
+		timer = time.NewTimer(time.Until(cli.deadline))
+		deadline = timer.C
+	}
+	go func() {
+		select {
+		case sig := <-i.signals:
+			signal.Stop(i.signals)
+			fmt.Fprintf(os.Stderr, "\nReceived %s; finishing the requests in flight, send again to exit immediately.\n", sig)
+			close(i.ch)
+		case <-deadline:
+			signal.Stop(i.signals)
+			fmt.Fprintf(os.Stderr, "\nReached -max-duration of %s; finishing the requests in flight, interrupt to exit immediately.\n", *cli.globalFlagMaxDuration)
+			close(i.ch)
+		case <-cli.ctx.Done():
+			signal.Stop(i.signals)
+			fmt.Fprintf(os.Stderr, "\nReached -deadline of %s; the requests in flight were cancelled.\n", *cli.globalFlagDeadline)
+			close(i.ch)
+		case <-i.stopped:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	return i
+}
+
+// done returns a channel that is closed once interrupted.
+func (i *interruption) done() <-chan struct{} {
+	if i == nil {
+		return nil
+	}
+	return i.ch
+}
+
+func (i *interruption) interrupted() bool {
+	select {
+	case <-i.done():
+		return true
+	default:
+		return false
+	}
+}
+
+// stop restores the default handling of the signals.
+func (i *interruption) stop() {
+	if i == nil {
+		return
+	}
+	signal.Stop(i.signals)
+	close(i.stopped)
+}
@@ -0,0 +1,81 @@
+package nectar
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+)
+
+// transferJournal records the items completed by an upload or download in a
+// state file, one per line, so that rerunning the same command with the same
+// state file can skip work already done. Each entry is appended with a single
+// write as soon as its item completes, so at most the item in flight is lost
+// by a crash. A nil *transferJournal is valid and records nothing.
+type transferJournal struct {
+	lock sync.Mutex
+	f    *os.File
+	done map[string]bool
+}
+
+func openTransferJournal(path string) (*transferJournal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	j := &transferJournal{f: f, done: map[string]bool{}}
+	r := bufio.NewReader(f)
+	partial := false
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			// A final line without a newline is from an interrupted write and
+			// is ignored, though it must be terminated before appending more.
+			partial = line != ""
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		j.done[line[:len(line)-1]] = true
+	}
+	if partial {
+		if _, err = f.Write([]byte{'\n'}); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// completed returns true if the key was recorded by an earlier run.
+func (j *transferJournal) completed(key string) bool {
+	if j == nil {
+		return false
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.done[key]
+}
+
+// complete records the key as done.
+func (j *transferJournal) complete(key string) error {
+	if j == nil {
+		return nil
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if _, err := j.f.Write([]byte(key + "\n")); err != nil {
+		return err
+	}
+	j.done[key] = true
+	return nil
+}
+
+func (j *transferJournal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.f.Close()
+}
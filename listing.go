@@ -0,0 +1,80 @@
+package nectar
+
+// EachContainer calls fn for each entry of the account listing beginning with
+// the prefix, following markers past the server's listing limit, typically
+// 10,000 entries, until the listing is exhausted or fn returns false. With a
+// delimiter, the entries standing for pseudo-hierarchy have only Subdir set,
+// as with GetAccount.
+func EachContainer(c Client, prefix string, delimiter string, headers map[string]string, fn func(entry *ContainerRecord) bool) error {
+	marker := ""
+	for {
+		entries, resp := c.GetAccount(marker, "", 0, prefix, delimiter, false, headers)
+		if resp.StatusCode/100 != 2 {
+			return NewResponseError(resp)
+		}
+		resp.Body.Close()
+		if len(entries) == 0 {
+			return nil
+		}
+		for _, entry := range entries {
+			if !fn(entry) {
+				return nil
+			}
+		}
+		last := entries[len(entries)-1]
+		if marker = last.Name; marker == "" {
+			marker = last.Subdir
+		}
+	}
+}
+
+// EachObject calls fn for each entry of the container listing beginning with
+// the prefix, following markers past the server's listing limit until the
+// listing is exhausted or fn returns false. With a delimiter, the entries
+// standing for pseudo-directories have only Subdir set, as with GetContainer.
+func EachObject(c Client, container string, prefix string, delimiter string, headers map[string]string, fn func(entry *ObjectRecord) bool) error {
+	marker := ""
+	for {
+		entries, resp := c.GetContainer(container, marker, "", 0, prefix, delimiter, false, headers)
+		if resp.StatusCode/100 != 2 {
+			return NewResponseError(resp)
+		}
+		resp.Body.Close()
+		if len(entries) == 0 {
+			return nil
+		}
+		for _, entry := range entries {
+			if !fn(entry) {
+				return nil
+			}
+		}
+		last := entries[len(entries)-1]
+		if marker = last.Name; marker == "" {
+			marker = last.Subdir
+		}
+	}
+}
+
+// GetAccountAll returns the entire account listing beginning with the prefix,
+// unlike GetAccount, which stops at the server's listing limit; see
+// EachContainer.
+func GetAccountAll(c Client, prefix string, delimiter string, headers map[string]string) ([]*ContainerRecord, error) {
+	var all []*ContainerRecord
+	err := EachContainer(c, prefix, delimiter, headers, func(entry *ContainerRecord) bool {
+		all = append(all, entry)
+		return true
+	})
+	return all, err
+}
+
+// GetContainerAll returns the entire container listing beginning with the
+// prefix, unlike GetContainer, which stops at the server's listing limit; see
+// EachObject.
+func GetContainerAll(c Client, container string, prefix string, delimiter string, headers map[string]string) ([]*ObjectRecord, error) {
+	var all []*ObjectRecord
+	err := EachObject(c, container, prefix, delimiter, headers, func(entry *ObjectRecord) bool {
+		all = append(all, entry)
+		return true
+	})
+	return all, err
+}
@@ -0,0 +1,250 @@
+package nectar
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// exportedObject is an entry in a listing written by export-listing and read
+// by import-check.
+type exportedObject struct {
+	Name         string `json:"name"`
+	Hash         string `json:"hash"`
+	Bytes        int64  `json:"bytes"`
+	LastModified string `json:"last_modified"`
+	ContentType  string `json:"content_type"`
+}
+
+// exportedColumns are the CSV columns of an exported listing, in order.
+var exportedColumns = []string{"name", "hash", "bytes", "last_modified", "content_type"}
+
+// listingFormat returns the format, json or csv, of the exported listing file:
+// the format given, or else csv for a .csv file and json otherwise.
+func listingFormat(format string, filename string) (string, error) {
+	switch format {
+	case "json", "csv":
+		return format, nil
+	case "":
+		if strings.HasSuffix(strings.ToLower(filename), ".csv") {
+			return "csv", nil
+		}
+		return "json", nil
+	}
+	return "", fmt.Errorf("Unknown listing format %q; use json or csv", format)
+}
+
+// importCheckReport is the outcome of an import-check: the names of the
+// objects listed in the file that no longer exist or whose ETag differs.
+type importCheckReport struct {
+	Checked int      `json:"checked"`
+	Missing []string `json:"missing"`
+	Changed []string `json:"changed"`
+}
+
+func (cli *CLIInstance) exportListing(c Client, args []string) {
+	if err := cli.ExportListingFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.ExportListingFlags.Args()
+	if len(args) != 2 {
+		cli.fatalf(cli, "export-listing requires <container> <file>.\n")
+	}
+	container, filename := args[0], args[1]
+	format, err := listingFormat(*cli.exportListingFlagFormat, filename)
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	var f *os.File
+	if filename == "-" {
+		f = os.Stdout
+	} else if f, err = os.Create(filename); err != nil {
+		cli.fatal(cli, err)
+	}
+	w := bufio.NewWriter(f)
+	// The entries are written as they are listed, so a container of any size
+	// can be exported.
+	count := 0
+	var add func(o *exportedObject) error
+	var finish func() error
+	if format == "csv" {
+		csvw := csv.NewWriter(w)
+		csvw.Write(exportedColumns)
+		add = func(o *exportedObject) error {
+			return csvw.Write([]string{o.Name, o.Hash, strconv.FormatInt(o.Bytes, 10), o.LastModified, o.ContentType})
+		}
+		finish = func() error {
+			csvw.Flush()
+			return csvw.Error()
+		}
+	} else {
+		enc := json.NewEncoder(w)
+		w.WriteString("[")
+		add = func(o *exportedObject) error {
+			if count > 0 {
+				w.WriteString(",")
+			}
+			return enc.Encode(o)
+		}
+		finish = func() error {
+			_, err := w.WriteString("]\n")
+			return err
+		}
+	}
+	var writeErr error
+	if err = cli.eachObject(c, container, *cli.exportListingFlagPrefix, func(entry *ObjectRecord) bool {
+		if writeErr = add(&exportedObject{Name: entry.Name, Hash: entry.Hash, Bytes: int64(entry.Bytes), LastModified: entry.LastModified, ContentType: entry.ContentType}); writeErr != nil {
+			return false
+		}
+		count++
+		return true
+	}); err != nil {
+		cli.fatal(cli, err)
+	}
+	if err = writeErr; err == nil {
+		err = finish()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if f != os.Stdout {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		cli.fatalf(cli, "Could not write %s: %s\n", filename, err)
+	}
+	cli.verbosef(cli, "Exported %d objects in %q to %s.\n", count, container, filename)
+}
+
+// readExportedListing reads the exported listing from r. A CSV file need only
+// have the name column, and the hash column if ETags are to be checked; the
+// columns are found by the header row.
+func readExportedListing(r io.Reader, format string) ([]*exportedObject, error) {
+	if format == "json" {
+		var objects []*exportedObject
+		err := json.NewDecoder(r).Decode(&objects)
+		return objects, err
+	}
+	csvr := csv.NewReader(r)
+	csvr.FieldsPerRecord = -1
+	header, err := csvr.Read()
+	if err != nil {
+		return nil, err
+	}
+	nameColumn, hashColumn := -1, -1
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(column)) {
+		case "name":
+			nameColumn = i
+		case "hash":
+			hashColumn = i
+		}
+	}
+	if nameColumn < 0 {
+		return nil, fmt.Errorf("no name column in the header row")
+	}
+	var objects []*exportedObject
+	for {
+		rec, err := csvr.Read()
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		o := &exportedObject{}
+		if nameColumn < len(rec) {
+			o.Name = rec[nameColumn]
+		}
+		if hashColumn >= 0 && hashColumn < len(rec) {
+			o.Hash = rec[hashColumn]
+		}
+		objects = append(objects, o)
+	}
+}
+
+func (cli *CLIInstance) importCheck(c Client, args []string) {
+	if err := cli.ImportCheckFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.ImportCheckFlags.Args()
+	if len(args) != 2 {
+		cli.fatalf(cli, "import-check requires <container> <file>.\n")
+	}
+	container, filename := args[0], args[1]
+	format, err := listingFormat(*cli.importCheckFlagFormat, filename)
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	var f *os.File
+	if filename == "-" {
+		f = os.Stdin
+	} else if f, err = os.Open(filename); err != nil {
+		cli.fatal(cli, err)
+	}
+	objects, err := readExportedListing(bufio.NewReader(f), format)
+	f.Close()
+	if err != nil {
+		cli.fatalf(cli, "Could not parse %s: %s\n", filename, err)
+	}
+	// The container is listed, rather than each object HEADed, so only the
+	// part of the container the names share a prefix with is scanned.
+	wanted := make(map[string]*exportedObject, len(objects))
+	prefix := ""
+	for i, o := range objects {
+		wanted[o.Name] = o
+		if i == 0 {
+			prefix = o.Name
+		}
+		for !strings.HasPrefix(o.Name, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	report := &importCheckReport{Checked: len(wanted), Missing: []string{}, Changed: []string{}}
+	if len(wanted) > 0 {
+		found := map[string]bool{}
+		if err = cli.eachObject(c, container, prefix, func(entry *ObjectRecord) bool {
+			if o := wanted[entry.Name]; o != nil {
+				found[entry.Name] = true
+				if o.Hash != "" && !strings.EqualFold(strings.Trim(o.Hash, `"`), entry.Hash) {
+					report.Changed = append(report.Changed, entry.Name)
+				}
+			}
+			return true
+		}); err != nil {
+			cli.fatal(cli, err)
+		}
+		for _, o := range objects {
+			if !found[o.Name] {
+				report.Missing = append(report.Missing, o.Name)
+				// Any duplicates in the file are reported once.
+				found[o.Name] = true
+			}
+		}
+	}
+	if *cli.globalFlagJSON {
+		cli.printJSON(report)
+	} else {
+		for _, name := range report.Missing {
+			fmt.Println("missing", name)
+		}
+		for _, name := range report.Changed {
+			fmt.Println("changed", name)
+		}
+	}
+	if len(report.Missing) > 0 || len(report.Changed) > 0 {
+		cli.fatalf(cli, "%d checked in %q: %d missing and %d changed.\n", report.Checked, container, len(report.Missing), len(report.Changed))
+	}
+	cli.verbosef(cli, "%d checked in %q: %d missing and %d changed.\n", report.Checked, container, len(report.Missing), len(report.Changed))
+}
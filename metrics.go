@@ -0,0 +1,120 @@
+package nectar
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request latency
+// histogram buckets.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// requestMetrics collects request counts, errors, bytes transferred, and
+// latencies by method from RequestInfo observations, serving them in the
+// Prometheus text exposition format.
+type requestMetrics struct {
+	lock     sync.Mutex
+	requests map[string]map[int]uint64
+	errors   map[string]uint64
+	sent     map[string]int64
+	received map[string]int64
+	latency  map[string]*latencyHistogram
+}
+
+type latencyHistogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newRequestMetrics() *requestMetrics {
+	return &requestMetrics{
+		requests: map[string]map[int]uint64{},
+		errors:   map[string]uint64{},
+		sent:     map[string]int64{},
+		received: map[string]int64{},
+		latency:  map[string]*latencyHistogram{},
+	}
+}
+
+// observe is for use with WithRequestObserver.
+func (m *requestMetrics) observe(info *RequestInfo) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	codes := m.requests[info.Method]
+	if codes == nil {
+		codes = map[int]uint64{}
+		m.requests[info.Method] = codes
+	}
+	codes[info.Status]++
+	if info.Err != nil || info.Status/100 == 5 {
+		m.errors[info.Method]++
+	}
+	m.sent[info.Method] += info.BytesSent
+	m.received[info.Method] += info.BytesReceived
+	h := m.latency[info.Method]
+	if h == nil {
+		h = &latencyHistogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latency[info.Method] = h
+	}
+	seconds := info.Elapsed.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (m *requestMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	var methods []string
+	for method := range m.latency {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	fmt.Fprintln(w, "# HELP nectar_requests_total Requests completed, by method and status code.")
+	fmt.Fprintln(w, "# TYPE nectar_requests_total counter")
+	for _, method := range methods {
+		var codes []int
+		for code := range m.requests[method] {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "nectar_requests_total{method=%q,code=\"%d\"} %d\n", method, code, m.requests[method][code])
+		}
+	}
+	fmt.Fprintln(w, "# HELP nectar_request_errors_total Requests failing with a transport error or 5xx status, by method.")
+	fmt.Fprintln(w, "# TYPE nectar_request_errors_total counter")
+	for _, method := range methods {
+		fmt.Fprintf(w, "nectar_request_errors_total{method=%q} %d\n", method, m.errors[method])
+	}
+	fmt.Fprintln(w, "# HELP nectar_sent_bytes_total Request body bytes sent, by method.")
+	fmt.Fprintln(w, "# TYPE nectar_sent_bytes_total counter")
+	for _, method := range methods {
+		fmt.Fprintf(w, "nectar_sent_bytes_total{method=%q} %d\n", method, m.sent[method])
+	}
+	fmt.Fprintln(w, "# HELP nectar_received_bytes_total Response body bytes received, by method.")
+	fmt.Fprintln(w, "# TYPE nectar_received_bytes_total counter")
+	for _, method := range methods {
+		fmt.Fprintf(w, "nectar_received_bytes_total{method=%q} %d\n", method, m.received[method])
+	}
+	fmt.Fprintln(w, "# HELP nectar_request_duration_seconds Request latency through the response body being closed, by method.")
+	fmt.Fprintln(w, "# TYPE nectar_request_duration_seconds histogram")
+	for _, method := range methods {
+		h := m.latency[method]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "nectar_request_duration_seconds_bucket{method=%q,le=%q} %d\n", method, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "nectar_request_duration_seconds_bucket{method=%q,le=\"+Inf\"} %d\n", method, h.count)
+		fmt.Fprintf(w, "nectar_request_duration_seconds_sum{method=%q} %g\n", method, h.sum)
+		fmt.Fprintf(w, "nectar_request_duration_seconds_count{method=%q} %d\n", method, h.count)
+	}
+}
@@ -3,6 +3,7 @@ package nectarutil
 
 import (
 	"bytes"
+	"crypto/rand"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -24,6 +25,18 @@ func Mkquery(parameters map[string]string) string {
 	return ""
 }
 
+// EscapeObjectPath escapes a container or object name for use within a URL
+// path. Each /-separated segment is escaped on its own so any slashes remain
+// path separators while characters such as space, #, ?, and % are escaped
+// rather than being taken as URL syntax.
+func EscapeObjectPath(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
 // ResponseStub returns a fake response with the given info.
 //
 // Note: The Request field of the returned response will be nil; you may want
@@ -73,3 +86,12 @@ func StubResponse(resp *http.Response) *http.Response {
 		Header:        header,
 	}
 }
+
+// UUID returns a random (version 4) UUID string.
+func UUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -4,6 +4,7 @@ package nectar
 import (
 	"io"
 	"net/http"
+	"time"
 )
 
 // Client is an API interface to CloudFiles.
@@ -30,15 +31,25 @@ type Client interface {
 	GetObject(container string, obj string, headers map[string]string) *http.Response
 	HeadObject(container string, obj string, headers map[string]string) *http.Response
 	DeleteObject(container string, obj string, headers map[string]string) *http.Response
+	// Raw sends the request to the urlAfterAccount as given, so any container
+	// and object names within it must already be escaped, such as with
+	// nectarutil.EscapeObjectPath.
 	Raw(method, urlAfterAccount string, headers map[string]string, body io.Reader) *http.Response
 	SetUserAgent(string)
 }
 
-// ContainerRecord is an entry in an account listing.
+// ContainerRecord is an entry in an account listing. LastModified and
+// StoragePolicy are only given by newer Swift|Hummingbird versions and will be
+// empty otherwise. When the listing was made with a delimiter, entries
+// standing for the pseudo-hierarchy of container names below a prefix have
+// only Subdir set.
 type ContainerRecord struct {
-	Count int64  `json:"count"`
-	Bytes int64  `json:"bytes"`
-	Name  string `json:"name"`
+	Count         int64  `json:"count"`
+	Bytes         int64  `json:"bytes"`
+	Name          string `json:"name"`
+	LastModified  string `json:"last_modified"`
+	StoragePolicy string `json:"storage_policy"`
+	Subdir        string `json:"subdir"`
 }
 
 // *ObjectRecord is an entry in a container listing.
@@ -51,9 +62,104 @@ type ObjectRecord struct {
 	Subdir       string `json:"subdir"`
 }
 
+// BulkDeleteResult is the outcome of a BulkDelete. Errors has the status, such
+// as "409 Conflict", of each path that could not be deleted, keyed by the path
+// as given; every other path was deleted or already did not exist.
+type BulkDeleteResult struct {
+	NumberDeleted  int
+	NumberNotFound int
+	Errors         map[string]string
+}
+
+// ExtractArchiveResult is the outcome of an ExtractArchive. Errors has the
+// status, such as "400 Bad Request", of each object that could not be
+// created, keyed by its container/object path.
+type ExtractArchiveResult struct {
+	NumberFilesCreated int
+	Errors             map[string]string
+}
+
+// Conditions are the preconditions of a conditional request; see GetObjectIf
+// and PutObjectIf. Fields left empty or zero are not sent. ETags may be given
+// with or without their quotes.
+type Conditions struct {
+	// IfMatch is the ETag the object must have, or * for it to merely exist.
+	IfMatch string
+	// IfNoneMatch is an ETag the object must not have, or * for it to not
+	// exist at all.
+	IfNoneMatch       string
+	IfModifiedSince   time.Time
+	IfUnmodifiedSince time.Time
+}
+
+// ContentRange is where the content of a response lies within the object:
+// bytes Start through End, inclusive, of an object of Size bytes. Size is -1
+// if the server did not say. For a 416 Requested Range Not Satisfiable,
+// Start and End are -1, so only Size is known.
+type ContentRange struct {
+	Start int64
+	End   int64
+	Size  int64
+}
+
+// AccountInfo is the information from an account HEAD. Metadata has the
+// X-Account-Meta- prefixes removed; Header has all the headers as given.
+type AccountInfo struct {
+	ContainerCount int64
+	ObjectCount    int64
+	BytesUsed      int64
+	Metadata       map[string]string
+	Header         http.Header
+}
+
+// ContainerInfo is the information from a container HEAD. Metadata has the
+// X-Container-Meta- prefixes removed; Header has all the headers as given.
+type ContainerInfo struct {
+	ObjectCount   int64
+	BytesUsed     int64
+	StoragePolicy string
+	Metadata      map[string]string
+	Header        http.Header
+}
+
+// ObjectInfo is the information from an object HEAD. ETag has any quotes
+// removed and LastModified will be the zero time if the header could not be
+// parsed. Metadata has the X-Object-Meta- prefixes removed; Header has all
+// the headers as given.
+type ObjectInfo struct {
+	ContentLength int64
+	ContentType   string
+	ETag          string
+	LastModified  time.Time
+	Metadata      map[string]string
+	Header        http.Header
+}
+
 // ClientToken is an extension to the Client interface allowing the retrieval
 // of the usually internal authentication token, usually for debugging
 // purposes.
 type ClientToken interface {
 	GetToken() string
 }
+
+// ClientEndpoints is an extension to the Client interface allowing the
+// retrieval of every service endpoint in use, rather than just the one given
+// by GetURL, along with the region they were chosen for.
+type ClientEndpoints interface {
+	GetURLs() []string
+	GetRegion() string
+}
+
+// ClientProject is an extension to the Client interface allowing a client
+// using Keystone auth to be re-scoped to a different project, for operators
+// working with many tenants.
+type ClientProject interface {
+	SwitchProject(project string) *http.Response
+}
+
+// ClientStats is an extension to the Client interface allowing the retrieval
+// of internal counters, such as the number of requests active, usually for
+// debugging purposes.
+type ClientStats interface {
+	Stats() map[string]int64
+}
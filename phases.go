@@ -0,0 +1,129 @@
+package nectar
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestPhases are how long the phases of a request's final attempt took;
+// see ResponsePhases. DNS, Connect, and TLS are 0 when a kept-alive connection
+// was reused.
+type RequestPhases struct {
+	// DNS is the time resolving the host name.
+	DNS time.Duration
+	// Connect is the time establishing the TCP connection.
+	Connect time.Duration
+	// TLS is the time of the TLS handshake.
+	TLS time.Duration
+	// RequestWrite is the time from having a connection to having written
+	// the request, including any body.
+	RequestWrite time.Duration
+	// TTFB is the time from having written the request to the first byte of
+	// the response; this is mostly the server's processing time.
+	TTFB time.Duration
+}
+
+type requestPhasesKey struct{}
+
+// phaseTrace records the RequestPhases of a request as it is traced; the
+// hooks may be called from the transport's goroutines, hence the lock.
+type phaseTrace struct {
+	lock         sync.Mutex
+	phases       RequestPhases
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+}
+
+// withPhaseTrace returns the context with a phaseTrace for ResponsePhases.
+func withPhaseTrace(ctx context.Context) context.Context {
+	t := &phaseTrace{}
+	since := func(start time.Time) time.Duration {
+		if start.IsZero() {
+			return 0
+		}
+		return time.Since(start)
+	}
+	ctx = context.WithValue(ctx, requestPhasesKey{}, t)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// A new attempt; forget any earlier one.
+			t.lock.Lock()
+			t.phases = RequestPhases{}
+			t.gotConn = time.Time{}
+			t.wroteRequest = time.Time{}
+			t.lock.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.lock.Lock()
+			t.dnsStart = time.Now()
+			t.lock.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.lock.Lock()
+			t.phases.DNS = since(t.dnsStart)
+			t.lock.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.lock.Lock()
+			t.connectStart = time.Now()
+			t.lock.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			t.lock.Lock()
+			t.phases.Connect = since(t.connectStart)
+			t.lock.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.lock.Lock()
+			t.tlsStart = time.Now()
+			t.lock.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.lock.Lock()
+			t.phases.TLS = since(t.tlsStart)
+			t.lock.Unlock()
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			t.lock.Lock()
+			t.gotConn = time.Now()
+			t.lock.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.lock.Lock()
+			t.wroteRequest = time.Now()
+			t.phases.RequestWrite = since(t.gotConn)
+			t.lock.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.lock.Lock()
+			t.phases.TTFB = since(t.wroteRequest)
+			t.lock.Unlock()
+		},
+	})
+}
+
+// ResponsePhases returns how long the phases of the final attempt of the
+// request for the response took, or nil if not known, such as for a response
+// not from a client created with NewClient or NewInsecureClient. A phase not
+// yet complete, such as the request write for a response given before the
+// whole body was sent, is 0.
+func ResponsePhases(resp *http.Response) *RequestPhases {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	t, ok := resp.Request.Context().Value(requestPhasesKey{}).(*phaseTrace)
+	if !ok {
+		return nil
+	}
+	t.lock.Lock()
+	phases := t.phases
+	t.lock.Unlock()
+	return &phases
+}
@@ -0,0 +1,183 @@
+package nectar
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// These hold a file's attributes with -preserve: its mode as octal Unix
+// permission bits, its owner's numeric user and group IDs where the platform
+// has them, and, for a symlink, its target. A symlink is uploaded as an empty
+// object with its target recorded; download then recreates the symlink.
+const (
+	preserveModeHeader    = "X-Object-Meta-Nectar-Mode"
+	preserveUIDHeader     = "X-Object-Meta-Nectar-Uid"
+	preserveGIDHeader     = "X-Object-Meta-Nectar-Gid"
+	preserveSymlinkHeader = "X-Object-Meta-Nectar-Symlink"
+)
+
+// fileAttributeHeaders adds the attributes of the file at path to the headers
+// for upload. A symlink's own attributes and target are recorded if symlinks
+// is true; otherwise those of the file it refers to are.
+func fileAttributeHeaders(path string, symlinks bool, headers map[string]string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if symlinks {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			headers[preserveSymlinkHeader] = target
+		} else if fi, err = os.Stat(path); err != nil {
+			return err
+		}
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		headers[preserveModeHeader] = fmt.Sprintf("%04o", unixMode(fi.Mode()))
+	}
+	if uid, gid, ok := fileOwner(fi); ok {
+		headers[preserveUIDHeader] = strconv.Itoa(uid)
+		headers[preserveGIDHeader] = strconv.Itoa(gid)
+	}
+	return nil
+}
+
+// restoreFileAttributes sets the attributes recorded in the object's headers
+// on the file, or symlink, at path. All are attempted, the first error being
+// returned; ownership usually requires running as root. A symlink only has its
+// owner restored: any mode recorded is ignored, as setting it would change the
+// link's target instead, which could be any file on the host.
+func restoreFileAttributes(path string, header http.Header) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	// The owner is set first, as changing it clears any setuid and setgid
+	// bits.
+	if header.Get(preserveUIDHeader) != "" || header.Get(preserveGIDHeader) != "" {
+		uid, err := strconv.Atoi(header.Get(preserveUIDHeader))
+		var gid int
+		if err == nil {
+			gid, err = strconv.Atoi(header.Get(preserveGIDHeader))
+		}
+		if err == nil {
+			err = os.Lchown(path, uid, gid)
+		}
+		if err != nil {
+			firstErr = fmt.Errorf("Could not restore owner %s:%s of %s: %s", header.Get(preserveUIDHeader), header.Get(preserveGIDHeader), path, err)
+		}
+	}
+	if v := header.Get(preserveModeHeader); v != "" && fi.Mode()&os.ModeSymlink == 0 {
+		mode, err := parseOctalMode(v)
+		if err == nil {
+			err = os.Chmod(path, mode)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("Could not restore mode %s of %s: %s", v, path, err)
+		}
+	}
+	return firstErr
+}
+
+// pendingSymlinks are the symlinks a download -preserve creates once every
+// regular file has been written, so no file is ever written through a symlink
+// an object named, such as object a linking to /etc followed by object
+// a/passwd.
+type pendingSymlinks struct {
+	lock  sync.Mutex
+	links []*pendingSymlink
+}
+
+type pendingSymlink struct {
+	path   string
+	target string
+	header http.Header
+}
+
+// add queues the symlink at path to target, with the attributes recorded in
+// the object's headers.
+func (p *pendingSymlinks) add(path string, target string, header http.Header) {
+	p.lock.Lock()
+	p.links = append(p.links, &pendingSymlink{path: path, target: target, header: header})
+	p.lock.Unlock()
+}
+
+// create creates the queued symlinks, returning an error for each that could
+// not be. The deepest are created first so none is created through another;
+// a symlink whose path is a directory with content is not created at all.
+// Attributes that cannot be restored are passed to verbosef.
+func (p *pendingSymlinks) create(verbosef func(frmt string, args ...interface{})) []error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	sort.SliceStable(p.links, func(i, j int) bool {
+		return strings.Count(p.links[i].path, string(os.PathSeparator)) > strings.Count(p.links[j].path, string(os.PathSeparator))
+	})
+	var errs []error
+	for _, link := range p.links {
+		if fi, err := os.Lstat(filepath.Dir(link.path)); err != nil || !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("Could not create symlink %s to %s: %s is not a directory", link.path, link.target, filepath.Dir(link.path)))
+			continue
+		}
+		os.Remove(link.path)
+		if err := os.Symlink(link.target, link.path); err != nil {
+			errs = append(errs, fmt.Errorf("Could not create symlink %s to %s: %s", link.path, link.target, err))
+			continue
+		}
+		if err := restoreFileAttributes(link.path, link.header); err != nil {
+			verbosef("%s\n", err)
+		}
+	}
+	p.links = nil
+	return errs
+}
+
+// unixMode returns the Unix permission bits, including setuid, setgid, and
+// sticky, of the mode.
+func unixMode(m os.FileMode) uint32 {
+	mode := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 01000
+	}
+	return mode
+}
+
+// parseOctalMode parses octal Unix permission bits, as formatted from
+// unixMode.
+func parseOctalMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	return fileMode(uint32(mode)), nil
+}
+
+// fileMode is the inverse of unixMode.
+func fileMode(mode uint32) os.FileMode {
+	m := os.FileMode(mode & 0777)
+	if mode&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package nectar
+
+import "os"
+
+// fileOwner is not supported on this platform, such as Windows, which has no
+// numeric user and group IDs.
+func fileOwner(fi os.FileInfo) (int, int, bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,41 @@
+package nectar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreFileAttributesSymlinkMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nectar-preserve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "target")
+	if err = ioutil.WriteFile(target, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chmod(target, 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err = os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unavailable: %s", err)
+	}
+	header := http.Header{}
+	header.Set(preserveSymlinkHeader, target)
+	header.Set(preserveModeHeader, "0777")
+	if err = restoreFileAttributes(link, header); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("mode of the link's target changed to %04o", fi.Mode().Perm())
+	}
+}
@@ -0,0 +1,109 @@
+package nectar
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// transferProgress reports the aggregate progress of an upload or download to
+// stderr at an interval. Bytes are taken from the client's byte count for the
+// direction of transfer if it implements ClientStats, so progress moves during
+// large objects, or otherwise from the sizes of the objects completed. The
+// totals are only known, and reported, for planned transfers. Any discovery,
+// such as the files found so far by an upload's walk, is reported first. With an
+// interval of 0 progress is only tracked, not reported. Any -progress-json
+// events are given progress events at the interval as well. A nil
+// *transferProgress is valid and reports nothing.
+type transferProgress struct {
+	verb         string
+	statsKey     string
+	totalBytes   int64
+	totalObjects int64
+	doneBytes    int64
+	doneObjects  int64
+	stats        ClientStats
+	discovery    fmt.Stringer
+	events       *progressEvents
+	startBytes   int64
+	stop         chan struct{}
+	stopped      chan struct{}
+}
+
+// newTransferProgress starts reporting; verb is such as "Downloaded" and
+// statsKey is the ClientStats key counting the bytes transferred. The totals
+// should be 0 if unknown, and discovery and events nil if there are none.
+func newTransferProgress(c Client, verb string, statsKey string, interval time.Duration, totalObjects int, totalBytes int64, discovery fmt.Stringer, events *progressEvents) *transferProgress {
+	p := &transferProgress{verb: verb, statsKey: statsKey, totalBytes: totalBytes, totalObjects: int64(totalObjects), discovery: discovery, events: events, stop: make(chan struct{}), stopped: make(chan struct{})}
+	if stats, ok := c.(ClientStats); ok {
+		p.stats = stats
+		p.startBytes = stats.Stats()[statsKey]
+	}
+	if interval <= 0 {
+		close(p.stopped)
+		return p
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintf(os.Stderr, "\r%s", p)
+				p.events.progress("progress", p, false)
+			case <-p.stop:
+				fmt.Fprintf(os.Stderr, "\r%s\n", p)
+				close(p.stopped)
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// completed records an object of the given size as transferred.
+func (p *transferProgress) completed(size int64) {
+	if p == nil {
+		return
+	}
+	atomic.AddInt64(&p.doneObjects, 1)
+	atomic.AddInt64(&p.doneBytes, size)
+}
+
+// counts returns the objects and bytes transferred so far.
+func (p *transferProgress) counts() (int64, int64) {
+	done := atomic.LoadInt64(&p.doneBytes)
+	if p.stats != nil {
+		done = p.stats.Stats()[p.statsKey] - p.startBytes
+	}
+	return atomic.LoadInt64(&p.doneObjects), done
+}
+
+func (p *transferProgress) String() string {
+	objects, done := p.counts()
+	if p.totalObjects == 0 {
+		s := fmt.Sprintf("%s %s in %d objects", p.verb, humanBytes(done), objects)
+		if p.discovery != nil {
+			s = p.discovery.String() + "; " + s
+		}
+		return s
+	}
+	if done > p.totalBytes {
+		done = p.totalBytes
+	}
+	percent := 100.0
+	if p.totalBytes > 0 {
+		percent = float64(done) * 100 / float64(p.totalBytes)
+	}
+	return fmt.Sprintf("%s %s of %s (%.0f%%), %d of %d objects", p.verb, humanBytes(done), humanBytes(p.totalBytes), percent, objects, p.totalObjects)
+}
+
+// Close stops the reporting after a final report, if reporting.
+func (p *transferProgress) Close() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.stopped
+}
@@ -0,0 +1,144 @@
+package nectar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressEvent is one line of -progress-json output. Event is one of
+// started, completed, skipped, failed, progress, or finished; the per-object
+// events have Path, Container, and Object, and the progress and finished
+// events have the running totals.
+type progressEvent struct {
+	Time         time.Time `json:"time"`
+	Event        string    `json:"event"`
+	Operation    string    `json:"operation"`
+	Path         string    `json:"path,omitempty"`
+	Container    string    `json:"container,omitempty"`
+	Object       string    `json:"object,omitempty"`
+	Bytes        int64     `json:"bytes"`
+	Objects      int64     `json:"objects,omitempty"`
+	TotalBytes   int64     `json:"total_bytes,omitempty"`
+	TotalObjects int64     `json:"total_objects,omitempty"`
+	Interrupted  bool      `json:"interrupted,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// progressEvents writes the -progress-json stream, one JSON event per line,
+// for wrapping uploads and downloads with other progress displays. If a write
+// fails, such as the reader having gone away, a warning is output and the
+// events stop; the transfer itself goes on. A nil *progressEvents is valid
+// and writes nothing.
+type progressEvents struct {
+	stream    *progressStream
+	operation string
+}
+
+// progressStream is the destination shared by the progressEvents of every
+// operation, so concurrent CLIInstance.Run calls interleave whole lines.
+type progressStream struct {
+	lock sync.Mutex
+	w    io.WriteCloser
+	enc  *json.Encoder
+}
+
+// openProgressEvents opens the destination for -progress-json: fd:<n> for an
+// already open file descriptor, unix:<path> or tcp:<host:port> for a socket to
+// connect to, or else a file path to create.
+func openProgressEvents(dest string) (*progressEvents, error) {
+	var w io.WriteCloser
+	var err error
+	switch {
+	case strings.HasPrefix(dest, "fd:"):
+		var fd uint64
+		if fd, err = strconv.ParseUint(dest[3:], 10, 32); err != nil {
+			return nil, fmt.Errorf("Invalid file descriptor in %s", dest)
+		}
+		w = os.NewFile(uintptr(fd), dest)
+	case strings.HasPrefix(dest, "unix:"):
+		w, err = net.Dial("unix", dest[5:])
+	case strings.HasPrefix(dest, "tcp:"):
+		w, err = net.Dial("tcp", dest[4:])
+	default:
+		w, err = os.Create(dest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &progressEvents{stream: &progressStream{w: w, enc: json.NewEncoder(w)}}, nil
+}
+
+// begin returns the progressEvents for an operation, such as upload, given
+// with each of its events; it writes to the same stream as e.
+func (e *progressEvents) begin(operation string) *progressEvents {
+	if e == nil {
+		return nil
+	}
+	return &progressEvents{stream: e.stream, operation: operation}
+}
+
+func (e *progressEvents) emit(ev *progressEvent) {
+	if e == nil {
+		return
+	}
+	ev.Operation = e.operation
+	e.stream.emit(ev)
+}
+
+func (s *progressStream) emit(ev *progressEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.enc == nil {
+		return
+	}
+	ev.Time = time.Now().UTC()
+	if err := s.enc.Encode(ev); err != nil {
+		fmt.Fprintf(os.Stderr, "Stopping -progress-json events: %s\n", err)
+		s.enc = nil
+	}
+}
+
+// started records the transfer of the object beginning; bytes is its size if
+// known.
+func (e *progressEvents) started(path string, container string, object string, bytes int64) {
+	e.emit(&progressEvent{Event: "started", Path: path, Container: container, Object: object, Bytes: bytes})
+}
+
+// completed records the object of the given size as transferred.
+func (e *progressEvents) completed(path string, container string, object string, bytes int64) {
+	e.emit(&progressEvent{Event: "completed", Path: path, Container: container, Object: object, Bytes: bytes})
+}
+
+// skipped records the object as not transferred, such as one left alone by
+// -no-clobber.
+func (e *progressEvents) skipped(path string, container string, object string) {
+	e.emit(&progressEvent{Event: "skipped", Path: path, Container: container, Object: object})
+}
+
+// failed records the transfer of the object as having failed with err.
+func (e *progressEvents) failed(path string, container string, object string, err error) {
+	e.emit(&progressEvent{Event: "failed", Path: path, Container: container, Object: object, Error: err.Error()})
+}
+
+// progress records the totals so far, as a progress or finished event.
+func (e *progressEvents) progress(event string, p *transferProgress, interrupted bool) {
+	if e == nil || p == nil {
+		return
+	}
+	objects, bytes := p.counts()
+	e.emit(&progressEvent{Event: event, Bytes: bytes, Objects: objects, TotalBytes: p.totalBytes, TotalObjects: p.totalObjects, Interrupted: interrupted})
+}
+
+func (e *progressEvents) Close() error {
+	if e == nil {
+		return nil
+	}
+	return e.stream.w.Close()
+}
@@ -0,0 +1,79 @@
+package nectar
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// responseContentRange returns where the content of the response to a
+// ranged GET lies within the object; see GetObjectRange.
+func responseContentRange(resp *http.Response) *ContentRange {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The range was ignored; the content is the whole object.
+		if resp.ContentLength < 0 {
+			return nil
+		}
+		return &ContentRange{Start: 0, End: resp.ContentLength - 1, Size: resp.ContentLength}
+	case http.StatusPartialContent:
+		// bytes <start>-<end>/<size>, the size possibly being *.
+		v := strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes ")
+		i := strings.Index(v, "-")
+		j := strings.Index(v, "/")
+		if i < 0 || j < i {
+			return nil
+		}
+		cr := &ContentRange{Size: -1}
+		var err error
+		if cr.Start, err = strconv.ParseInt(v[:i], 10, 64); err != nil {
+			return nil
+		}
+		if cr.End, err = strconv.ParseInt(v[i+1:j], 10, 64); err != nil {
+			return nil
+		}
+		if size, err := strconv.ParseInt(v[j+1:], 10, 64); err == nil {
+			cr.Size = size
+		}
+		return cr
+	case http.StatusRequestedRangeNotSatisfiable:
+		// bytes */<size>
+		cr := &ContentRange{Start: -1, End: -1, Size: -1}
+		if v := resp.Header.Get("Content-Range"); strings.HasPrefix(v, "bytes */") {
+			if size, err := strconv.ParseInt(v[len("bytes */"):], 10, 64); err == nil {
+				cr.Size = size
+			}
+		}
+		return cr
+	}
+	return nil
+}
+
+// rangeEmpty returns true if resp is a 416 Requested Range Not Satisfiable for
+// a single range that simply selects no content, rather than one beyond the
+// end of the object: a suffix range, such as bytes=-1024, of a zero-byte
+// object, or a range starting exactly at the end of the object, such as when
+// resuming a transfer that had in fact completed.
+func rangeEmpty(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable || resp.Request == nil {
+		return false
+	}
+	size := responseContentRange(resp).Size
+	if size < 0 {
+		return false
+	}
+	spec := resp.Request.Header.Get("Range")
+	if !strings.HasPrefix(spec, "bytes=") || strings.Contains(spec, ",") {
+		return false
+	}
+	spec = strings.TrimSpace(spec[len("bytes="):])
+	if strings.HasPrefix(spec, "-") {
+		return size == 0
+	}
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return false
+	}
+	start, err := strconv.ParseInt(spec[:i], 10, 64)
+	return err == nil && start == size
+}
@@ -0,0 +1,113 @@
+package nectar
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRangeEmpty(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		status int
+		spec   string
+		cr     string
+		want   bool
+	}{
+		{name: "suffix of zero-byte object", status: 416, spec: "bytes=-1024", cr: "bytes */0", want: true},
+		{name: "suffix of non-empty object", status: 416, spec: "bytes=-1024", cr: "bytes */10", want: false},
+		{name: "start at size", status: 416, spec: "bytes=10-", cr: "bytes */10", want: true},
+		{name: "start and end at size", status: 416, spec: "bytes=10-19", cr: "bytes */10", want: true},
+		{name: "start beyond size", status: 416, spec: "bytes=11-", cr: "bytes */10", want: false},
+		{name: "multiple ranges", status: 416, spec: "bytes=0-0,10-", cr: "bytes */10", want: false},
+		{name: "no size", status: 416, spec: "bytes=10-", cr: "", want: false},
+		{name: "not a 416", status: 206, spec: "bytes=0-", cr: "bytes 0-9/10", want: false},
+	} {
+		resp := &http.Response{StatusCode: test.status, Header: http.Header{}, Request: &http.Request{Header: http.Header{}}}
+		resp.Request.Header.Set("Range", test.spec)
+		if test.cr != "" {
+			resp.Header.Set("Content-Range", test.cr)
+		}
+		if got := rangeEmpty(resp); got != test.want {
+			t.Errorf("%s: rangeEmpty for Range %q and Content-Range %q = %v, want %v", test.name, test.spec, test.cr, got, test.want)
+		}
+	}
+}
+
+// rangeClient serves GetObject from content, honoring If-Match and single
+// ranges as Swift does, unless ignoreRange.
+type rangeClient struct {
+	Client
+	content     string
+	etag        string
+	ignoreRange bool
+}
+
+func (c *rangeClient) GetObject(container string, obj string, headers map[string]string) *http.Response {
+	req := &http.Request{Method: "GET", URL: &url.URL{Path: "/v1/AUTH_test/" + container + "/" + obj}, Header: http.Header{}}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Request: req, ContentLength: int64(len(c.content))}
+	resp.Header.Set("Etag", c.etag)
+	body := c.content
+	if im := req.Header.Get("If-Match"); im != "" && strings.Trim(im, "\"") != c.etag {
+		resp.StatusCode = http.StatusPreconditionFailed
+		body = ""
+	} else if spec := req.Header.Get("Range"); spec != "" && !c.ignoreRange {
+		// Only bytes=<start>- is needed here.
+		start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(spec, "bytes="), "-"))
+		if start >= len(c.content) {
+			resp.StatusCode = http.StatusRequestedRangeNotSatisfiable
+			resp.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", len(c.content)))
+			body = ""
+		} else {
+			resp.StatusCode = http.StatusPartialContent
+			resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(c.content)-1, len(c.content)))
+			body = c.content[start:]
+		}
+	}
+	resp.ContentLength = int64(len(body))
+	resp.Body = ioutil.NopCloser(strings.NewReader(body))
+	return resp
+}
+
+func TestResumeDownload(t *testing.T) {
+	cli := newCLIInstance("nectar", nil, nil, nil, nil)
+	cli.buffers = newBufferPool(0)
+	for _, test := range []struct {
+		name        string
+		content     string
+		offset      int64
+		etag        string
+		ignoreRange bool
+		want        string
+		err         bool
+	}{
+		{name: "rest of object", content: "hello", offset: 2, want: "llo"},
+		{name: "stalled after last byte", content: "hello", offset: 5, want: ""},
+		{name: "zero-byte object", content: "", offset: 0, want: ""},
+		{name: "range ignored", content: "hello", offset: 2, ignoreRange: true, want: "llo"},
+		{name: "object changed", content: "hello", offset: 2, etag: "other", err: true},
+	} {
+		c := &rangeClient{content: test.content, etag: "etag", ignoreRange: test.ignoreRange}
+		etag := test.etag
+		if etag == "" {
+			etag = c.etag
+		}
+		var buf bytes.Buffer
+		n, err := cli.resumeDownload(c, "c", "o", etag, test.offset, &buf)
+		if (err != nil) != test.err {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.err)
+			continue
+		}
+		if n != int64(buf.Len()) || buf.String() != test.want {
+			t.Errorf("%s: got %d bytes %q, want %q", test.name, n, buf.String(), test.want)
+		}
+	}
+}
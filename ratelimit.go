@@ -0,0 +1,48 @@
+package nectar
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter paces operations to a steady rate across all the goroutines
+// sharing it, such as for -delete-rate. Unused time does not accumulate, so
+// there are no bursts after a pause. A nil *rateLimiter is valid and imposes
+// no limit.
+type rateLimiter struct {
+	lock     sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter returns nil, for no limit, if perSecond is not positive.
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the next operation may begin, returning false if done is
+// closed first.
+func (r *rateLimiter) wait(done <-chan struct{}) bool {
+	if r == nil {
+		return true
+	}
+	r.lock.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	at := r.next
+	r.next = r.next.Add(r.interval)
+	r.lock.Unlock()
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
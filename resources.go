@@ -0,0 +1,113 @@
+package nectar
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// resourceSampler periodically samples the client process's CPU and memory
+// usage, along with the bytes the client has transferred, so bench results
+// can show whether the cluster or the client machine was the limiting
+// factor.
+type resourceSampler struct {
+	stats     ClientStats
+	start     time.Time
+	startCPU  time.Duration
+	startSent int64
+	startRecv int64
+	lastTime  time.Time
+	lastCPU   time.Duration
+	cpuOK     bool
+	peakCPU   float64
+	peakSys   uint64
+	peakHeap  uint64
+	lock      sync.Mutex
+	stopChan  chan struct{}
+	doneChan  chan struct{}
+}
+
+// newResourceSampler starts sampling; c will be used for transfer counts if
+// it implements ClientStats.
+func newResourceSampler(c Client) *resourceSampler {
+	s := &resourceSampler{start: time.Now(), stopChan: make(chan struct{}), doneChan: make(chan struct{})}
+	s.stats, _ = c.(ClientStats)
+	if s.stats != nil {
+		stats := s.stats.Stats()
+		s.startSent = stats["bytes_sent"]
+		s.startRecv = stats["bytes_received"]
+	}
+	s.startCPU, s.cpuOK = processCPUTime()
+	s.lastTime = s.start
+	s.lastCPU = s.startCPU
+	go s.run()
+	return s
+}
+
+func (s *resourceSampler) run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sample()
+		case <-s.stopChan:
+			close(s.doneChan)
+			return
+		}
+	}
+}
+
+func (s *resourceSampler) sample() {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	now := time.Now()
+	cpu, _ := processCPUTime()
+	s.lock.Lock()
+	if mem.Sys > s.peakSys {
+		s.peakSys = mem.Sys
+	}
+	if mem.HeapAlloc > s.peakHeap {
+		s.peakHeap = mem.HeapAlloc
+	}
+	if s.cpuOK {
+		if pct := cpuPercent(cpu-s.lastCPU, now.Sub(s.lastTime)); pct > s.peakCPU {
+			s.peakCPU = pct
+		}
+		s.lastCPU = cpu
+		s.lastTime = now
+	}
+	s.lock.Unlock()
+}
+
+// stop ends the sampling and returns a one line summary of the usage.
+func (s *resourceSampler) stop() string {
+	close(s.stopChan)
+	<-s.doneChan
+	s.sample()
+	elapsed := time.Since(s.start)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	report := "Client resources:"
+	if s.cpuOK {
+		cpu, _ := processCPUTime()
+		report += fmt.Sprintf(" CPU %.01f%% average, %.01f%% peak, of %d cores;", cpuPercent(cpu-s.startCPU, elapsed), s.peakCPU, runtime.NumCPU())
+	}
+	report += fmt.Sprintf(" memory %.01f MiB peak, %.01f MiB peak heap", float64(s.peakSys)/(1<<20), float64(s.peakHeap)/(1<<20))
+	if s.stats != nil {
+		stats := s.stats.Stats()
+		seconds := elapsed.Seconds()
+		report += fmt.Sprintf("; network %.02f MiB/s sent, %.02f MiB/s received", float64(stats["bytes_sent"]-s.startSent)/(1<<20)/seconds, float64(stats["bytes_received"]-s.startRecv)/(1<<20)/seconds)
+	}
+	return report + "."
+}
+
+// cpuPercent returns the CPU time as a percentage of all cores over the
+// elapsed time.
+func cpuPercent(cpu time.Duration, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(cpu) / float64(elapsed) / float64(runtime.NumCPU()) * 100
+}
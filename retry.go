@@ -0,0 +1,74 @@
+package nectar
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures the retrying of failed requests; see
+// WithRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a request will be sent, including the
+	// first; less than 2 disables retries.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubling with each
+	// retry after.
+	Backoff time.Duration
+	// MaxBackoff limits the delay between attempts; 0 is no limit.
+	MaxBackoff time.Duration
+	// Jitter is the fraction, from 0 to 1, of each delay that is
+	// randomized, so that clients failing together do not retry together.
+	Jitter float64
+	// RetryableStatuses are the response status codes to retry; if nil,
+	// 408, 429, and all 5xx statuses are retried. Transport errors are
+	// always retried.
+	RetryableStatuses []int
+}
+
+// retryable returns true if the attempt, which failed with err or returned
+// resp, may be retried.
+func (p *RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	if p.RetryableStatuses == nil {
+		return resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5
+	}
+	for _, status := range p.RetryableStatuses {
+		if resp.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait after the given attempt, counting from 1,
+// before the next. A Retry-After in seconds on the response is honored if
+// longer, up to any MaxBackoff.
+func (p *RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 && d > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= time.Duration(rand.Float64() * jitter * float64(d))
+	}
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && time.Duration(seconds)*time.Second > d {
+			d = time.Duration(seconds) * time.Second
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				d = p.MaxBackoff
+			}
+		}
+	}
+	return d
+}
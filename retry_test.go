@@ -0,0 +1,219 @@
+package nectar
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// retryServer answers each request with the next of its statuses, repeating
+// the last once they run out, and records the bodies it was sent.
+type retryServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	statuses []int
+	header   http.Header
+	bodies   []string
+}
+
+func newRetryServer(header http.Header, statuses ...int) *retryServer {
+	s := &retryServer{statuses: statuses, header: header}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		s.lock.Lock()
+		s.bodies = append(s.bodies, string(body))
+		status := s.statuses[0]
+		if len(s.statuses) > 1 {
+			s.statuses = s.statuses[1:]
+		}
+		s.lock.Unlock()
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func (s *retryServer) requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+// seekable returns a seekable body that, unlike a bytes.Reader, http.Request
+// does not know how to rewind by itself, as with a file.
+func seekable(content string) io.ReadSeeker {
+	return struct{ io.ReadSeeker }{strings.NewReader(content)}
+}
+
+// newTestClient returns a client, already authenticated, for the service
+// endpoints given.
+func newTestClient(urls ...string) *userClient {
+	return &userClient{client: &http.Client{}, ServiceURLs: urls, AuthToken: "token"}
+}
+
+func TestRetrySeekableBody(t *testing.T) {
+	s := newRetryServer(nil, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusCreated)
+	defer s.Close()
+	c := newTestClient(s.URL)
+	c.retry = &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	resp := c.PutObject("c", "o", nil, seekable("the content"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if retries := ResponseRetries(resp); retries != 2 {
+		t.Errorf("got %d retries, want 2", retries)
+	}
+	bodies := s.requests()
+	if len(bodies) != 3 {
+		t.Fatalf("got %d requests, want 3", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != "the content" {
+			t.Errorf("attempt %d sent %q, want %q", i+1, body, "the content")
+		}
+	}
+}
+
+func TestRetryNonSeekableBody(t *testing.T) {
+	s := newRetryServer(nil, http.StatusServiceUnavailable, http.StatusCreated)
+	defer s.Close()
+	c := newTestClient(s.URL)
+	c.retry = &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	resp := c.PutObject("c", "o", nil, ioutil.NopCloser(strings.NewReader("the content")))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if bodies := s.requests(); len(bodies) != 1 || bodies[0] != "the content" {
+		t.Errorf("got requests %q, want just the one", bodies)
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	for _, test := range []struct {
+		policy *RetryPolicy
+		status int
+		want   int
+	}{
+		{policy: nil, status: http.StatusServiceUnavailable, want: 1},
+		{policy: &RetryPolicy{MaxAttempts: 4, Backoff: time.Millisecond}, status: http.StatusServiceUnavailable, want: 4},
+		{policy: &RetryPolicy{MaxAttempts: 4, Backoff: time.Millisecond}, status: http.StatusNotFound, want: 1},
+		{policy: &RetryPolicy{MaxAttempts: 4, Backoff: time.Millisecond, RetryableStatuses: []int{http.StatusNotFound}}, status: http.StatusNotFound, want: 4},
+	} {
+		s := newRetryServer(nil, test.status)
+		c := newTestClient(s.URL)
+		c.retry = test.policy
+		resp := c.HeadObject("c", "o", nil)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%+v: got status %d, want %d", test.policy, resp.StatusCode, test.status)
+		}
+		if got := len(s.requests()); got != test.want {
+			t.Errorf("%+v: got %d attempts, want %d", test.policy, got, test.want)
+		}
+		s.Close()
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	retryAfter := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": {value}}}
+	}
+	for _, test := range []struct {
+		policy  RetryPolicy
+		attempt int
+		resp    *http.Response
+		want    time.Duration
+	}{
+		{policy: RetryPolicy{Backoff: time.Second}, attempt: 1, want: time.Second},
+		{policy: RetryPolicy{Backoff: time.Second}, attempt: 3, want: 4 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second, MaxBackoff: 3 * time.Second}, attempt: 3, want: 3 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second}, attempt: 1, resp: retryAfter("5"), want: 5 * time.Second},
+		{policy: RetryPolicy{Backoff: 8 * time.Second}, attempt: 1, resp: retryAfter("5"), want: 8 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second, MaxBackoff: 3 * time.Second}, attempt: 1, resp: retryAfter("5"), want: 3 * time.Second},
+		{policy: RetryPolicy{Backoff: time.Second}, attempt: 1, resp: retryAfter("soon"), want: time.Second},
+	} {
+		if got := test.policy.delay(test.attempt, test.resp); got != test.want {
+			t.Errorf("%+v delay(%d) = %s, want %s", test.policy, test.attempt, got, test.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	s := newRetryServer(http.Header{"Retry-After": {"1"}}, http.StatusTooManyRequests, http.StatusNoContent)
+	defer s.Close()
+	c := newTestClient(s.URL)
+	c.retry = &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+	start := time.Now()
+	resp := c.HeadObject("c", "o", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, before the Retry-After of 1s", elapsed)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	s := newRetryServer(nil, http.StatusServiceUnavailable)
+	defer s.Close()
+	c := newTestClient(s.URL)
+	c.retry = &RetryPolicy{MaxAttempts: 10, Backoff: time.Millisecond}
+	c.budget = &retryBudget{tokens: 2}
+	resp := c.HeadObject("c", "o", nil)
+	resp.Body.Close()
+	if got := len(s.requests()); got != 3 {
+		t.Errorf("got %d attempts, want 3 with a budget of 2 retries", got)
+	}
+	if retries := ResponseRetries(resp); retries != 2 {
+		t.Errorf("got %d retries, want 2", retries)
+	}
+	resp = c.HeadObject("c", "o", nil)
+	resp.Body.Close()
+	if got := len(s.requests()); got != 4 {
+		t.Errorf("got %d attempts, want 4 with the budget spent", got)
+	}
+}
+
+func TestRedirectRequest(t *testing.T) {
+	c := newTestClient("http://one/v1/AUTH_test")
+	req, err := c.authedRequest("PUT", "/c/o", seekable("the content"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := req.Context().Value(requestTargetKey{}).(*requestTarget)
+	if _, err = ioutil.ReadAll(req.Body); err != nil {
+		t.Fatal(err)
+	}
+	nreq, err := redirectRequest(req, target, "http://two/v1/AUTH_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nreq.URL.String(); got != "http://two/v1/AUTH_test/c/o" {
+		t.Errorf("redirected to %s", got)
+	}
+	if got := nreq.Header.Get("X-Auth-Token"); got != "token" {
+		t.Errorf("got X-Auth-Token %q, want %q", got, "token")
+	}
+	if body, _ := ioutil.ReadAll(nreq.Body); string(body) != "the content" {
+		t.Errorf("redirected request has body %q, want %q", body, "the content")
+	}
+
+	req, err = c.authedRequest("PUT", "/c/o", ioutil.NopCloser(strings.NewReader("the content")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target = req.Context().Value(requestTargetKey{}).(*requestTarget)
+	if _, err = redirectRequest(req, target, "http://two/v1/AUTH_test"); err == nil {
+		t.Errorf("redirected a request whose body cannot be rewound")
+	}
+}
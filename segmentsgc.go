@@ -0,0 +1,271 @@
+package nectar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/troubling/nectar/nectarutil"
+)
+
+// listingTimeFormat is the format of LastModified in listings.
+const listingTimeFormat = "2006-01-02T15:04:05.999999"
+
+// segmentReferences are the objects in a segment container that are in use by
+// large object manifests: the segments named by static large objects, and
+// everything under the prefixes of dynamic large objects.
+type segmentReferences struct {
+	container string
+	lock      sync.Mutex
+	segments  map[string]bool
+	prefixes  []string
+	// manifests guards against following the same nested manifest twice.
+	manifests map[string]bool
+}
+
+func (r *segmentReferences) referenced(name string) bool {
+	if r.segments[name] {
+		return true
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// addSegmentReferences adds the references of the object, given its HEAD
+// headers, if it is a large object manifest, following nested static large
+// objects.
+func (cli *CLIInstance) addSegmentReferences(c Client, refs *segmentReferences, container string, object string, header http.Header) error {
+	if v := header.Get("X-Object-Manifest"); v != "" {
+		if unescaped, err := url.PathUnescape(v); err == nil {
+			v = unescaped
+		}
+		if i := strings.Index(v, "/"); i >= 0 && v[:i] == refs.container {
+			refs.lock.Lock()
+			refs.prefixes = append(refs.prefixes, v[i+1:])
+			refs.lock.Unlock()
+		}
+		return nil
+	}
+	if header.Get("X-Static-Large-Object") == "" {
+		return nil
+	}
+	refs.lock.Lock()
+	seen := refs.manifests[container+"/"+object]
+	refs.manifests[container+"/"+object] = true
+	refs.lock.Unlock()
+	if seen {
+		return nil
+	}
+	resp := c.Raw("GET", "/"+nectarutil.EscapeObjectPath(container)+"/"+nectarutil.EscapeObjectPath(object)+"?multipart-manifest=get", cli.globalFlagHeaders.Headers(), nil)
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		return NewResponseError(resp)
+	}
+	segments, err := decodeStoredManifest(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("Could not parse the manifest of %s/%s: %s", container, object, err)
+	}
+	for _, segment := range segments {
+		path := segment.Name
+		if path == "" {
+			path = segment.Path
+		}
+		parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if parts[0] == refs.container {
+			refs.lock.Lock()
+			refs.segments[parts[1]] = true
+			refs.lock.Unlock()
+		}
+		if segment.SubSLO {
+			if err := cli.addSegmentReferences(c, refs, parts[0], parts[1], http.Header{"X-Static-Large-Object": {"True"}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// storedSegment is an entry of a manifest as stored, from a GET with
+// ?multipart-manifest=get, which names segments with name rather than the
+// path given when it was written.
+type storedSegment struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	SubSLO bool   `json:"sub_slo"`
+}
+
+// decodeStoredManifest returns the segments of the manifest as stored. A
+// manifest with no segments may be stored as nothing at all.
+func decodeStoredManifest(r io.Reader) ([]storedSegment, error) {
+	var segments []storedSegment
+	if err := json.NewDecoder(r).Decode(&segments); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return segments, nil
+}
+
+func (cli *CLIInstance) segmentsGC(c Client, args []string) {
+	if err := cli.SegmentsGCFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.SegmentsGCFlags.Args()
+	if len(args) != 1 {
+		cli.fatalf(cli, "segments-gc requires <segment container>.\n")
+	}
+	segmentContainer := args[0]
+	manifestContainers := []string(cli.segmentsGCFlagManifestContainer)
+	if len(manifestContainers) == 0 {
+		if !strings.HasSuffix(segmentContainer, "_segments") {
+			cli.fatalf(cli, "segments-gc requires -manifest-container unless the segment container is named <container>_segments.\n")
+		}
+		manifestContainers = []string{strings.TrimSuffix(segmentContainer, "_segments")}
+	}
+	minAge, err := time.ParseDuration(*cli.segmentsGCFlagMinAge)
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	concurrency := *cli.globalFlagConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	// Any failure while finding the references is fatal, even with
+	// -continue-on-error, as segments still in use would be reported.
+	refs := &segmentReferences{container: segmentContainer, segments: map[string]bool{}, manifests: map[string]bool{}}
+	scan := func(container string) {
+		names := make(chan string, concurrency)
+		var errLock sync.Mutex
+		var firstErr error
+		wg := sync.WaitGroup{}
+		wg.Add(concurrency)
+		for i := 0; i < concurrency; i++ {
+			go func() {
+				defer wg.Done()
+				for name := range names {
+					resp := c.HeadObject(container, name, cli.globalFlagHeaders.Headers())
+					cli.verboseTransID(resp)
+					var err error
+					if resp.StatusCode/100 == 2 {
+						resp.Body.Close()
+						err = cli.addSegmentReferences(c, refs, container, name, resp.Header)
+					} else if resp.StatusCode == 404 {
+						// Deleted since being listed.
+						resp.Body.Close()
+					} else {
+						err = NewResponseError(resp)
+					}
+					if err != nil {
+						errLock.Lock()
+						if firstErr == nil {
+							firstErr = err
+						}
+						errLock.Unlock()
+					}
+				}
+			}()
+		}
+		err := cli.eachObject(c, container, "", func(entry *ObjectRecord) bool {
+			names <- entry.Name
+			return true
+		})
+		close(names)
+		wg.Wait()
+		if err == nil {
+			err = firstErr
+		}
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+	}
+	for _, container := range manifestContainers {
+		cli.verbosef(cli, "Finding the segments referenced by the manifests in %q.\n", container)
+		scan(container)
+	}
+	cutoff := time.Now().Add(-minAge)
+	var orphans []*ObjectRecord
+	var orphanBytes int64
+	if err := cli.eachObject(c, segmentContainer, "", func(entry *ObjectRecord) bool {
+		if refs.referenced(entry.Name) {
+			return true
+		}
+		modified, err := time.Parse(listingTimeFormat, entry.LastModified)
+		if err != nil {
+			// Without knowing its age, the segment may be from an upload
+			// still in progress.
+			fmt.Fprintf(os.Stderr, "Skipping %q; its age is unknown from last_modified %q.\n", entry.Name, entry.LastModified)
+			return true
+		}
+		if modified.After(cutoff) {
+			cli.verbosef(cli, "Skipping %q; too recent.\n", entry.Name)
+			return true
+		}
+		orphans = append(orphans, entry)
+		orphanBytes += int64(entry.Bytes)
+		return true
+	}); err != nil {
+		cli.fatal(cli, err)
+	}
+	if *cli.globalFlagJSON {
+		names := []string{}
+		for _, entry := range orphans {
+			names = append(names, entry.Name)
+		}
+		cli.printJSON(names)
+	} else {
+		for _, entry := range orphans {
+			fmt.Println(entry.Name)
+		}
+	}
+	if !*cli.segmentsGCFlagConfirm {
+		fmt.Fprintf(os.Stderr, "%d orphaned segments of %s in %s; use -confirm to delete them.\n", len(orphans), humanBytes(orphanBytes), segmentContainer)
+		return
+	}
+	entries := make(chan *ObjectRecord, len(orphans))
+	for _, entry := range orphans {
+		entries <- entry
+	}
+	close(entries)
+	var lock sync.Mutex
+	var failures int
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				resp := c.DeleteObject(segmentContainer, entry.Name, cli.globalFlagHeaders.Headers())
+				cli.verboseTransID(resp)
+				if resp.StatusCode/100 != 2 && resp.StatusCode != 404 {
+					err := NewResponseError(resp)
+					if !*cli.globalFlagContinueOnError {
+						cli.fatal(cli, err)
+					}
+					fmt.Fprintln(os.Stderr, err)
+					lock.Lock()
+					failures++
+					lock.Unlock()
+					continue
+				}
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Fprintf(os.Stderr, "Deleted %d orphaned segments of %s from %s.\n", len(orphans)-failures, humanBytes(orphanBytes), segmentContainer)
+	if failures > 0 {
+		cli.fatalf(cli, "%d orphaned segments could not be deleted.\n", failures)
+	}
+}
@@ -0,0 +1,33 @@
+package nectar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeStoredManifest(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		body     string
+		segments int
+		err      bool
+	}{
+		{name: "empty body", body: "", segments: 0},
+		{name: "empty list", body: "[]", segments: 0},
+		{name: "one segment", body: `[{"name": "/segs/a/1", "bytes": 1}]`, segments: 1},
+		{name: "invalid", body: "[{", err: true},
+	} {
+		segments, err := decodeStoredManifest(strings.NewReader(test.body))
+		if (err != nil) != test.err {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.err)
+			continue
+		}
+		if len(segments) != test.segments {
+			t.Errorf("%s: got %d segments, want %d", test.name, len(segments), test.segments)
+		}
+	}
+	segments, _ := decodeStoredManifest(strings.NewReader(`[{"name": "/segs/a/1", "sub_slo": true}]`))
+	if len(segments) != 1 || segments[0].Name != "/segs/a/1" || !segments[0].SubSLO {
+		t.Errorf("got %+v, want the segment /segs/a/1 marked as a sub-manifest", segments)
+	}
+}
@@ -0,0 +1,150 @@
+package nectar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// shardRangeStates are the names of the Swift shard range states, which
+// listings may give as numbers.
+var shardRangeStates = map[int]string{
+	10: "found",
+	20: "created",
+	30: "cleaved",
+	40: "active",
+	50: "shrinking",
+	60: "sharding",
+	70: "shrunk",
+	80: "sharded",
+}
+
+// shardRange is an entry in a container's shard range listing, as returned
+// for X-Backend-Record-Type: shard.
+type shardRange struct {
+	Name        string          `json:"name"`
+	Lower       string          `json:"lower"`
+	Upper       string          `json:"upper"`
+	ObjectCount int64           `json:"object_count"`
+	BytesUsed   int64           `json:"bytes_used"`
+	State       json.RawMessage `json:"state"`
+}
+
+// stateName returns the state as a name, whether given as a name or number.
+func (r *shardRange) stateName() string {
+	var name string
+	if err := json.Unmarshal(r.State, &name); err == nil {
+		return name
+	}
+	var n int
+	if err := json.Unmarshal(r.State, &n); err == nil {
+		if name, ok := shardRangeStates[n]; ok {
+			return name
+		}
+		return strconv.Itoa(n)
+	}
+	return string(r.State)
+}
+
+// shardsReport is the output of the shards command.
+type shardsReport struct {
+	Container     string         `json:"container"`
+	ShardingState string         `json:"sharding_state"`
+	Exposed       bool           `json:"exposed"`
+	ObjectCount   int64          `json:"object_count"`
+	BytesUsed     int64          `json:"bytes_used"`
+	Shards        []*shardReport `json:"shards"`
+}
+
+type shardReport struct {
+	Name        string  `json:"name"`
+	Lower       string  `json:"lower"`
+	Upper       string  `json:"upper"`
+	State       string  `json:"state"`
+	ObjectCount int64   `json:"object_count"`
+	BytesUsed   int64   `json:"bytes_used"`
+	Percent     float64 `json:"percent_of_objects"`
+}
+
+func (cli *CLIInstance) shards(c Client, args []string) {
+	if err := cli.ShardsFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.ShardsFlags.Args()
+	if len(args) != 1 {
+		cli.fatalf(cli, "shards requires <container>.\n")
+	}
+	report := &shardsReport{Container: args[0], Shards: []*shardReport{}}
+	info, err := HeadContainerInfo(c, report.Container, cli.globalFlagHeaders.Headers())
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	report.ObjectCount = info.ObjectCount
+	report.BytesUsed = info.BytesUsed
+	report.ShardingState = info.Header.Get("X-Backend-Sharding-State")
+	// The shard ranges are only listed when X-Backend-Record-Type: shard
+	// gets through to the container servers; the gatekeeper middleware
+	// usually removes it for all but internal clients, in which case the
+	// object listing is returned instead and is ignored.
+	headers := cli.globalFlagHeaders.Headers()
+	headers["X-Backend-Record-Type"] = "shard"
+	resp := c.GetContainerRaw(report.Container, "", "", 0, "", "", false, headers)
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		cli.fatal(cli, NewResponseError(resp))
+	}
+	var ranges []*shardRange
+	if resp.Header.Get("X-Backend-Record-Type") == "shard" {
+		report.Exposed = true
+		if err = json.NewDecoder(resp.Body).Decode(&ranges); err != nil {
+			resp.Body.Close()
+			cli.fatalf(cli, "Could not parse the shard ranges of %s: %s\n", report.Container, err)
+		}
+		if state := resp.Header.Get("X-Backend-Sharding-State"); state != "" {
+			report.ShardingState = state
+		}
+	}
+	resp.Body.Close()
+	var total int64
+	for _, r := range ranges {
+		total += r.ObjectCount
+	}
+	for _, r := range ranges {
+		shard := &shardReport{Name: r.Name, Lower: r.Lower, Upper: r.Upper, State: r.stateName(), ObjectCount: r.ObjectCount, BytesUsed: r.BytesUsed}
+		if total > 0 {
+			shard.Percent = float64(r.ObjectCount) * 100 / float64(total)
+		}
+		report.Shards = append(report.Shards, shard)
+	}
+	if *cli.globalFlagJSON {
+		cli.printJSON(report)
+		return
+	}
+	state := report.ShardingState
+	if state == "" {
+		state = "unknown"
+	}
+	fmt.Printf("Container %s: %d objects, %s; sharding state %s.\n", report.Container, report.ObjectCount, humanBytes(report.BytesUsed), state)
+	if !report.Exposed {
+		fmt.Fprintln(os.Stderr, "The shard ranges are not exposed to this client; they are usually only listed for internal clients or with the gatekeeper middleware bypassed.")
+		return
+	}
+	if len(report.Shards) == 0 {
+		fmt.Println("Not sharded.")
+		return
+	}
+	fmt.Printf("%d shards.\n", len(report.Shards))
+	data := [][]string{{"Lower", "Upper", "State", "Objects", "Bytes", "Share"}}
+	for _, shard := range report.Shards {
+		lower, upper := shard.Lower, shard.Upper
+		if lower == "" {
+			lower = "(start)"
+		}
+		if upper == "" {
+			upper = "(end)"
+		}
+		data = append(data, []string{lower, upper, shard.State, fmt.Sprintf("%d", shard.ObjectCount), fmt.Sprintf("%d", shard.BytesUsed), fmt.Sprintf("%.1f%%", shard.Percent)})
+	}
+	cli.table(data, nil)
+}
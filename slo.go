@@ -0,0 +1,200 @@
+package nectar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/troubling/nectar/nectarutil"
+)
+
+// SLOSegment is an entry in a static large object manifest: the segment's
+// path, as /<container>/<object>, and its ETag and size, which are checked as
+// the manifest is written unless left empty and 0. Range, such as 0-1023,
+// limits the manifest to part of the segment.
+type SLOSegment struct {
+	Path      string `json:"path"`
+	Etag      string `json:"etag,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	Range     string `json:"range,omitempty"`
+}
+
+// segmentCounter counts the bytes read of a segment, as long as it is rewound
+// only to where it began, as the client does for retries.
+type segmentCounter struct {
+	r     io.Reader
+	start int64
+	n     int64
+}
+
+func newSegmentCounter(r io.Reader) *segmentCounter {
+	s := &segmentCounter{r: r, start: -1}
+	if seeker, ok := r.(io.Seeker); ok {
+		if pos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			s.start = pos
+		}
+	}
+	return s
+}
+
+func (s *segmentCounter) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+	return n, err
+}
+
+func (s *segmentCounter) Seek(offset int64, whence int) (int64, error) {
+	if s.start < 0 {
+		return 0, fmt.Errorf("segment cannot be rewound")
+	}
+	pos, err := s.r.(io.Seeker).Seek(offset, whence)
+	if err == nil {
+		s.n = pos - s.start
+	}
+	return pos, err
+}
+
+// PutSegment uploads src as a segment of a static large object, returning its
+// manifest entry, with the ETag and size as uploaded, or the error response.
+// Each segment but the last must be at least the cluster's min_segment_size,
+// 1 MiB by default, and at most its max_file_size, 5 GiB by default.
+func PutSegment(c Client, container string, object string, headers map[string]string, src io.Reader) (*SLOSegment, *http.Response) {
+	counter := newSegmentCounter(src)
+	resp := c.PutObject(container, object, headers, counter)
+	if resp.StatusCode/100 != 2 {
+		return nil, resp
+	}
+	return &SLOSegment{Path: "/" + container + "/" + object, Etag: strings.Trim(resp.Header.Get("Etag"), "\""), SizeBytes: counter.n}, resp
+}
+
+// NewSLOManifest returns the manifest JSON for the segments, in order, as
+// PutManifest writes it.
+func NewSLOManifest(segments []*SLOSegment) ([]byte, error) {
+	for i, segment := range segments {
+		if segment == nil || segment.Path == "" {
+			return nil, fmt.Errorf("Segment %d of the manifest has no path", i)
+		}
+	}
+	return json.Marshal(segments)
+}
+
+// PutManifest writes the static large object manifest for the segments to
+// container/object, using ?multipart-manifest=put; the headers, such as
+// Content-Type and X-Object-Meta- headers, are for the large object.
+func PutManifest(c Client, container string, object string, headers map[string]string, segments []*SLOSegment) *http.Response {
+	manifest, err := NewSLOManifest(segments)
+	if err != nil {
+		return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+	}
+	return c.Raw("PUT", "/"+nectarutil.EscapeObjectPath(container)+"/"+nectarutil.EscapeObjectPath(object)+"?multipart-manifest=put", headers, bytes.NewReader(manifest))
+}
+
+// DeleteManifest deletes the static large object and all its segments, using
+// ?multipart-manifest=delete. Note the response may be a 200 whose body lists
+// segments that could not be deleted.
+func DeleteManifest(c Client, container string, object string, headers map[string]string) *http.Response {
+	return c.Raw("DELETE", "/"+nectarutil.EscapeObjectPath(container)+"/"+nectarutil.EscapeObjectPath(object)+"?multipart-manifest=delete", headers, nil)
+}
+
+// uploadSegmented uploads the file as a static large object: the file is
+// split into segments of -segment-size that are uploaded concurrently to the
+// segment container, and then the manifest is written to container/object.
+// Segments are named <object>/slo/<mtime>/<size>/<segment-size>/<index>, as
+// other Swift clients do, so a changed file never overwrites the segments of
+// the manifest already in place. The headers are for the manifest.
+func (cli *CLIInstance) uploadSegmented(c Client, limiter *adaptiveLimiter, path string, fi os.FileInfo, container string, object string, headers map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Cannot open %s while attempting to upload to %s/%s: %s", path, container, object, err)
+	}
+	defer f.Close()
+	size := fi.Size()
+	segmentSize := int64(cli.uploadFlagSegmentSize)
+	segmentContainer := cli.uploadSegmentContainer(container)
+	prefix := fmt.Sprintf("%s/slo/%d.%06d/%d/%d/", object, fi.ModTime().Unix(), fi.ModTime().Nanosecond()/1000, size, segmentSize)
+	segments := make([]*SLOSegment, (size+segmentSize-1)/segmentSize)
+	concurrency := *cli.globalFlagConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	indexes := make(chan int, len(segments))
+	for i := range segments {
+		indexes <- i
+	}
+	close(indexes)
+	var errLock sync.Mutex
+	var firstErr error
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errLock.Lock()
+				failed := firstErr != nil
+				errLock.Unlock()
+				if failed {
+					continue
+				}
+				offset := int64(i) * segmentSize
+				length := segmentSize
+				if offset+length > size {
+					length = size - offset
+				}
+				name := fmt.Sprintf("%s%08d", prefix, i)
+				cli.verbosef(cli, "Uploading segment %d of %q to %q %q.\n", i, path, segmentContainer, name)
+				limiter.acquire()
+				opStart := time.Now()
+				segment, resp := PutSegment(c, segmentContainer, name, cli.globalFlagHeaders.Headers(), io.NewSectionReader(f, offset, length))
+				// As with whole files, a stalled segment is sent again from
+				// its start.
+				for attempt := 0; resp.StatusCode == http.StatusRequestTimeout && *cli.globalFlagStallTimeout != "" && attempt < stallRetries; attempt++ {
+					resp.Body.Close()
+					cli.verbosef(cli, "Upload of segment %d of %q stalled; retrying.\n", i, path)
+					segment, resp = PutSegment(c, segmentContainer, name, cli.globalFlagHeaders.Headers(), io.NewSectionReader(f, offset, length))
+				}
+				limiter.release(opStart, resp.StatusCode)
+				cli.verboseTransID(resp)
+				if resp.StatusCode/100 != 2 {
+					err := NewResponseError(resp)
+					errLock.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errLock.Unlock()
+					continue
+				}
+				resp.Body.Close()
+				segments[i] = segment
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	cli.verbosef(cli, "Writing manifest of %d segments for %q to %q %q.\n", len(segments), path, container, object)
+	resp := PutManifest(c, container, object, headers, segments)
+	cli.verboseTransID(resp)
+	if resp.StatusCode/100 != 2 {
+		return cli.clobberError(resp, container, object)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// uploadSegmentContainer returns the container for the segments of objects
+// uploaded to the container: -segment-container if given, or otherwise
+// <container>_segments as is the Swift convention.
+func (cli *CLIInstance) uploadSegmentContainer(container string) string {
+	if *cli.uploadFlagSegmentContainer != "" {
+		return *cli.uploadFlagSegmentContainer
+	}
+	return container + "_segments"
+}
@@ -0,0 +1,159 @@
+package nectar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// listingSnapshot is a container listing as saved by snapshot-list, for
+// snapshot-diff to compare with another. Objects are in listing order, that
+// is by name.
+type listingSnapshot struct {
+	Container string            `json:"container"`
+	Prefix    string            `json:"prefix"`
+	Created   time.Time         `json:"created"`
+	Objects   []*snapshotObject `json:"objects"`
+}
+
+type snapshotObject struct {
+	Name  string `json:"name"`
+	Hash  string `json:"hash"`
+	Bytes int64  `json:"bytes"`
+}
+
+// snapshotDiff is the result of comparing two snapshots: the names of the
+// objects only in the newer, only in the older, and in both but with a
+// different hash or size.
+type snapshotDiff struct {
+	Created  []string `json:"created"`
+	Deleted  []string `json:"deleted"`
+	Modified []string `json:"modified"`
+}
+
+// diffSnapshots compares the older and newer snapshots, which must both be in
+// name order.
+func diffSnapshots(older *listingSnapshot, newer *listingSnapshot) *snapshotDiff {
+	d := &snapshotDiff{Created: []string{}, Deleted: []string{}, Modified: []string{}}
+	i, j := 0, 0
+	for i < len(older.Objects) || j < len(newer.Objects) {
+		switch {
+		case j >= len(newer.Objects) || (i < len(older.Objects) && older.Objects[i].Name < newer.Objects[j].Name):
+			d.Deleted = append(d.Deleted, older.Objects[i].Name)
+			i++
+		case i >= len(older.Objects) || newer.Objects[j].Name < older.Objects[i].Name:
+			d.Created = append(d.Created, newer.Objects[j].Name)
+			j++
+		default:
+			if older.Objects[i].Hash != newer.Objects[j].Hash || older.Objects[i].Bytes != newer.Objects[j].Bytes {
+				d.Modified = append(d.Modified, newer.Objects[j].Name)
+			}
+			i++
+			j++
+		}
+	}
+	return d
+}
+
+func (cli *CLIInstance) snapshotList(c Client, args []string) {
+	if err := cli.SnapshotListFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.SnapshotListFlags.Args()
+	if len(args) != 2 {
+		cli.fatalf(cli, "snapshot-list requires <container> <snapshot>.\n")
+	}
+	snapshot := &listingSnapshot{Container: args[0], Prefix: *cli.snapshotListFlagPrefix, Created: time.Now().UTC(), Objects: []*snapshotObject{}}
+	if err := cli.eachObject(c, snapshot.Container, snapshot.Prefix, func(entry *ObjectRecord) bool {
+		snapshot.Objects = append(snapshot.Objects, &snapshotObject{Name: entry.Name, Hash: entry.Hash, Bytes: int64(entry.Bytes)})
+		return true
+	}); err != nil {
+		cli.fatal(cli, err)
+	}
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	if cli.snapshotFlagObject {
+		container, object := parsePath(args[1:])
+		if object == "" {
+			cli.fatalf(cli, "snapshot-list -object requires <snapshot> as <container>/<object>.\n")
+		}
+		headers := cli.globalFlagHeaders.Headers()
+		headers["Content-Type"] = "application/json"
+		resp := c.PutObject(container, object, headers, bytes.NewReader(b))
+		cli.verboseTransID(resp)
+		if resp.StatusCode/100 != 2 {
+			cli.fatal(cli, NewResponseError(resp))
+		}
+		resp.Body.Close()
+	} else if err = ioutil.WriteFile(args[1], b, 0644); err != nil {
+		cli.fatal(cli, err)
+	}
+	cli.verbosef(cli, "Saved a snapshot of %d objects in %q to %s.\n", len(snapshot.Objects), snapshot.Container, args[1])
+}
+
+// readSnapshot reads the snapshot from the local file, or from the object
+// given as <container>/<object> if -object was given.
+func (cli *CLIInstance) readSnapshot(c Client, path string) *listingSnapshot {
+	var b []byte
+	var err error
+	if cli.snapshotFlagObject {
+		container, object := parsePath([]string{path})
+		resp := c.GetObject(container, object, cli.globalFlagHeaders.Headers())
+		cli.verboseTransID(resp)
+		if resp.StatusCode/100 != 2 {
+			cli.fatal(cli, NewResponseError(resp))
+		}
+		b, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	} else {
+		b, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	var snapshot listingSnapshot
+	if err = json.Unmarshal(b, &snapshot); err != nil {
+		cli.fatalf(cli, "Could not parse snapshot %s: %s\n", path, err)
+	}
+	return &snapshot
+}
+
+func (cli *CLIInstance) snapshotDiff(c Client, args []string) {
+	if err := cli.SnapshotDiffFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	args = cli.SnapshotDiffFlags.Args()
+	if len(args) != 2 {
+		cli.fatalf(cli, "snapshot-diff requires <older snapshot> <newer snapshot>.\n")
+	}
+	// Only snapshots in objects need authentication.
+	if cli.snapshotFlagObject && c == nil {
+		cli.connect()
+		c = cli.client
+	}
+	older := cli.readSnapshot(c, args[0])
+	newer := cli.readSnapshot(c, args[1])
+	if older.Container != newer.Container || older.Prefix != newer.Prefix {
+		fmt.Fprintf(os.Stderr, "Note: comparing snapshots of %q %q and %q %q.\n", older.Container, older.Prefix, newer.Container, newer.Prefix)
+	}
+	d := diffSnapshots(older, newer)
+	if *cli.globalFlagJSON {
+		cli.printJSON(d)
+	} else {
+		for _, name := range d.Created {
+			fmt.Println("created", name)
+		}
+		for _, name := range d.Deleted {
+			fmt.Println("deleted", name)
+		}
+		for _, name := range d.Modified {
+			fmt.Println("modified", name)
+		}
+	}
+	cli.verbosef(cli, "%d created, %d deleted, and %d modified between %s and %s.\n", len(d.Created), len(d.Deleted), len(d.Modified), older.Created.Format(time.RFC3339), newer.Created.Format(time.RFC3339))
+}
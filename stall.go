@@ -0,0 +1,138 @@
+package nectar
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/troubling/nectar/nectarutil"
+)
+
+// ErrTransferStalled is the error given when no bytes have moved for the
+// timeout given with WithStallTimeout. Reads from a response body return it
+// directly; if the stall happened before the response arrived, and the request
+// could not be retried, a 408 response stub is returned instead.
+var ErrTransferStalled = errors.New("transfer stalled")
+
+// stallRetries is how many times a stalled transfer will be retried.
+const stallRetries = 3
+
+// WithStallTimeout aborts any request where no bytes have moved, in either
+// direction, for the timeout. This is distinct from the overall request
+// timeout, protecting long transfers from half-dead connections that never
+// error out. Requests stalling before the response arrives are retried if
+// their bodies, if any, can be rewound.
+func WithStallTimeout(timeout time.Duration) ClientOption {
+	return func(c *userClient) {
+		c.stallTimeout = timeout
+	}
+}
+
+// stallWatchdog cancels a request if it is not kicked within the timeout.
+type stallWatchdog struct {
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  func()
+	fired   int32
+}
+
+// newStallWatchdog returns a watchdog that will call cancel, and increment
+// stalls, if not kicked within the timeout.
+func newStallWatchdog(timeout time.Duration, cancel func(), stalls *int64) *stallWatchdog {
+	w := &stallWatchdog{timeout: timeout, cancel: cancel}
+	w.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&w.fired, 1)
+		atomic.AddInt64(stalls, 1)
+		cancel()
+	})
+	return w
+}
+
+// kick notes that bytes have moved.
+func (w *stallWatchdog) kick() {
+	w.timer.Reset(w.timeout)
+}
+
+// stop releases the watchdog's resources once its request is done.
+func (w *stallWatchdog) stop() {
+	w.timer.Stop()
+	w.cancel()
+}
+
+func (w *stallWatchdog) stalled() bool {
+	return atomic.LoadInt32(&w.fired) == 1
+}
+
+// stallReadCloser kicks its watchdog as bytes are read; if final, closing it
+// stops the watchdog.
+type stallReadCloser struct {
+	io.ReadCloser
+	watchdog *stallWatchdog
+	final    bool
+}
+
+func (r *stallReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.watchdog.kick()
+	}
+	if err != nil && err != io.EOF && r.watchdog.stalled() {
+		err = ErrTransferStalled
+	}
+	return n, err
+}
+
+func (r *stallReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	if r.final {
+		r.watchdog.stop()
+	}
+	return err
+}
+
+// sendWatched is send with a stall watchdog on the request.
+func (c *userClient) sendWatched(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithCancel(req.Context())
+		watchdog := newStallWatchdog(c.stallTimeout, cancel, &c.stats.stalls)
+		wreq := req.WithContext(ctx)
+		if wreq.Body != nil && wreq.Body != http.NoBody {
+			wreq.Body = &stallReadCloser{ReadCloser: wreq.Body, watchdog: watchdog}
+		}
+		resp, err := c.sendObserved(wreq)
+		if err == nil {
+			resp.Body = &stallReadCloser{ReadCloser: resp.Body, watchdog: watchdog, final: true}
+			return resp, nil
+		}
+		watchdog.stop()
+		if !watchdog.stalled() {
+			return nil, err
+		}
+		if attempt >= stallRetries {
+			return nil, ErrTransferStalled
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, ErrTransferStalled
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, ErrTransferStalled
+			}
+			req = req.WithContext(req.Context())
+			req.Body = body
+		}
+		atomic.AddInt64(&c.stats.retries, 1)
+	}
+}
+
+// errorResponse returns a response stub for an error from send.
+func errorResponse(err error) *http.Response {
+	if err == ErrTransferStalled {
+		return nectarutil.ResponseStub(http.StatusRequestTimeout, err.Error())
+	}
+	return nectarutil.ResponseStub(http.StatusBadRequest, err.Error())
+}
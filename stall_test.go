@@ -0,0 +1,217 @@
+package nectar
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stallServer serves the object /c/o, stalling its first stalls requests for
+// it: a GET stalls after half the content, anything else before responding.
+// It records the headers of each request for the object, and the body of the
+// PUT that completes.
+type stallServer struct {
+	*httptest.Server
+	content string
+	lock    sync.Mutex
+	stalls  int
+	headers []http.Header
+	put     string
+}
+
+func newStallServer(content string, stalls int) *stallServer {
+	s := &stallServer{content: content, stalls: stalls}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *stallServer) etag() string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(s.content)))
+}
+
+func (s *stallServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/c/o" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.lock.Lock()
+	s.headers = append(s.headers, r.Header)
+	stall := s.stalls > 0
+	if stall {
+		s.stalls--
+	}
+	s.lock.Unlock()
+	if r.Method != "GET" {
+		body, _ := ioutil.ReadAll(r.Body)
+		if stall {
+			<-r.Context().Done()
+			return
+		}
+		if r.Method == "PUT" {
+			s.lock.Lock()
+			s.put = string(body)
+			s.lock.Unlock()
+		}
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	content := s.content
+	w.Header().Set("Etag", s.etag())
+	status := http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" {
+		start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+		if err != nil || start >= len(content) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != `"`+s.etag()+`"` {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(content)-1, len(content)))
+		content = content[start:]
+		status = http.StatusPartialContent
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(status)
+	if stall {
+		w.Write([]byte(content[:len(content)/2]))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		return
+	}
+	w.Write([]byte(content))
+}
+
+func (s *stallServer) requests() []http.Header {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]http.Header(nil), s.headers...)
+}
+
+func newStallClient(url string) *userClient {
+	c := newTestClient(url)
+	c.stallTimeout = 50 * time.Millisecond
+	return c
+}
+
+func TestStallMidBody(t *testing.T) {
+	s := newStallServer(strings.Repeat("0123456789", 1000), 1)
+	defer s.Close()
+	c := newStallClient(s.URL)
+	resp := c.GetObject("c", "o", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != ErrTransferStalled {
+		t.Errorf("got error %v, want %v", err, ErrTransferStalled)
+	}
+	if len(body) != len(s.content)/2 {
+		t.Errorf("got %d bytes before the stall, want %d", len(body), len(s.content)/2)
+	}
+	if stalls := c.Stats()["stalls"]; stalls != 1 {
+		t.Errorf("got %d stalls, want 1", stalls)
+	}
+}
+
+func TestStallBeforeResponse(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		body io.Reader
+		want int
+	}{
+		{name: "no body", want: stallRetries + 1},
+		{name: "rewindable body", body: seekable("content"), want: stallRetries + 1},
+		{name: "body that cannot be rewound", body: ioutil.NopCloser(strings.NewReader("content")), want: 1},
+	} {
+		s := newStallServer("content", stallRetries+2)
+		c := newStallClient(s.URL)
+		var resp *http.Response
+		if test.body == nil {
+			resp = c.PostObject("c", "o", nil)
+		} else {
+			resp = c.PutObject("c", "o", nil, test.body)
+		}
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestTimeout || !strings.Contains(string(msg), ErrTransferStalled.Error()) {
+			t.Errorf("%s: got %d %q, want a 408 stub", test.name, resp.StatusCode, msg)
+		}
+		if got := len(s.requests()); got != test.want {
+			t.Errorf("%s: got %d attempts, want %d", test.name, got, test.want)
+		}
+		s.Close()
+	}
+}
+
+func TestDownloadStallResume(t *testing.T) {
+	s := newStallServer(strings.Repeat("0123456789", 1000), 1)
+	defer s.Close()
+	c := newStallClient(s.URL)
+	dir, err := ioutil.TempDir("", "nectar-stall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cli := newCLIInstance("nectar", nil, nil, nil, nil)
+	cli.buffers = newBufferPool(0)
+	destpath := filepath.Join(dir, "o")
+	if err = cli.downloadObject(c, nil, nil, "c", "o", destpath); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(destpath); err != nil || string(content) != s.content {
+		t.Errorf("got %d bytes, error %v; want the %d bytes of the object", len(content), err, len(s.content))
+	}
+	requests := s.requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want the GET and its resumption", len(requests))
+	}
+	if got, want := requests[1].Get("Range"), fmt.Sprintf("bytes=%d-", len(s.content)/2); got != want {
+		t.Errorf("resumed with Range %q, want %q", got, want)
+	}
+	if got, want := requests[1].Get("If-Match"), `"`+s.etag()+`"`; got != want {
+		t.Errorf("resumed with If-Match %q, want %q", got, want)
+	}
+}
+
+func TestUploadStallRetry(t *testing.T) {
+	// Each PutObject retries the stall itself stallRetries times, so the
+	// first gives upload a 408 stub to retry.
+	s := newStallServer("", stallRetries+1)
+	defer s.Close()
+	c := newStallClient(s.URL)
+	dir, err := ioutil.TempDir("", "nectar-stall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	if err = ioutil.WriteFile(path, []byte("the content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cli := newCLIInstance("nectar", nil, nil, nil, nil)
+	cli.buffers = newBufferPool(0)
+	*cli.globalFlagStallTimeout = c.stallTimeout.String()
+	cli.upload(c, []string{path, "c/o"})
+	if got := len(s.requests()); got != stallRetries+2 {
+		t.Errorf("got %d PUTs, want %d", got, stallRetries+2)
+	}
+	if s.put != "the content" {
+		t.Errorf("uploaded %q, want %q", s.put, "the content")
+	}
+	if stalls := c.Stats()["stalls"]; stalls != stallRetries+1 {
+		t.Errorf("got %d stalls, want %d", stalls, stallRetries+1)
+	}
+}
@@ -0,0 +1,170 @@
+package nectar
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Tags are key/value labels on objects, much like S3 object tags, kept as
+// object metadata named Tag-<key>; that is, the X-Object-Meta-Tag-<key>
+// header. Keys are case insensitive, as header names are, and are returned in
+// canonical header form, such as Env for env. Since Swift replaces all of an
+// object's metadata on a POST, changing a tag rewrites the rest of the
+// metadata as read just before; changes made to the object's metadata by
+// others in between will be lost.
+const tagMetaPrefix = "Tag-"
+
+// tagPostHeaders are the headers besides metadata that an object POST
+// replaces, and so must be sent again to be kept.
+var tagPostHeaders = []string{"Content-Type", "Content-Disposition", "Content-Encoding", "X-Delete-At", "X-Object-Manifest"}
+
+// ObjectTags returns the tags in the object's metadata, keyed by tag key.
+func ObjectTags(info *ObjectInfo) map[string]string {
+	tags := map[string]string{}
+	for name, value := range info.Metadata {
+		if strings.HasPrefix(name, tagMetaPrefix) && len(name) > len(tagMetaPrefix) {
+			tags[name[len(tagMetaPrefix):]] = value
+		}
+	}
+	return tags
+}
+
+// AddTag sets the tag on the object, replacing any value it had.
+func AddTag(c Client, container string, object string, key string, value string, headers map[string]string) error {
+	return retag(c, container, object, headers, func(tags map[string]string) bool {
+		tags[tagKey(key)] = value
+		return true
+	})
+}
+
+// RemoveTag removes the tag from the object; it is not an error if the object
+// did not have the tag.
+func RemoveTag(c Client, container string, object string, key string, headers map[string]string) error {
+	return retag(c, container, object, headers, func(tags map[string]string) bool {
+		if _, ok := tags[tagKey(key)]; !ok {
+			return false
+		}
+		delete(tags, tagKey(key))
+		return true
+	})
+}
+
+// ListByTag returns the names of the objects in the container beginning with
+// the prefix that have the tag, with the value unless value is "". As listings
+// do not include metadata, every object listed is HEADed, concurrency at a
+// time.
+func ListByTag(c Client, container string, prefix string, key string, value string, concurrency int, headers map[string]string) ([]string, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var names []string
+	marker := ""
+	for {
+		entries, resp := c.GetContainer(container, marker, "", 0, prefix, "", false, headers)
+		if resp.StatusCode/100 != 2 {
+			return nil, NewResponseError(resp)
+		}
+		resp.Body.Close()
+		if len(entries) == 0 {
+			return names, nil
+		}
+		matched := make([]bool, len(entries))
+		var errLock sync.Mutex
+		var firstErr error
+		indexes := make(chan int, len(entries))
+		for i := range entries {
+			indexes <- i
+		}
+		close(indexes)
+		wg := sync.WaitGroup{}
+		wg.Add(concurrency)
+		for i := 0; i < concurrency; i++ {
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					if entries[i].Name == "" {
+						continue
+					}
+					exists, info, err := ObjectExists(c, container, entries[i].Name, headers)
+					if err != nil {
+						errLock.Lock()
+						if firstErr == nil {
+							firstErr = err
+						}
+						errLock.Unlock()
+						continue
+					}
+					// Objects deleted since being listed are just skipped.
+					if !exists {
+						continue
+					}
+					tagged, ok := ObjectTags(info)[tagKey(key)]
+					matched[i] = ok && (value == "" || tagged == value)
+				}
+			}()
+		}
+		wg.Wait()
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		for i, entry := range entries {
+			if matched[i] {
+				names = append(names, entry.Name)
+			}
+		}
+		marker = entries[len(entries)-1].Name
+	}
+}
+
+// tagKey returns the key in canonical form, as ObjectTags returns them.
+func tagKey(key string) string {
+	return http.CanonicalHeaderKey(tagMetaPrefix + key)[len(tagMetaPrefix):]
+}
+
+// retag HEADs the object and calls fn with its tags; if fn returns true the
+// tags as fn left them are POSTed along with the rest of the object's
+// metadata.
+func retag(c Client, container string, object string, headers map[string]string, fn func(tags map[string]string) bool) error {
+	resp := c.HeadObject(container, object, headers)
+	if resp.StatusCode/100 != 2 {
+		return NewResponseError(resp)
+	}
+	resp.Body.Close()
+	tags := map[string]string{}
+	post := map[string]string{}
+	for k, v := range headers {
+		post[k] = v
+	}
+	for k, vs := range resp.Header {
+		if len(vs) == 0 {
+			continue
+		}
+		name := metaHeaderName(k)
+		if name == "" {
+			continue
+		}
+		if strings.HasPrefix(name, tagMetaPrefix) && len(name) > len(tagMetaPrefix) {
+			tags[name[len(tagMetaPrefix):]] = vs[0]
+		} else {
+			post[http.CanonicalHeaderKey(k)] = vs[0]
+		}
+	}
+	if !fn(tags) {
+		return nil
+	}
+	for _, k := range tagPostHeaders {
+		if v := resp.Header.Get(k); v != "" {
+			post[k] = v
+		}
+	}
+	for k, v := range tags {
+		post["X-Object-Meta-"+tagMetaPrefix+k] = v
+	}
+	resp = c.PostObject(container, object, post)
+	if resp.StatusCode/100 != 2 {
+		return NewResponseError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
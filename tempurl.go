@@ -0,0 +1,104 @@
+package nectar
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/troubling/nectar/nectarutil"
+)
+
+// TempURLSignature returns the signature of a temporary URL for the method,
+// such as GET or PUT, until the expiry, a Unix time, for the path, such as
+// /v1/AUTH_test/container/object, given the account's or container's
+// X-*-Meta-Temp-URL-Key. The digest is sha1 or sha256; the cluster must allow
+// it in its tempurl allowed_digests. For a prefix-scoped temporary URL, path
+// ends with the prefix and is given with a "prefix:" in front.
+func TempURLSignature(digest string, key string, method string, expires int64, path string) (string, error) {
+	var h func() hash.Hash
+	switch digest {
+	case "sha1":
+		h = sha1.New
+	case "sha256":
+		h = sha256.New
+	default:
+		return "", fmt.Errorf("unknown temporary URL digest %q; use sha1 or sha256", digest)
+	}
+	mac := hmac.New(h, []byte(key))
+	fmt.Fprintf(mac, "%s\n%d\n%s", method, expires, path)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// TempURL returns a temporary URL for the method on container/object under
+// the storageURL, such as Client.GetURL gives, valid until expires; see
+// TempURLSignature. With prefix, the URL is valid for every object whose name
+// begins with object, the object name then being replaced in the URL as
+// needed.
+func TempURL(storageURL string, container string, object string, method string, key string, digest string, expires time.Time, prefix bool) (string, error) {
+	base := strings.TrimSuffix(storageURL, "/")
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	// The signature is of the path unescaped, as the server sees it.
+	path := u.Path + "/" + container + "/" + object
+	signed := path
+	if prefix {
+		signed = "prefix:" + path
+	}
+	sig, err := TempURLSignature(digest, key, method, expires.Unix(), signed)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{"temp_url_sig": {sig}, "temp_url_expires": {strconv.FormatInt(expires.Unix(), 10)}}
+	if prefix {
+		query.Set("temp_url_prefix", object)
+	}
+	return base + "/" + nectarutil.EscapeObjectPath(container) + "/" + nectarutil.EscapeObjectPath(object) + "?" + query.Encode(), nil
+}
+
+func (cli *CLIInstance) tempurl(c Client, args []string) {
+	if err := cli.TempURLFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, object := parsePath(cli.TempURLFlags.Args())
+	if container == "" || (object == "" && !*cli.tempurlFlagPrefix) {
+		cli.fatalf(cli, "tempurl requires <container> <object>, or <container> [prefix] with -prefix.\n")
+	}
+	lifetime, err := time.ParseDuration(*cli.tempurlFlagExpires)
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	method := strings.ToUpper(*cli.tempurlFlagMethod)
+	key := *cli.tempurlFlagKey
+	if key == "" {
+		// The container's key is preferred so the account's need not be
+		// shared with those managing just the container.
+		info, err := HeadContainerInfo(c, container, cli.globalFlagHeaders.Headers())
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		if key = info.Header.Get("X-Container-Meta-Temp-Url-Key"); key == "" {
+			ainfo, err := HeadAccountInfo(c, cli.globalFlagHeaders.Headers())
+			if err != nil {
+				cli.fatal(cli, err)
+			}
+			key = ainfo.Header.Get("X-Account-Meta-Temp-Url-Key")
+		}
+		if key == "" {
+			cli.fatalf(cli, "Neither the container nor the account has a Temp-URL-Key set; set one with post -m Temp-URL-Key=<key> or give -key.\n")
+		}
+	}
+	u, err := TempURL(c.GetURL(), container, object, method, key, *cli.tempurlFlagDigest, time.Now().Add(lifetime), *cli.tempurlFlagPrefix)
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	fmt.Println(u)
+}
@@ -0,0 +1,46 @@
+package nectar
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// LoadTLSConfig returns a TLS configuration for WithTLSConfig that trusts the
+// certificate authorities in the PEM file caFile, if given, in addition to the
+// system's, and presents the client certificate in the PEM files certFile and
+// keyFile, if given. With insecure, server certificates are not verified at
+// all.
+func LoadTLSConfig(caFile string, certFile string, keyFile string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		if keyFile == "" {
+			// The key may be in the same file as the certificate.
+			keyFile = certFile
+		}
+		if certFile == "" {
+			return nil, fmt.Errorf("A client key requires a client certificate")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
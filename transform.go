@@ -0,0 +1,73 @@
+package nectar
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// transformFlag is a list of sed style s|pattern|replacement|[g] rules
+// applied in order to object names. Any character may be used as the
+// delimiter in place of |, and may be escaped with a backslash within the
+// pattern or replacement. The pattern is a Go regular expression and
+// replacements may refer to submatches as \1 or ${1}. Without the g flag
+// only the first match is replaced.
+type transformFlag []*transformRule
+
+type transformRule struct {
+	source      string
+	re          *regexp.Regexp
+	replacement string
+	global      bool
+}
+
+var sedGroupRefs = regexp.MustCompile(`\\([0-9])`)
+
+func (tf *transformFlag) Set(value string) error {
+	if len(value) < 2 || value[0] != 's' {
+		return fmt.Errorf("invalid transform %q; expected s|pattern|replacement|", value)
+	}
+	delim := value[1:2]
+	var parts []string
+	var part strings.Builder
+	for i := 2; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) && value[i+1:i+2] == delim {
+			part.WriteString(delim)
+			i++
+		} else if value[i:i+1] == delim {
+			parts = append(parts, part.String())
+			part.Reset()
+		} else {
+			part.WriteByte(value[i])
+		}
+	}
+	if len(parts) != 2 || (part.String() != "" && part.String() != "g") {
+		return fmt.Errorf("invalid transform %q; expected s|pattern|replacement|", value)
+	}
+	re, err := regexp.Compile(parts[0])
+	if err != nil {
+		return fmt.Errorf("invalid transform %q: %s", value, err)
+	}
+	*tf = append(*tf, &transformRule{source: value, re: re, replacement: sedGroupRefs.ReplaceAllString(parts[1], "$${$1}"), global: part.String() == "g"})
+	return nil
+}
+
+func (tf *transformFlag) String() string {
+	var sources []string
+	for _, rule := range *tf {
+		sources = append(sources, rule.source)
+	}
+	return strings.Join(sources, " ")
+}
+
+// apply returns the name with each rule applied in turn.
+func (tf *transformFlag) apply(name string) string {
+	for _, rule := range *tf {
+		if rule.global {
+			name = rule.re.ReplaceAllString(name, rule.replacement)
+		} else if loc := rule.re.FindStringSubmatchIndex(name); loc != nil {
+			name = name[:loc[0]] + string(rule.re.ExpandString(nil, rule.replacement, name, loc)) + name[loc[1]:]
+		}
+	}
+	return name
+}
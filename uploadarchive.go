@@ -0,0 +1,136 @@
+package nectar
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// archivePAXRecords returns the PAX records by which the bulk middleware's
+// extract-archive sets the content type and metadata of the object for a file;
+// other headers, such as X-Delete-After, cannot be carried this way.
+func archivePAXRecords(headers map[string]string) map[string]string {
+	records := map[string]string{}
+	for k, v := range headers {
+		if strings.EqualFold(k, "Content-Type") {
+			records["SCHILY.xattr.user.mime_type"] = v
+		} else if len(k) > len("X-Object-Meta-") && strings.EqualFold(k[:len("X-Object-Meta-")], "X-Object-Meta-") {
+			records["SCHILY.xattr.user.meta."+strings.ToLower(k[len("X-Object-Meta-"):])] = v
+		}
+	}
+	return records
+}
+
+// uploadArchive is upload -archive: the files under sourcepath are written as
+// a gzipped tar, named as upload would name their objects, and streamed to the
+// cluster to be expanded into container.
+func (cli *CLIInstance) uploadArchive(c Client, sourcepath string, container string, object string, marker *dirMarker) {
+	if !strings.HasSuffix(sourcepath, string(os.PathSeparator)) {
+		sourcepath += string(os.PathSeparator)
+	}
+	walk := newUploadWalk(sourcepath, *cli.uploadFlagWalkers, *cli.uploadFlagQueue, *cli.uploadFlagPreserve, nil)
+	pr, pw := io.Pipe()
+	files := 0
+	var archiveErr error
+	done := make(chan struct{})
+	go func() {
+		gz := gzip.NewWriter(pw)
+		tw := tar.NewWriter(gz)
+		// addFile adds path to the archive as opath.
+		addFile := func(path string, opath string) error {
+			fi, err := os.Lstat(path)
+			if err != nil {
+				return err
+			}
+			headers, err := cli.uploadHeaders(path, opath)
+			if err == nil && *cli.uploadFlagPreserve {
+				err = fileAttributeHeaders(path, true, headers)
+			}
+			symlink := headers[preserveSymlinkHeader] != ""
+			if err == nil && *cli.uploadFlagChecksum != "" && !symlink {
+				var sum string
+				if sum, err = fileChecksum(path, *cli.uploadFlagChecksum); err == nil {
+					headers[checksumMetaHeader] = sum
+				}
+			}
+			if err != nil {
+				return err
+			}
+			hdr := &tar.Header{Typeflag: tar.TypeReg, Name: opath, Mode: 0644, ModTime: fi.ModTime(), PAXRecords: archivePAXRecords(headers)}
+			if symlink {
+				return tw.WriteHeader(hdr)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			// The size is from the open file so a file changing size
+			// meanwhile fails rather than corrupting the archive.
+			if fi, err = f.Stat(); err != nil {
+				return err
+			}
+			hdr.Size = fi.Size()
+			if err = tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err = io.CopyN(tw, f, hdr.Size)
+			return err
+		}
+		var err error
+		for path := range walk.queue {
+			if err != nil {
+				// Just drained once failed.
+				continue
+			}
+			if *cli.uploadFlagMetaSidecar != "" && strings.HasSuffix(path, *cli.uploadFlagMetaSidecar) {
+				continue
+			}
+			if marker != nil && path == dirMarkerName {
+				continue
+			}
+			opath := object + cli.uploadFlagTransform.apply(localToObjectPath(path))
+			cli.verbosef(cli, "Archiving %q as %q %q.\n", path, container, opath)
+			if err = addFile(path, opath); err != nil && err != io.ErrClosedPipe {
+				err = fmt.Errorf("Could not archive %s: %s", path, err)
+			}
+			files++
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		if err == nil {
+			err = gz.Close()
+		}
+		archiveErr = err
+		pw.CloseWithError(err)
+		close(done)
+	}()
+	cli.verbosef(cli, "Streaming %s to %q for extract-archive.\n", sourcepath, container)
+	result, err := ExtractArchive(c, container, "", "tar.gz", pr, cli.globalFlagHeaders.Headers())
+	// Should the request end early, the archiving is left to fail.
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	if archiveErr != nil && archiveErr != io.ErrClosedPipe {
+		cli.fatal(cli, archiveErr)
+	}
+	if err != nil {
+		cli.fatal(cli, err)
+	}
+	paths := make([]string, 0, len(result.Errors))
+	for path := range result.Errors {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, result.Errors[path])
+	}
+	fmt.Printf("Archived %d files; %d objects created.\n", files, result.NumberFilesCreated)
+	if len(paths) > 0 && !*cli.globalFlagContinueOnError {
+		cli.fatalf(cli, "%d files could not be extracted.\n", len(paths))
+	}
+}
@@ -0,0 +1,85 @@
+package nectar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// fileMeta is the metadata for one uploaded object, as read from a sidecar
+// file or a -meta-manifest entry, such as:
+//
+//	{"content_type": "text/html", "metadata": {"author": "pat"}, "delete_after": 86400}
+//
+// DeleteAt and DeleteAfter are Unix seconds and seconds from now, as with the
+// X-Delete-At and X-Delete-After headers. Headers are set as given.
+type fileMeta struct {
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata"`
+	DeleteAt    int64             `json:"delete_at"`
+	DeleteAfter int64             `json:"delete_after"`
+	Headers     map[string]string `json:"headers"`
+}
+
+// apply adds the metadata to the headers, returning the headers.
+func (m *fileMeta) apply(headers map[string]string) map[string]string {
+	if m == nil {
+		return headers
+	}
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	if m.ContentType != "" {
+		headers["Content-Type"] = m.ContentType
+	}
+	for k, v := range m.Metadata {
+		headers["X-Object-Meta-"+k] = v
+	}
+	if m.DeleteAt != 0 {
+		headers["X-Delete-At"] = strconv.FormatInt(m.DeleteAt, 10)
+	}
+	if m.DeleteAfter != 0 {
+		headers["X-Delete-After"] = strconv.FormatInt(m.DeleteAfter, 10)
+	}
+	return headers
+}
+
+// loadMetaManifest reads a -meta-manifest file: a JSON object of fileMeta
+// keyed by object name.
+func loadMetaManifest(path string) (map[string]*fileMeta, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := map[string]*fileMeta{}
+	if err = json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("Could not parse %s: %s", path, err)
+	}
+	return manifest, nil
+}
+
+// uploadHeaders returns the headers for uploading the file at path to the
+// object: the global headers, then the -m items, then any -meta-manifest
+// entry for the object, and then any -meta-sidecar file for the path, each
+// overriding the last.
+func (cli *CLIInstance) uploadHeaders(path string, object string) (map[string]string, error) {
+	headers := cli.uploadFlagMeta.MetaHeaders("X-Object-Meta-", cli.globalFlagHeaders.Headers())
+	headers = cli.uploadMetaManifest[object].apply(headers)
+	if *cli.uploadFlagMetaSidecar == "" {
+		return headers, nil
+	}
+	b, err := ioutil.ReadFile(path + *cli.uploadFlagMetaSidecar)
+	if os.IsNotExist(err) {
+		return headers, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m fileMeta
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("Could not parse %s: %s", path+*cli.uploadFlagMetaSidecar, err)
+	}
+	return m.apply(headers), nil
+}
@@ -0,0 +1,129 @@
+package nectar
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// uploadWalk finds the regular files under a directory for upload, reading
+// directories with several goroutines at once so huge trees on network
+// filesystems are not walked one stat at a time. The files found are sent on
+// a bounded queue, letting the walk run ahead of the uploads by that much.
+// Symlinks are not followed, though they are sent along with the files if
+// asked for, and unreadable directories are skipped, as with filepath.Walk,
+// but files are not found in lexical order.
+type uploadWalk struct {
+	// files, bytes, and dirs are first to ensure 64-bit alignment for atomic
+	// use.
+	files     int64
+	bytes     int64
+	dirs      int64
+	done      int32
+	queue     chan string
+	interrupt *interruption
+	symlinks  bool
+	lock      sync.Mutex
+	cond      *sync.Cond
+	pending   []string
+	// reading is the number of directories pending or being read.
+	reading int
+	stopped bool
+}
+
+// newUploadWalk starts walking root with the given number of goroutines;
+// the files found, and symlinks if symlinks is true, are sent on the returned
+// walk's queue, which is closed once the walk is complete or interrupted.
+func newUploadWalk(root string, walkers int, queueSize int, symlinks bool, interrupt *interruption) *uploadWalk {
+	if walkers < 1 {
+		walkers = 1
+	}
+	w := &uploadWalk{queue: make(chan string, queueSize), interrupt: interrupt, symlinks: symlinks, pending: []string{root}, reading: 1}
+	w.cond = sync.NewCond(&w.lock)
+	wg := sync.WaitGroup{}
+	wg.Add(walkers)
+	for i := 0; i < walkers; i++ {
+		go func() {
+			w.walk()
+			wg.Done()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		atomic.StoreInt32(&w.done, 1)
+		close(w.queue)
+	}()
+	return w
+}
+
+func (w *uploadWalk) walk() {
+	for {
+		w.lock.Lock()
+		for len(w.pending) == 0 && w.reading > 0 && !w.stopped {
+			w.cond.Wait()
+		}
+		if len(w.pending) == 0 || w.stopped {
+			w.lock.Unlock()
+			return
+		}
+		dir := w.pending[len(w.pending)-1]
+		w.pending = w.pending[:len(w.pending)-1]
+		w.lock.Unlock()
+		ok := w.read(dir)
+		w.lock.Lock()
+		w.reading--
+		if !ok {
+			w.stopped = true
+		}
+		if w.reading == 0 || w.stopped {
+			w.cond.Broadcast()
+		}
+		w.lock.Unlock()
+	}
+}
+
+// read queues the regular files in dir and adds its subdirectories to the
+// pending list, returning false if interrupted.
+func (w *uploadWalk) read(dir string) bool {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return !w.interrupt.interrupted()
+	}
+	atomic.AddInt64(&w.dirs, 1)
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		if info.IsDir() {
+			w.lock.Lock()
+			w.pending = append(w.pending, path)
+			w.reading++
+			w.cond.Signal()
+			w.lock.Unlock()
+			continue
+		}
+		if w.symlinks && info.Mode()&os.ModeSymlink != 0 {
+			atomic.AddInt64(&w.files, 1)
+		} else if info.Mode().IsRegular() {
+			atomic.AddInt64(&w.files, 1)
+			atomic.AddInt64(&w.bytes, info.Size())
+		} else {
+			continue
+		}
+		select {
+		case w.queue <- path:
+		case <-w.interrupt.done():
+			return false
+		}
+	}
+	return !w.interrupt.interrupted()
+}
+
+func (w *uploadWalk) String() string {
+	s := fmt.Sprintf("Found %d files of %s in %d directories", atomic.LoadInt64(&w.files), humanBytes(atomic.LoadInt64(&w.bytes)), atomic.LoadInt64(&w.dirs))
+	if atomic.LoadInt32(&w.done) == 0 {
+		s += " so far"
+	}
+	return s
+}
@@ -0,0 +1,210 @@
+package nectar
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ObjectVersion is a previous version of an object, kept as an object in the
+// archive container of a container with versioning enabled.
+type ObjectVersion struct {
+	// Container and Object give the archived copy.
+	Container string `json:"container"`
+	Object    string `json:"object"`
+	// Version is the timestamp the archived copy is named for, such as
+	// 1507211250.27396.
+	Version      string `json:"version"`
+	Hash         string `json:"hash"`
+	LastModified string `json:"last_modified"`
+	Bytes        int    `json:"bytes"`
+	ContentType  string `json:"content_type"`
+}
+
+// EnableVersioning sets the container to keep previous versions of its objects
+// in the archive container, creating the archive container if needed. In the
+// default stack mode, X-Versions-Location, deleting an object restores its
+// previous version; with history, X-History-Location, deleting an object
+// archives it too, keeping every version. Either mode replaces the other.
+func EnableVersioning(c Client, container string, archive string, history bool, headers map[string]string) *http.Response {
+	resp := c.PutContainer(archive, headers)
+	if resp.StatusCode/100 != 2 {
+		return resp
+	}
+	resp.Body.Close()
+	h := map[string]string{}
+	for k, v := range headers {
+		h[k] = v
+	}
+	if history {
+		h["X-History-Location"] = archive
+	} else {
+		h["X-Versions-Location"] = archive
+	}
+	return c.PostContainer(container, h)
+}
+
+// DisableVersioning stops the container keeping previous versions of its
+// objects; those already archived are left alone.
+func DisableVersioning(c Client, container string, headers map[string]string) *http.Response {
+	h := map[string]string{"X-Remove-Versions-Location": "x", "X-Remove-History-Location": "x"}
+	for k, v := range headers {
+		h[k] = v
+	}
+	return c.PostContainer(container, h)
+}
+
+// GetVersioning returns the archive container for the container's previous
+// object versions, or "" if versioning is not enabled, and whether it is in
+// history mode; see EnableVersioning.
+func GetVersioning(c Client, container string, headers map[string]string) (string, bool, error) {
+	info, err := HeadContainerInfo(c, container, headers)
+	if err != nil {
+		return "", false, err
+	}
+	if archive := info.Header.Get("X-History-Location"); archive != "" {
+		return archive, true, nil
+	}
+	return info.Header.Get("X-Versions-Location"), false, nil
+}
+
+// versionPrefix returns the prefix of the archived copies of the object: its
+// name's length, as three hex digits, then its name and a slash, as Swift's
+// versioned_writes middleware names them.
+func versionPrefix(object string) string {
+	return fmt.Sprintf("%03x%s/", len(object), object)
+}
+
+// ObjectVersions returns the previous versions of the object kept in the
+// archive container of the container, oldest first as the timestamps are of
+// fixed width.
+func ObjectVersions(c Client, container string, object string, headers map[string]string) ([]*ObjectVersion, error) {
+	archive, _, err := GetVersioning(c, container, headers)
+	if err != nil {
+		return nil, err
+	}
+	if archive == "" {
+		return nil, fmt.Errorf("Container %s does not have versioning enabled", container)
+	}
+	prefix := versionPrefix(object)
+	var versions []*ObjectVersion
+	if err = EachObject(c, archive, prefix, "", headers, func(entry *ObjectRecord) bool {
+		version := entry.Name[len(prefix):]
+		if strings.Contains(version, "/") {
+			// Belongs to an object whose name merely begins the same.
+			return true
+		}
+		versions = append(versions, &ObjectVersion{Container: archive, Object: entry.Name, Version: version, Hash: entry.Hash, LastModified: entry.LastModified, Bytes: entry.Bytes, ContentType: entry.ContentType})
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// RestoreVersion copies the previous version over the object, server-side.
+// In stack mode, the object's current version is itself archived first.
+func RestoreVersion(c Client, container string, object string, version *ObjectVersion, headers map[string]string) *http.Response {
+	return CopyObject(c, version.Container, version.Object, container, object, headers)
+}
+
+func (cli *CLIInstance) versioning(c Client, args []string) {
+	if err := cli.VersioningFlags.Parse(args); err != nil {
+		cli.fatal(cli, err)
+	}
+	container, object := parsePath(cli.VersioningFlags.Args())
+	if container == "" {
+		cli.fatalf(cli, "versioning requires <container> [object].\n")
+	}
+	headers := cli.globalFlagHeaders.Headers()
+	switch {
+	case *cli.versioningFlagEnable != "" || *cli.versioningFlagDisable:
+		if object != "" {
+			cli.fatalf(cli, "versioning -enable and -disable do not take an object.\n")
+		}
+		if *cli.versioningFlagEnable != "" && *cli.versioningFlagDisable {
+			cli.fatalf(cli, "versioning -enable and -disable cannot be used together.\n")
+		}
+		var resp *http.Response
+		if *cli.versioningFlagDisable {
+			resp = DisableVersioning(c, container, headers)
+		} else {
+			resp = EnableVersioning(c, container, *cli.versioningFlagEnable, *cli.versioningFlagHistory, headers)
+		}
+		cli.verboseTransID(resp)
+		if resp.StatusCode/100 != 2 {
+			cli.fatal(cli, NewResponseError(resp))
+		}
+		resp.Body.Close()
+		fallthrough
+	case object == "":
+		if *cli.versioningFlagRestore != "" {
+			cli.fatalf(cli, "versioning -restore requires <container> <object>.\n")
+		}
+		archive, history, err := GetVersioning(c, container, headers)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		if *cli.globalFlagJSON {
+			mode := ""
+			if archive != "" {
+				mode = "stack"
+				if history {
+					mode = "history"
+				}
+			}
+			cli.printJSON(map[string]string{"container": container, "archive": archive, "mode": mode})
+		} else if archive == "" {
+			fmt.Printf("Versioning of %s is disabled.\n", container)
+		} else if history {
+			fmt.Printf("Versioning of %s is in history mode, archiving to %s.\n", container, archive)
+		} else {
+			fmt.Printf("Versioning of %s is in stack mode, archiving to %s.\n", container, archive)
+		}
+	case *cli.versioningFlagRestore != "":
+		versions, err := ObjectVersions(c, container, object, headers)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		var version *ObjectVersion
+		if *cli.versioningFlagRestore == "latest" && len(versions) > 0 {
+			version = versions[len(versions)-1]
+		}
+		for _, v := range versions {
+			if v.Version == *cli.versioningFlagRestore {
+				version = v
+			}
+		}
+		if version == nil {
+			cli.fatalf(cli, "No version %s of %s/%s was found.\n", *cli.versioningFlagRestore, container, object)
+		}
+		cli.verbosef(cli, "Restoring %s/%s from %s/%s.\n", container, object, version.Container, version.Object)
+		resp := RestoreVersion(c, container, object, version, headers)
+		cli.verboseTransID(resp)
+		if resp.StatusCode/100 != 2 {
+			cli.fatal(cli, NewResponseError(resp))
+		}
+		resp.Body.Close()
+	default:
+		versions, err := ObjectVersions(c, container, object, headers)
+		if err != nil {
+			cli.fatal(cli, err)
+		}
+		if *cli.globalFlagJSON {
+			if versions == nil {
+				versions = []*ObjectVersion{}
+			}
+			cli.printJSON(versions)
+			return
+		}
+		if len(versions) == 0 {
+			fmt.Printf("No previous versions of %s/%s.\n", container, object)
+			return
+		}
+		data := [][]string{{"Version", "Last Modified", "Bytes", "ETag"}}
+		for _, v := range versions {
+			data = append(data, []string{v.Version, v.LastModified, fmt.Sprintf("%d", v.Bytes), v.Hash})
+		}
+		cli.table(data, nil)
+	}
+}